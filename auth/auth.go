@@ -3,72 +3,70 @@ package auth
 
 import (
 	"errors"
+	"net/http"
 	"time"
 
 	"github.com/giuliobosco/todoAPI/config"
 	"github.com/giuliobosco/todoAPI/model"
 	"github.com/giuliobosco/todoAPI/utils"
 
-	jwtapple2 "github.com/appleboy/gin-jwt/v2"
 	"github.com/gin-gonic/gin"
+	jwt "github.com/golang-jwt/jwt/v4"
 )
 
 const sExpire string = config.SExpire
 const sToken string = config.SToken
+const sRefreshToken string = config.SRefreshToken
+const sRefreshExpire string = config.SRefreshExpire
+
+// SetupAuth Sets-up the authentication middleware, with the keys of the
+// current configuration
+func SetupAuth() (*Middleware, error) {
+	if _, err := Keys(config.GetDB()); err != nil {
+		return nil, err
+	}
 
-// SetupAuth Sets-up the authentication middleware
-func SetupAuth() (*jwtapple2.GinJWTMiddleware, error) {
-	authMiddleware, err := jwtapple2.New(&jwtapple2.GinJWTMiddleware{
-		Realm: "	apitodogo", // https://tools.ietf.org/html/rfc7235#section-2.2
-		Key:             []byte(config.Key),
-		Timeout:         time.Hour * 24,
-		MaxRefresh:      time.Hour,
-		IdentityKey:     config.IdentityKey,
-		PayloadFunc:     payload,
-		IdentityHandler: identityHandler,
-		Authenticator:   authenticator,
-		Authorizator:    authorizator,
-		Unauthorized:    unauthorized,
-		LoginResponse:   loginResponse,
-		TokenLookup:     "header: Authorization, query: token, cookie: jwtapple2",
-		TokenHeadName:   "Bearer",
-		TimeFunc:        time.Now,
-	})
+	authMiddleware := &Middleware{
+		Realm:   "apitodogo", // https://tools.ietf.org/html/rfc7235#section-2.2
+		Timeout: config.Get().JWT.Timeout,
+	}
 
-	return authMiddleware, err
+	return authMiddleware, nil
 }
 
-// payload maps IdentityKey to ID
-func payload(data interface{}) jwtapple2.MapClaims {
-	if v, ok := data.(*model.User); ok {
-		return jwtapple2.MapClaims{
-			config.IdentityKey: v.ID,
+// payload maps IdentityKey to the ID of the user and SessionKey to the ID of
+// the session
+func payload(data interface{}) jwt.MapClaims {
+	if v, ok := data.(*model.Session); ok {
+		return jwt.MapClaims{
+			config.IdentityKey: v.UserID,
+			config.SessionKey:  v.ID,
 		}
 	}
-	return jwtapple2.MapClaims{}
+	return jwt.MapClaims{}
 }
 
 // identitityHandler identify the user
 func identityHandler(c *gin.Context) interface{} {
-	claims := jwtapple2.ExtractClaims(c)
+	claims := ExtractClaims(c)
 	var user model.User
 	config.GetDB().Where("id = ?", claims[config.IdentityKey]).First(&user)
 
 	return user
 }
 
-// authenticator authenticate the user
-func authenticator(c *gin.Context) (interface{}, error) {
+// authenticator authenticate the user by email and password
+func authenticator(c *gin.Context) (*model.User, error) {
 	var loginVals model.User
 	if err := c.ShouldBindJSON(&loginVals); err != nil {
-		return "", jwtapple2.ErrMissingLoginValues
+		return nil, ErrMissingLoginValues
 	}
 
 	var result model.User
 	config.GetDB().Where("email = ?", loginVals.Email).First(&result)
 
 	if result.ID == 0 {
-		return nil, jwtapple2.ErrFailedAuthentication
+		return nil, ErrFailedAuthentication
 	}
 
 	if !result.Active {
@@ -76,7 +74,7 @@ func authenticator(c *gin.Context) (interface{}, error) {
 	}
 
 	if !utils.ComparePasswordHash(result.Password, loginVals.Password) {
-		return nil, jwtapple2.ErrFailedAuthentication
+		return nil, ErrFailedAuthentication
 	}
 
 	if len(result.VerifyToken) > 0 {
@@ -86,13 +84,35 @@ func authenticator(c *gin.Context) (interface{}, error) {
 	return &result, nil
 }
 
-// authorizator checks the authorization of the user
+// startSession opens a session of the authenticated user, its refresh token
+// is returned by loginResponse
+func startSession(c *gin.Context, user *model.User) (*model.Session, error) {
+	session, refreshToken, err := utils.CreateSession(config.GetDB(), user.ID, c.Request.UserAgent(), c.ClientIP(), config.Get().JWT.RefreshTimeout)
+	if err != nil {
+		return nil, err
+	}
+	c.Set(config.SRefreshToken, refreshToken)
+	c.Set(config.SRefreshExpire, session.ExpiresAt)
+
+	return &session, nil
+}
+
+// authorizator checks the authorization of the user and that the session of
+// the token is still active, the personal access tokens have no session
 func authorizator(data interface{}, c *gin.Context) bool {
-	if v, ok := data.(model.User); ok && v.ID != 0 && v.Active {
+	v, ok := data.(model.User)
+	if !ok || v.ID == 0 || !v.Active {
+		return false
+	}
+
+	claims := ExtractClaims(c)
+	if _, ok := claims[config.AccessTokenKey]; ok {
+		// the personal access tokens are checked by the middleware
 		return true
 	}
 
-	return false
+	sid, ok := claims[config.SessionKey]
+	return ok && utils.ActiveSession(config.GetDB(), sid, v.ID)
 }
 
 // unauthorized returns the messagge of unauthorization
@@ -102,10 +122,104 @@ func unauthorized(c *gin.Context, code int, message string) {
 	})
 }
 
-// loginResponse builds the response of success full login
+// loginResponse builds the response of success full login, with the refresh
+// token of the new session
 func loginResponse(c *gin.Context, code int, token string, expire time.Time) {
+	refreshExpire, _ := c.Get(config.SRefreshExpire)
 	c.JSON(code, gin.H{
-		sExpire: expire,
-		sToken:  token,
+		sExpire:        expire,
+		sToken:         token,
+		sRefreshToken:  c.GetString(config.SRefreshToken),
+		sRefreshExpire: refreshExpire,
 	})
 }
+
+// TwoFactorRequest is the body of the second step of a login
+type TwoFactorRequest struct {
+	Challenge string `json:"challenge"` // challenge of the first step of the login
+	Code      string `json:"code"`      // TOTP or recovery code
+}
+
+// TwoFactorLoginHandler returns the handler of the second step of the login
+// of the users with the two-factor authentication: the challenge of the
+// password verification and a TOTP or recovery code open the session. The
+// wrong codes of a user are counted across the challenges and too many lock
+// the second step for a while, see RecordTwoFactorFailure.
+func TwoFactorLoginHandler(mw *Middleware) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req TwoFactorRequest
+		if err := c.ShouldBindJSON(&req); err != nil || len(req.Challenge) == 0 || len(req.Code) == 0 {
+			mw.unauthorized(c, http.StatusUnauthorized, config.SInvalidTwoFactorCode)
+			return
+		}
+
+		challenge, err := utils.AttemptLoginChallenge(config.GetDB(), req.Challenge)
+		if err != nil {
+			mw.unauthorized(c, http.StatusUnauthorized, err.Error())
+			return
+		}
+
+		var user model.User
+		config.GetDB().Where("id = ? AND active = ?", challenge.UserID, true).First(&user)
+		if user.ID == 0 || !user.TOTPEnabled {
+			mw.unauthorized(c, http.StatusUnauthorized, config.SInvalidChallenge)
+			return
+		}
+		if utils.TwoFactorLocked(user) {
+			mw.unauthorized(c, http.StatusTooManyRequests, config.STwoFactorLocked)
+			return
+		}
+		if !utils.VerifySecondFactor(config.GetDB(), user, req.Code) {
+			if err := utils.RecordTwoFactorFailure(config.GetDB(), user.ID); err != nil {
+				mw.unauthorized(c, http.StatusInternalServerError, err.Error())
+				return
+			}
+			mw.unauthorized(c, http.StatusUnauthorized, config.SInvalidTwoFactorCode)
+			return
+		}
+		if err := utils.ResetTwoFactorFailures(config.GetDB(), user.ID); err != nil {
+			mw.unauthorized(c, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		config.GetDB().Delete(&challenge)
+		mw.login(c, &user)
+	}
+}
+
+// RefreshRequest is the body of the refresh of a session
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"` // current refresh token of the session
+}
+
+// RefreshHandler returns the handler renewing a session: the refresh token is
+// rotated and a new access token is generated. Using again a spent refresh
+// token revokes the session.
+func RefreshHandler(mw *Middleware) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req RefreshRequest
+		if err := c.ShouldBindJSON(&req); err != nil || len(req.RefreshToken) == 0 {
+			unauthorized(c, http.StatusUnauthorized, config.SInvalidRefreshToken)
+			return
+		}
+
+		session, refreshToken, err := utils.RotateRefreshToken(config.GetDB(), req.RefreshToken, c.ClientIP(), config.Get().JWT.RefreshTimeout)
+		if err != nil {
+			unauthorized(c, http.StatusUnauthorized, err.Error())
+			return
+		}
+
+		token, expire, err := mw.TokenGenerator(&session)
+		if err != nil {
+			unauthorized(c, http.StatusUnauthorized, err.Error())
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			sExpire:        expire,
+			sToken:         token,
+			sRefreshToken:  refreshToken,
+			sRefreshExpire: session.ExpiresAt,
+		})
+	}
+}
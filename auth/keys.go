@@ -0,0 +1,372 @@
+package auth
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/giuliobosco/todoAPI/config"
+	"github.com/giuliobosco/todoAPI/model"
+	"github.com/giuliobosco/todoAPI/utils"
+
+	jwt "github.com/golang-jwt/jwt/v4"
+	"github.com/jinzhu/gorm"
+)
+
+// rsaKeySize is the size in bits of the generated RSA keys
+const rsaKeySize = 2048
+
+// ErrUnknownKey is the error of a token signed by an unknown or expired key
+var ErrUnknownKey = errors.New("unknown signing key")
+
+// Key is a key signing and verifying the access tokens
+type Key struct {
+	ID        string            // kid of the tokens signed by the key
+	Method    jwt.SigningMethod // signing method of the key
+	CreatedAt time.Time         // time of the key creation
+	RotatedAt *time.Time        // time of the replacement by a new key, nil for the signing key
+	private   interface{}       // key signing the tokens
+	public    interface{}       // key verifying the tokens
+}
+
+// KeySet is the set of the keys of the access tokens: the current key signs
+// the new tokens, the keys replaced by a rotation still verify the tokens
+// until they expire. With HS256 it has only the key of the configuration,
+// with RS256 and EdDSA the keys are generated and shared by the database,
+// encrypted with the encryption key of the configuration.
+type KeySet struct {
+	mu       sync.RWMutex
+	settings config.JWTSettings
+	aead     cipher.AEAD
+	db       *gorm.DB
+	current  *Key
+	keys     map[string]*Key
+	loadedAt time.Time
+}
+
+// NewKeySet creates the key set of the settings, the RS256 and EdDSA keys are
+// loaded by Load
+func NewKeySet(settings config.JWTSettings) *KeySet {
+	ks := &KeySet{settings: settings, keys: make(map[string]*Key)}
+
+	if !settings.Asymmetric() {
+		// the kid of the shared key does not reveal it
+		sum := sha256.Sum256([]byte(settings.Key))
+		key := &Key{ID: hex.EncodeToString(sum[:8]), Method: jwt.SigningMethodHS256, private: []byte(settings.Key), public: []byte(settings.Key)}
+		ks.current = key
+		ks.keys[key.ID] = key
+	} else {
+		// AES-256-GCM with the key derived from the encryption key, a 32
+		// bytes key is always valid
+		sum := sha256.Sum256([]byte(settings.EncryptionKey))
+		block, _ := aes.NewCipher(sum[:])
+		ks.aead, _ = cipher.NewGCM(block)
+	}
+
+	return ks
+}
+
+// Load loads the RS256 or EdDSA keys still verifying the tokens from the
+// database, a signing key is generated if there is none
+func (ks *KeySet) Load(db *gorm.DB) error {
+	if !ks.settings.Asymmetric() {
+		return nil
+	}
+
+	var rows []model.SigningKey
+	err := db.Where("algorithm = ? AND (rotated_at IS NULL OR rotated_at > ?)", ks.settings.Algorithm, time.Now().Add(-ks.settings.Timeout)).
+		Order("created_at asc").
+		Find(&rows).Error
+	if err != nil {
+		return err
+	}
+
+	keys := make(map[string]*Key)
+	var current *Key
+	for _, row := range rows {
+		key, err := ks.parseSigningKey(row)
+		if err != nil {
+			log.Printf("signing key %s error: %s", row.KID, err)
+			continue
+		}
+		keys[key.ID] = key
+		if key.RotatedAt == nil {
+			current = key
+		}
+	}
+
+	ks.mu.Lock()
+	ks.db, ks.keys, ks.current, ks.loadedAt = db, keys, current, time.Now()
+	ks.mu.Unlock()
+
+	if current == nil {
+		return ks.Rotate(db)
+	}
+
+	return nil
+}
+
+// Rotate replaces the RS256 or EdDSA signing key with a new key, the replaced
+// keys verify the tokens until they expire
+func (ks *KeySet) Rotate(db *gorm.DB) error {
+	if !ks.settings.Asymmetric() {
+		return nil
+	}
+
+	row, err := ks.generateSigningKey(ks.settings.Algorithm)
+	if err != nil {
+		return err
+	}
+	key, err := ks.parseSigningKey(row)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	err = db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&model.SigningKey{}).Where("rotated_at IS NULL AND algorithm = ?", row.Algorithm).Update("rotated_at", now).Error
+		if err != nil {
+			return err
+		}
+
+		return tx.Save(&row).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	for _, k := range ks.keys {
+		if k.RotatedAt == nil {
+			k.RotatedAt = &now
+		}
+	}
+	ks.keys[key.ID] = key
+	ks.current = key
+
+	return nil
+}
+
+// RotateIfDue reloads the keys, rotated by the other instances too, rotates
+// the signing key older than the rotation interval and deletes the expired
+// keys
+func (ks *KeySet) RotateIfDue(db *gorm.DB) error {
+	if !ks.settings.Asymmetric() {
+		return nil
+	}
+
+	if err := ks.Load(db); err != nil {
+		return err
+	}
+
+	ks.mu.RLock()
+	due := ks.current.CreatedAt.Add(ks.settings.RotationInterval).Before(time.Now())
+	ks.mu.RUnlock()
+	if due {
+		if err := ks.Rotate(db); err != nil {
+			return err
+		}
+	}
+
+	return db.Where("rotated_at < ?", time.Now().Add(-ks.settings.Timeout)).Delete(&model.SigningKey{}).Error
+}
+
+// Sign returns the token of the claims signed by the current key
+func (ks *KeySet) Sign(claims jwt.MapClaims) (string, error) {
+	ks.mu.RLock()
+	key := ks.current
+	ks.mu.RUnlock()
+	if key == nil {
+		return "", ErrUnknownKey
+	}
+
+	token := jwt.NewWithClaims(key.Method, claims)
+	token.Header["kid"] = key.ID
+
+	return token.SignedString(key.private)
+}
+
+// Parse verifies the token with the key of its kid and returns its claims.
+// The keys are reloaded for an unknown kid, the key could have been
+// generated by another instance.
+func (ks *KeySet) Parse(token string) (jwt.MapClaims, error) {
+	parsed, err := jwt.Parse(token, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+
+		key := ks.lookup(kid)
+		if key == nil {
+			return nil, ErrUnknownKey
+		}
+		if t.Method.Alg() != key.Method.Alg() {
+			return nil, fmt.Errorf("unexpected signing method %s", t.Method.Alg())
+		}
+
+		return key.public, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return parsed.Claims.(jwt.MapClaims), nil
+}
+
+// JWKS returns the RFC 7517 JSON Web Key Set of the public keys verifying the
+// tokens, empty with the shared HS256 key
+func (ks *KeySet) JWKS() map[string]interface{} {
+	ks.reload()
+
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	keys := []map[string]interface{}{}
+	for _, key := range ks.sortedKeys() {
+		jwk := map[string]interface{}{"kid": key.ID, "use": "sig", "alg": key.Method.Alg()}
+		switch public := key.public.(type) {
+		case *rsa.PublicKey:
+			jwk["kty"] = "RSA"
+			jwk["n"] = jwt.EncodeSegment(public.N.Bytes())
+			jwk["e"] = jwt.EncodeSegment(big.NewInt(int64(public.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk["kty"] = "OKP"
+			jwk["crv"] = "Ed25519"
+			jwk["x"] = jwt.EncodeSegment(public)
+		default:
+			continue
+		}
+		keys = append(keys, jwk)
+	}
+
+	return map[string]interface{}{"keys": keys}
+}
+
+// lookup returns the key of the kid, reloading the keys if the kid is
+// unknown
+func (ks *KeySet) lookup(kid string) *Key {
+	ks.mu.RLock()
+	key := ks.keys[kid]
+	ks.mu.RUnlock()
+
+	if key == nil && ks.reload() {
+		ks.mu.RLock()
+		key = ks.keys[kid]
+		ks.mu.RUnlock()
+	}
+
+	return key
+}
+
+// reload reloads the RS256 or EdDSA keys loaded more than KeyReloadInterval
+// ago, returns true if the keys have been reloaded
+func (ks *KeySet) reload() bool {
+	ks.mu.RLock()
+	db, stale := ks.db, ks.db != nil && time.Since(ks.loadedAt) > config.KeyReloadInterval
+	ks.mu.RUnlock()
+	if !stale {
+		return false
+	}
+
+	if err := ks.Load(db); err != nil {
+		log.Printf("signing keys load error: %s", err)
+		return false
+	}
+
+	return true
+}
+
+// sortedKeys returns the keys from the newest, the caller holds the lock
+func (ks *KeySet) sortedKeys() []*Key {
+	keys := make([]*Key, 0, len(ks.keys))
+	for _, key := range ks.keys {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].CreatedAt.After(keys[j].CreatedAt) })
+
+	return keys
+}
+
+// generateSigningKey generates a key of the algorithm, with the private key
+// encrypted
+func (ks *KeySet) generateSigningKey(algorithm string) (model.SigningKey, error) {
+	row := model.SigningKey{Algorithm: algorithm, CreatedAt: time.Now()}
+
+	var err error
+	row.KID, err = utils.GenerateRandomStringURLSafe(16)
+	if err != nil {
+		return row, err
+	}
+
+	var private []byte
+	switch algorithm {
+	case config.AlgorithmRS256:
+		key, err := rsa.GenerateKey(rand.Reader, rsaKeySize)
+		if err != nil {
+			return row, err
+		}
+		private = x509.MarshalPKCS1PrivateKey(key)
+	case config.AlgorithmEdDSA:
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return row, err
+		}
+		private = key
+	default:
+		return row, fmt.Errorf("unsupported signing algorithm %s", algorithm)
+	}
+
+	nonce, err := utils.GenerateRandomBytes(ks.aead.NonceSize())
+	if err != nil {
+		return row, err
+	}
+	// the kid and the algorithm authenticate the encrypted key, it can not
+	// be moved to another row
+	row.PrivateKey = ks.aead.Seal(nonce, nonce, private, []byte(row.KID+row.Algorithm))
+
+	return row, nil
+}
+
+// parseSigningKey decrypts the private key of the row of the database and
+// returns its key
+func (ks *KeySet) parseSigningKey(row model.SigningKey) (*Key, error) {
+	key := &Key{ID: row.KID, CreatedAt: row.CreatedAt, RotatedAt: row.RotatedAt}
+
+	size := ks.aead.NonceSize()
+	if len(row.PrivateKey) < size {
+		return nil, errors.New("invalid encrypted private key")
+	}
+	private, err := ks.aead.Open(nil, row.PrivateKey[:size], row.PrivateKey[size:], []byte(row.KID+row.Algorithm))
+	if err != nil {
+		return nil, fmt.Errorf("private key decryption: %s", err)
+	}
+
+	switch row.Algorithm {
+	case config.AlgorithmRS256:
+		rsaKey, err := x509.ParsePKCS1PrivateKey(private)
+		if err != nil {
+			return nil, err
+		}
+		key.Method, key.private, key.public = jwt.SigningMethodRS256, rsaKey, &rsaKey.PublicKey
+	case config.AlgorithmEdDSA:
+		if len(private) != ed25519.PrivateKeySize {
+			return nil, errors.New("invalid Ed25519 private key")
+		}
+		edKey := ed25519.PrivateKey(private)
+		key.Method, key.private, key.public = jwt.SigningMethodEdDSA, edKey, edKey.Public()
+	default:
+		return nil, fmt.Errorf("unsupported signing algorithm %s", row.Algorithm)
+	}
+
+	return key, nil
+}
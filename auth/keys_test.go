@@ -0,0 +1,138 @@
+package auth
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"testing"
+	"time"
+
+	"github.com/giuliobosco/todoAPI/config"
+
+	jwt "github.com/golang-jwt/jwt/v4"
+	mocket "github.com/selvatico/go-mocket"
+	"github.com/stretchr/testify/assert"
+)
+
+func testKeySet(t *testing.T, algorithm string) *KeySet {
+	config.TestInit()
+	mocket.Catcher.Reset()
+
+	settings := config.DefaultSettings().JWT
+	settings.Algorithm = algorithm
+	settings.Key = "test_key_of_the_api_engine_8F6E2P"
+	settings.EncryptionKey = "test_encryption_key_of_the_engine"
+
+	ks := NewKeySet(settings)
+	assert.NoError(t, ks.Load(config.GetDB()))
+
+	return ks
+}
+
+func TestKeySetRotation(t *testing.T) {
+	for _, algorithm := range []string{config.AlgorithmRS256, config.AlgorithmEdDSA} {
+		ks := testKeySet(t, algorithm)
+		claims := jwt.MapClaims{config.IdentityKey: 1, "exp": time.Now().Add(time.Minute).Unix()}
+
+		old, err := ks.Sign(claims)
+		assert.NoError(t, err)
+		assert.NoError(t, ks.Rotate(config.GetDB()))
+		token, err := ks.Sign(claims)
+		assert.NoError(t, err)
+
+		// the tokens of the replaced key are still verified
+		for _, tok := range []string{old, token} {
+			parsed, err := ks.Parse(tok)
+			assert.NoError(t, err, algorithm)
+			assert.Equal(t, float64(1), parsed[config.IdentityKey])
+		}
+
+		parsedOld, _ := jwt.Parse(old, nil)
+		parsedNew, _ := jwt.Parse(token, nil)
+		assert.Equal(t, algorithm, parsedNew.Method.Alg())
+		assert.NotEqual(t, parsedOld.Header["kid"], parsedNew.Header["kid"])
+
+		jwks := ks.JWKS()["keys"].([]map[string]interface{})
+		assert.Len(t, jwks, 2)
+		assert.Equal(t, parsedNew.Header["kid"], jwks[0]["kid"])
+		assert.Equal(t, algorithm, jwks[0]["alg"])
+		assert.NotContains(t, jwks[0], "d")
+	}
+}
+
+func TestKeySetRejectsTokens(t *testing.T) {
+	ks := testKeySet(t, config.AlgorithmEdDSA)
+	other := testKeySet(t, config.AlgorithmEdDSA)
+
+	token, err := other.Sign(jwt.MapClaims{"exp": time.Now().Add(time.Minute).Unix()})
+	assert.NoError(t, err)
+	_, err = ks.Parse(token)
+	assert.Error(t, err)
+
+	token, err = ks.Sign(jwt.MapClaims{"exp": time.Now().Add(-time.Minute).Unix()})
+	assert.NoError(t, err)
+	_, err = ks.Parse(token)
+	assert.Error(t, err)
+
+	// a token of the shared key is refused even with the kid of a key
+	hs := testKeySet(t, config.AlgorithmHS256)
+	unsigned := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"exp": time.Now().Add(time.Minute).Unix()})
+	unsigned.Header["kid"] = ks.current.ID
+	token, err = unsigned.SignedString([]byte("test_key_of_the_api_engine_8F6E2P"))
+	assert.NoError(t, err)
+	_, err = ks.Parse(token)
+	assert.Error(t, err)
+
+	assert.Empty(t, hs.JWKS()["keys"])
+}
+
+func TestSigningKeyEncryption(t *testing.T) {
+	for _, algorithm := range []string{config.AlgorithmRS256, config.AlgorithmEdDSA} {
+		ks := testKeySet(t, algorithm)
+
+		row, err := ks.generateSigningKey(algorithm)
+		assert.NoError(t, err)
+		key, err := ks.parseSigningKey(row)
+		assert.NoError(t, err, algorithm)
+
+		var private []byte
+		switch k := key.private.(type) {
+		case *rsa.PrivateKey:
+			private = x509.MarshalPKCS1PrivateKey(k)
+		case ed25519.PrivateKey:
+			private = k
+		}
+		assert.NotEmpty(t, private)
+		assert.False(t, bytes.Contains(row.PrivateKey, private), algorithm)
+
+		// another encryption key or kid does not decrypt the key
+		settings := ks.settings
+		settings.EncryptionKey = "another_encryption_key_of_engine!"
+		_, err = NewKeySet(settings).parseSigningKey(row)
+		assert.Error(t, err)
+		row.KID = "other"
+		_, err = ks.parseSigningKey(row)
+		assert.Error(t, err)
+	}
+}
+
+func TestKeysFollowSettings(t *testing.T) {
+	config.TestInit()
+	defer config.TestInit()
+
+	ks, err := Keys(config.GetDB())
+	assert.NoError(t, err)
+	same, err := Keys(config.GetDB())
+	assert.NoError(t, err)
+	assert.True(t, ks == same)
+
+	t.Setenv("DB_DSN", "host=db")
+	t.Setenv("JWT_KEY", "another_key_of_the_api_engine_0123")
+	_, err = config.Load()
+	assert.NoError(t, err)
+
+	other, err := Keys(config.GetDB())
+	assert.NoError(t, err)
+	assert.NotEqual(t, ks.current.ID, other.current.ID)
+}
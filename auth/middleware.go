@@ -0,0 +1,309 @@
+package auth
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/giuliobosco/todoAPI/config"
+	"github.com/giuliobosco/todoAPI/model"
+	"github.com/giuliobosco/todoAPI/utils"
+
+	"github.com/gin-gonic/gin"
+	jwt "github.com/golang-jwt/jwt/v4"
+	"github.com/jinzhu/gorm"
+)
+
+// payloadKey is the key of the claims of the request in the gin context
+const payloadKey = "JWT_PAYLOAD"
+
+var (
+	// ErrMissingLoginValues is the error of a login without email or password
+	ErrMissingLoginValues = errors.New(config.SMissingLoginValues)
+	// ErrFailedAuthentication is the error of a login with wrong email or
+	// password
+	ErrFailedAuthentication = errors.New(config.SFailedAuthentication)
+)
+
+// accessTokenResources are the endpoints accepting the personal access
+// tokens, by path prefix, with the scopes reading and writing them. The other
+// endpoints, like the sessions and the access tokens, require a login.
+var accessTokenResources = []struct {
+	path  string
+	read  string
+	write string
+}{
+	{"/v1/todo", model.ScopeTasksRead, model.ScopeTasksWrite},
+	{"/v1/projects", model.ScopeTasksRead, model.ScopeTasksWrite},
+	{"/v1/tags", model.ScopeTasksRead, model.ScopeTasksWrite},
+	{"/v1/workspaces", model.ScopeTasksRead, model.ScopeTasksWrite},
+	{"/v1/shared", model.ScopeTasksRead, model.ScopeTasksWrite},
+	{"/v1/shares", model.ScopeTasksRead, model.ScopeTasksWrite},
+	{"/v1/joinWorkspace", model.ScopeTasksRead, model.ScopeTasksWrite},
+	{"/v1/user", model.ScopeProfileRead, model.ScopeProfileWrite},
+	{"/v1/updateUser", model.ScopeProfileRead, model.ScopeProfileWrite},
+}
+
+var (
+	keysMu sync.Mutex
+	keys   *KeySet
+)
+
+// Middleware authenticates the requests with the access tokens of the
+// Authorization header (Bearer) or of the token query parameter, or with the
+// personal access tokens of the Authorization header
+type Middleware struct {
+	Realm   string        // realm of the WWW-Authenticate header
+	Keys    *KeySet       // keys signing and verifying the tokens, nil for the keys of the current configuration
+	Timeout time.Duration // validity of the tokens
+}
+
+// Keys returns the keys of the access tokens of the current configuration,
+// shared by the middleware and the rotation job, loaded from db the first
+// time and again when the configuration changes
+func Keys(db *gorm.DB) (*KeySet, error) {
+	keysMu.Lock()
+	defer keysMu.Unlock()
+
+	settings := config.Get().JWT
+	if keys == nil || keys.settings != settings {
+		ks := NewKeySet(settings)
+		if err := ks.Load(db); err != nil {
+			return nil, err
+		}
+		keys = ks
+	}
+
+	return keys, nil
+}
+
+// StartKeyRotation starts a background job checking every interval the
+// rotation of the signing keys. The returned function stops the job.
+func StartKeyRotation(db *gorm.DB, interval time.Duration) (func(), error) {
+	if _, err := Keys(db); err != nil {
+		return nil, err
+	}
+
+	ticker := time.NewTicker(interval)
+	done := make(chan struct{})
+
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+			case <-done:
+				ticker.Stop()
+				return
+			}
+
+			ks, err := Keys(db)
+			if err == nil {
+				err = ks.RotateIfDue(db)
+			}
+			if err != nil {
+				log.Printf("key rotation error: %s", err)
+			}
+		}
+	}()
+
+	return func() { close(done) }, nil
+}
+
+// ExtractClaims returns the claims of the access token of the request
+func ExtractClaims(c *gin.Context) jwt.MapClaims {
+	claims, ok := c.Get(payloadKey)
+	if !ok {
+		return jwt.MapClaims{}
+	}
+
+	return claims.(jwt.MapClaims)
+}
+
+// LoginHandler authenticates the user and responds with the access token.
+// The users with the two-factor authentication receive instead the challenge
+// of the second step of the login, see TwoFactorLoginHandler.
+func (mw *Middleware) LoginHandler(c *gin.Context) {
+	user, err := authenticator(c)
+	if err != nil {
+		mw.unauthorized(c, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	if user.TOTPEnabled {
+		if utils.TwoFactorLocked(*user) {
+			mw.unauthorized(c, http.StatusTooManyRequests, config.STwoFactorLocked)
+			return
+		}
+		challenge, expire, err := utils.CreateLoginChallenge(config.GetDB(), user.ID)
+		if err != nil {
+			mw.unauthorized(c, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{config.STwoFactorRequired: true, config.SChallenge: challenge, config.SExpire: expire})
+		return
+	}
+
+	mw.login(c, user)
+}
+
+// login opens a session of the authenticated user and responds with its
+// access token
+func (mw *Middleware) login(c *gin.Context, user *model.User) {
+	session, err := startSession(c, user)
+	if err != nil {
+		mw.unauthorized(c, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	token, expire, err := mw.TokenGenerator(session)
+	if err != nil {
+		mw.unauthorized(c, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	loginResponse(c, http.StatusOK, token, expire)
+}
+
+// MiddlewareFunc returns the handler authorizing the requests, the claims of
+// the token are returned by ExtractClaims
+func (mw *Middleware) MiddlewareFunc() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := tokenFromRequest(c)
+		if len(token) == 0 {
+			mw.unauthorized(c, http.StatusUnauthorized, config.SMissingToken)
+			return
+		}
+
+		var claims jwt.MapClaims
+		if strings.HasPrefix(token, config.AccessTokenPrefix) {
+			accessToken, ok := utils.FindAccessToken(config.GetDB(), token)
+			if !ok {
+				mw.unauthorized(c, http.StatusUnauthorized, config.SInvalidToken)
+				return
+			}
+			scope, ok := accessTokenScope(c.Request.Method, c.Request.URL.Path)
+			if !ok || !utils.HasScope(accessToken.Scopes, scope) {
+				mw.unauthorized(c, http.StatusForbidden, config.SInsufficientScope)
+				return
+			}
+			if err := utils.TouchAccessToken(config.GetDB(), accessToken); err != nil {
+				log.Printf("access token %d error: %s", accessToken.ID, err)
+			}
+
+			claims = jwt.MapClaims{config.IdentityKey: accessToken.UserID, config.AccessTokenKey: accessToken.ID}
+		} else {
+			ks, err := mw.keySet()
+			if err == nil {
+				claims, err = ks.Parse(token)
+			}
+			if err != nil {
+				mw.unauthorized(c, http.StatusUnauthorized, config.SInvalidToken)
+				return
+			}
+			// the expiration is checked by Parse only if present
+			if _, ok := claims["exp"].(float64); !ok {
+				mw.unauthorized(c, http.StatusUnauthorized, config.SInvalidToken)
+				return
+			}
+		}
+
+		c.Set(payloadKey, claims)
+		identity := identityHandler(c)
+		c.Set(config.IdentityKey, identity)
+
+		if !authorizator(identity, c) {
+			mw.unauthorized(c, http.StatusForbidden, config.SForbidden)
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// TokenGenerator returns an access token of the data and its expiration
+func (mw *Middleware) TokenGenerator(data interface{}) (string, time.Time, error) {
+	now := time.Now()
+	expire := now.UTC().Add(mw.Timeout)
+
+	claims := payload(data)
+	claims["exp"] = expire.Unix()
+	claims["orig_iat"] = now.Unix()
+
+	ks, err := mw.keySet()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	token, err := ks.Sign(claims)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	return token, expire, nil
+}
+
+// JWKSHandler responds with the public keys verifying the access tokens, for
+// the services verifying them without the shared key
+func (mw *Middleware) JWKSHandler(c *gin.Context) {
+	ks, err := mw.keySet()
+	if err != nil {
+		unauthorized(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, ks.JWKS())
+}
+
+// keySet returns the keys of the middleware, or the keys of the current
+// configuration
+func (mw *Middleware) keySet() (*KeySet, error) {
+	if mw.Keys != nil {
+		return mw.Keys, nil
+	}
+
+	return Keys(config.GetDB())
+}
+
+// unauthorized aborts the request with the message
+func (mw *Middleware) unauthorized(c *gin.Context, code int, message string) {
+	c.Header("WWW-Authenticate", "JWT realm="+mw.Realm)
+	c.Abort()
+
+	unauthorized(c, code, message)
+}
+
+// accessTokenScope returns the scope of the personal access tokens required by
+// the request, false if the endpoint does not accept them
+func accessTokenScope(method string, path string) (string, bool) {
+	for _, r := range accessTokenResources {
+		if path != r.path && !strings.HasPrefix(path, r.path+"/") {
+			continue
+		}
+		if method == http.MethodGet || method == http.MethodHead {
+			return r.read, true
+		}
+
+		return r.write, true
+	}
+
+	return "", false
+}
+
+// tokenFromRequest returns the token of the Authorization header or of the
+// token query parameter. The personal access tokens are long-lived, they are
+// only accepted from the header to keep them out of the access logs.
+func tokenFromRequest(c *gin.Context) string {
+	parts := strings.SplitN(c.GetHeader("Authorization"), " ", 2)
+	if len(parts) == 2 && parts[0] == "Bearer" {
+		return parts[1]
+	}
+
+	if token := c.Query("token"); !strings.HasPrefix(token, config.AccessTokenPrefix) {
+		return token
+	}
+
+	return ""
+}
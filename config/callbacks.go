@@ -0,0 +1,34 @@
+package config
+
+import (
+	"github.com/jinzhu/gorm"
+)
+
+// registerCallbacks registers the gorm callbacks of the API Engine on the
+// connection, the callbacks are not shared by the connections
+func registerCallbacks(db *gorm.DB) {
+	db.Callback().Create().Before("gorm:create").Register("todo:init_version", initVersionCallback)
+	db.Callback().Update().Before("gorm:update").Register("todo:increment_version", incrementVersionCallback)
+}
+
+// initVersionCallback sets the version of the new objects to 1
+func initVersionCallback(scope *gorm.Scope) {
+	if field, ok := scope.FieldByName("Version"); ok && field.IsBlank {
+		scope.Err(field.Set(1))
+	}
+}
+
+// incrementVersionCallback increments the version of the updated objects
+func incrementVersionCallback(scope *gorm.Scope) {
+	field, ok := scope.FieldByName("Version")
+	if !ok {
+		return
+	}
+
+	if attrs, ok := scope.InstanceGet("gorm:update_attrs"); ok {
+		attrs.(map[string]interface{})[field.DBName] = gorm.Expr(field.DBName + " + 1")
+		return
+	}
+
+	scope.Err(field.Set(field.Field.Uint() + 1))
+}
@@ -0,0 +1,16 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegisterCallbacks(t *testing.T) {
+	// each connection has its own callbacks
+	for i := 0; i < 2; i++ {
+		db := TestInit()
+		assert.NotNil(t, db.Callback().Create().Get("todo:init_version"))
+		assert.NotNil(t, db.Callback().Update().Get("todo:increment_version"))
+	}
+}
@@ -11,14 +11,19 @@ import (
 // DB is the gorm connection to the postgress database
 var DB *gorm.DB
 
-// Init initialize the connection to the database.
+// Init initialize the connection to the database of the configuration.
 func Init() *gorm.DB {
-	db, err := gorm.Open("postgres", "host=postgrestodo port=5432 user=admin dbname=tododb password=123  sslmode=disable")
+	database := Get().Database
+	db, err := gorm.Open("postgres", string(database.DSN))
 
 	if err != nil {
 		panic(err.Error())
 	}
+	db.DB().SetMaxOpenConns(database.MaxOpenConns)
+	db.DB().SetMaxIdleConns(database.MaxIdleConns)
+	db.DB().SetConnMaxLifetime(database.ConnMaxLifetime)
 
+	registerCallbacks(db)
 	DB = db
 	return DB
 }
@@ -28,12 +33,17 @@ func GetDB() *gorm.DB {
 	return DB
 }
 
-// TestInit initialize the connection to the mock database driver for tests
+// TestInit initialize the connection to the mock database driver and the
+// configuration for tests
 func TestInit() *gorm.DB {
+	settings = DefaultSettings()
+	settings.JWT.Key = "test_key_of_the_api_engine_8F6E2P"
+
 	mocket.Catcher.Register()
 	mocket.Catcher.Logging = true
 
 	db, _ := gorm.Open(mocket.DriverName, "connection_string")
+	registerCallbacks(db)
 	DB = db
 
 	return DB
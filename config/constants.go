@@ -1,23 +1,84 @@
 package config
 
 import (
-	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/giuliobosco/todoAPI/model"
 )
 
-var (
-	// URL application url
-	URL = os.Getenv("URL")
-)
+// AttachmentContentTypes are the allowed content types of the attachments
+var AttachmentContentTypes = []string{"image/png", "image/jpeg", "image/gif", "image/webp", "application/pdf", "text/plain"}
 
 const (
 	// TokenLength is the length of the user activation token
 	TokenLength = 64
+	// TagNameLength is the maximum length of the name of a tag
+	TagNameLength = 64
+	// CommentBodyLength is the maximum length of the body of a comment
+	CommentBodyLength = 10000
+	// ProjectDeleteInbox moves the tasks of a deleted project to the inbox
+	ProjectDeleteInbox = "inbox"
+	// ProjectDeleteCascade deletes the tasks of a deleted project
+	ProjectDeleteCascade = "cascade"
+	// MaxTaskDepth is the maximum number of levels of a task hierarchy
+	MaxTaskDepth = 5
+	// ChildrenBlock blocks the completion of a task with incomplete subtasks
+	ChildrenBlock = "block"
+	// ChildrenComplete completes the incomplete subtasks of a completed task
+	ChildrenComplete = "complete"
+	// MergePatchContentType is the content type of the RFC 7396 JSON Merge Patch
+	MergePatchContentType = "application/merge-patch+json"
+	// JSONPatchContentType is the content type of the RFC 6902 JSON Patch
+	JSONPatchContentType = "application/json-patch+json"
+	// MaxBatchOperations is the maximum number of operations of a batch
+	MaxBatchOperations = 100
+	// WorkspaceHeader is the header selecting the active workspace
+	WorkspaceHeader = "X-Workspace-ID"
+	// InvitationValidity is the time an invitation in a workspace can be accepted
+	InvitationValidity = 7 * 24 * time.Hour
+	// TOTPIssuer is the issuer of the TOTP secrets, shown by the
+	// authenticator apps
+	TOTPIssuer = "todoAPI"
+	// TOTPSecretLength is the length in bytes of the TOTP secrets
+	TOTPSecretLength = 20
+	// TOTPDigits is the number of digits of the TOTP codes
+	TOTPDigits = 6
+	// TOTPPeriod is the validity of a TOTP code
+	TOTPPeriod = 30 * time.Second
+	// RecoveryCodeCount is the number of recovery codes of a user
+	RecoveryCodeCount = 10
+	// LoginChallengeValidity is the time the second step of a login can be
+	// completed
+	LoginChallengeValidity = 5 * time.Minute
+	// LoginChallengeAttempts is the maximum number of codes tried for a login
+	LoginChallengeAttempts = 5
+	// TwoFactorFailures is the number of wrong codes of a user, across the
+	// login challenges, locking the second step of the login
+	TwoFactorFailures = 10
+	// TwoFactorLockout is the time the second step of the login of a user is
+	// locked after TwoFactorFailures wrong codes
+	TwoFactorLockout = 15 * time.Minute
+	// KeyRotationCheckInterval is the interval between the checks of the
+	// rotation of the signing keys
+	KeyRotationCheckInterval = time.Hour
+	// KeyReloadInterval is the minimum interval between the reloads of the
+	// signing keys for the tokens with an unknown kid
+	KeyReloadInterval = time.Minute
 	// IdentityKey represent the parameter used as connection key.
 	IdentityKey = "id"
-	// Key is the internal secret key of the API Engine.
-	Key = "my_secret_key_8F6E2P"
+	// SessionKey is the claim of the access tokens with the id of the session
+	SessionKey = "sid"
+	// AccessTokenKey is the claim of the requests authenticated by a personal
+	// access token with the id of the token
+	AccessTokenKey = "pat"
+	// AccessTokenPrefix is the prefix of the personal access tokens,
+	// distinguishing them from the JWTs
+	AccessTokenPrefix = "tdp_"
+	// AccessTokenNameLength is the maximum length of the name of a personal
+	// access token
+	AccessTokenNameLength = 64
 	// SWelcome is the welcome string
 	SWelcome = "Welcome to my Todo App"
 	// SUserExists is the user already exists string
@@ -68,6 +129,221 @@ const (
 	STaskUpdated = "Task updated successfully!"
 	// STaskDeleted is the task delted string
 	STaskDeleted = "Task deleted successfully!"
+	// STagCreated is the tag created string
+	STagCreated = "Tag created successfully!"
+	// STagNotFound is the tag not found string
+	STagNotFound = "Tag not found"
+	// STagInvalid is the invalid tag id string
+	STagInvalid = "Invalid tag id"
+	// STagExists is the tag already exists string
+	STagExists = "Tag already exists"
+	// STagUpdated is the tag updated string
+	STagUpdated = "Tag updated successfully!"
+	// STagDeleted is the tag deleted string
+	STagDeleted = "Tag deleted successfully!"
+	// STagAttached is the tag attached to the task string
+	STagAttached = "Tag added to the task"
+	// STagDetached is the tag detached from the task string
+	STagDetached = "Tag removed from the task"
+	// STagNameTooLong is the tag name too long string
+	STagNameTooLong = "Tag name too long"
+	// STag is the tag string
+	STag = "tag"
+	// SProjectCreated is the project created string
+	SProjectCreated = "Project created successfully!"
+	// SProjectNotFound is the project not found string
+	SProjectNotFound = "Project not found"
+	// SProjectInvalid is the invalid project id string
+	SProjectInvalid = "Invalid project id"
+	// SProjectUpdated is the project updated string
+	SProjectUpdated = "Project updated successfully!"
+	// SProjectDeleted is the project deleted string
+	SProjectDeleted = "Project deleted successfully!"
+	// SProjectInvalidDeleteMode is the invalid project tasks delete mode string
+	SProjectInvalidDeleteMode = "Invalid tasks mode, use inbox or cascade"
+	// SProject is the project string
+	SProject = "project"
+	// STaskInvalidParent is the invalid parent task string
+	STaskInvalidParent = "Invalid parent task"
+	// STaskParentCycle is the task parent cycle string
+	STaskParentCycle = "A task cannot be a subtask of itself or of its subtasks"
+	// STaskTooDeep is the task hierarchy too deep string
+	STaskTooDeep = "Too many levels of subtasks"
+	// STaskIncompleteChildren is the task with incomplete subtasks string
+	STaskIncompleteChildren = "Task has incomplete subtasks, use children=complete to complete them"
+	// STaskInvalidChildrenMode is the invalid subtasks completion mode string
+	STaskInvalidChildrenMode = "Invalid children mode, use block or complete"
+	// SInvalidRecurrence is the invalid recurrence rule string
+	SInvalidRecurrence = "Invalid recurrence rule"
+	// STaskRecurrenceNeedsDue is the recurrent task without due date string
+	STaskRecurrenceNeedsDue = "Recurrent tasks need a due date"
+	// SOccurrences is the occurrences string
+	SOccurrences = "occurrences"
+	// SNext is the next string
+	SNext = "next"
+	// SInvalidPatch is the invalid patch document string
+	SInvalidPatch = "Invalid patch document"
+	// SInvalidPatchField is the field not modifiable by a patch string
+	SInvalidPatchField = "Invalid patch field"
+	// SInvalidPatchOperation is the unsupported patch operation string
+	SInvalidPatchOperation = "Unsupported patch operation"
+	// SPatchTestFailed is the failed patch test operation string
+	SPatchTestFailed = "Patch test failed"
+	// SUnsupportedMediaType is the unsupported content type string
+	SUnsupportedMediaType = "Unsupported content type"
+	// STaskInvalidDates is the task due date before start date string
+	STaskInvalidDates = "Task due date is before start date"
+	// STaskInvalidPriority is the invalid task priority string
+	STaskInvalidPriority = "Invalid task priority"
+	// SInvalidSort is the invalid sort field string
+	SInvalidSort = "Invalid sort field"
+	// SInvalidFilter is the invalid filter string
+	SInvalidFilter = "Invalid filter"
+	// SInvalidCursor is the invalid pagination cursor string
+	SInvalidCursor = "Invalid cursor"
+	// SInvalidLimit is the invalid page limit string
+	SInvalidLimit = "Invalid limit"
+	// SCursorRequiresKeyset is the cursor used on a custom sorted listing string
+	SCursorRequiresKeyset = "Cursors are only available when sorting by created_at, use offset"
+	// SOffsetRequiresSort is the offset used on a listing sorted by created_at
+	// string
+	SOffsetRequiresSort = "Offsets are only available when sorting by other fields, use the cursors"
+	// SInvalidOffset is the invalid page offset string
+	SInvalidOffset = "Invalid offset"
+	// SPagination is the pagination string
+	SPagination = "pagination"
+	// SInvalidDateRange is the invalid date range string
+	SInvalidDateRange = "Invalid date range"
+	// SInvalidTimezone is the invalid timezone string
+	SInvalidTimezone = "Invalid timezone"
+	// SPreconditionFailed is the object modified since the If-Match version string
+	SPreconditionFailed = "The object has been modified, fetch it again"
+	// STaskRestored is the task restored string
+	STaskRestored = "Task restored successfully!"
+	// STaskPurged is the task permanently deleted string
+	STaskPurged = "Task permanently deleted"
+	// SBatchInvalidMode is the invalid batch mode string
+	SBatchInvalidMode = "Invalid mode, use atomic or best_effort"
+	// SBatchInvalidSize is the invalid number of batch operations string
+	SBatchInvalidSize = "A batch must have from 1 to 100 operations"
+	// SBatchInvalidOperation is the invalid batch operation string
+	SBatchInvalidOperation = "Invalid operation"
+	// SBatchRolledBack is the batch rolled back string
+	SBatchRolledBack = "Operation failed, batch rolled back"
+	// SBatchOperationRolledBack is the batch operation rolled back by a later failed operation string
+	SBatchOperationRolledBack = "Rolled back by a failed operation"
+	// STaskReverted is the task reverted string
+	STaskReverted = "Task reverted successfully!"
+	// SRevisionNotFound is the task revision not found string
+	SRevisionNotFound = "Revision not found"
+	// SComment is the comment string
+	SComment = "comment"
+	// SCommentCreated is the comment created string
+	SCommentCreated = "Comment created successfully!"
+	// SCommentUpdated is the comment updated string
+	SCommentUpdated = "Comment updated successfully!"
+	// SCommentDeleted is the comment deleted string
+	SCommentDeleted = "Comment deleted successfully!"
+	// SCommentNotFound is the comment not found string
+	SCommentNotFound = "Comment not found"
+	// SCommentTooLong is the comment body too long string
+	SCommentTooLong = "Comment too long"
+	// SInvalidOrder is the invalid listing order string
+	SInvalidOrder = "Invalid order, use asc or desc"
+	// SAttachment is the attachment string
+	SAttachment = "attachment"
+	// SAttachmentCreated is the attachment uploaded string
+	SAttachmentCreated = "Attachment uploaded successfully!"
+	// SAttachmentDeleted is the attachment deleted string
+	SAttachmentDeleted = "Attachment deleted successfully!"
+	// SAttachmentNotFound is the attachment not found string
+	SAttachmentNotFound = "Attachment not found"
+	// SAttachmentTooLarge is the attachment too large string
+	SAttachmentTooLarge = "Attachment too large"
+	// SAttachmentType is the attachment content type not allowed string
+	SAttachmentType = "Attachment type not allowed"
+	// SAttachmentQuota is the attachment quota exceeded string
+	SAttachmentQuota = "Attachment quota exceeded"
+	// SQuota is the quota string
+	SQuota = "quota"
+	// SShare is the share string
+	SShare = "share"
+	// SShared is the resource shared string
+	SShared = "Shared successfully!"
+	// SShareRevoked is the share revoked string
+	SShareRevoked = "Share revoked successfully!"
+	// SShareNotFound is the share not found string
+	SShareNotFound = "Share not found"
+	// SShareInvalidRole is the invalid share role string
+	SShareInvalidRole = "Invalid role, use viewer or editor"
+	// SShareInvalidEmail is the share with an unknown email, or with the owner,
+	// string
+	SShareInvalidEmail = "Can not share with this email"
+	// SDependency is the dependency string
+	SDependency = "dependency"
+	// SDependencyAdded is the dependency added string
+	SDependencyAdded = "Blocker added successfully!"
+	// SDependencyRemoved is the dependency removed string
+	SDependencyRemoved = "Blocker removed successfully!"
+	// SDependencyNotFound is the dependency not found string
+	SDependencyNotFound = "Blocker not found"
+	// STaskInvalidBlocker is the invalid blocker task string
+	STaskInvalidBlocker = "Invalid blocker task"
+	// STaskDependencyCycle is the dependency creating a cycle string
+	STaskDependencyCycle = "The blocker depends on the task"
+	// STaskBlocked is the task with open blockers completed string
+	STaskBlocked = "Task blocked by open tasks, use force=true to complete it"
+	// SAssignee is the assignee string
+	SAssignee = "assignee"
+	// STaskAssigned is the task assigned string
+	STaskAssigned = "Task assigned successfully!"
+	// STaskUnassigned is the task unassigned string
+	STaskUnassigned = "Task unassigned successfully!"
+	// SAssigneeNotFound is the assignee not found string
+	SAssigneeNotFound = "Assignee not found"
+	// SAssigneeInvalidEmail is the assignment to an unknown email, or to a user
+	// without access to the task, string
+	SAssigneeInvalidEmail = "Can not assign the task to this email"
+	// SWorkspace is the workspace string
+	SWorkspace = "workspace"
+	// SWorkspaceCreated is the workspace created string
+	SWorkspaceCreated = "Workspace created successfully!"
+	// SWorkspaceUpdated is the workspace updated string
+	SWorkspaceUpdated = "Workspace updated successfully!"
+	// SWorkspaceDeleted is the workspace deleted string
+	SWorkspaceDeleted = "Workspace deleted successfully!"
+	// SWorkspaceNotFound is the workspace not found string
+	SWorkspaceNotFound = "Workspace not found"
+	// SWorkspaceForbidden is the role of the member not allowed string
+	SWorkspaceForbidden = "Your role in the workspace does not allow this operation"
+	// SWorkspaceInvalidRole is the invalid member role string
+	SWorkspaceInvalidRole = "Invalid role, use admin, member or guest"
+	// SWorkspaceOwnerRole is the role of the owner changed string
+	SWorkspaceOwnerRole = "The owner of the workspace can not be changed or removed"
+	// SWorkspaceJoined is the invitation accepted string
+	SWorkspaceJoined = "Workspace joined successfully!"
+	// SWorkspaceInvalidName is the workspace name with line breaks string
+	SWorkspaceInvalidName = "Invalid name, line breaks are not allowed"
+	// SWorkspaceAlreadyMember is the user already member string
+	SWorkspaceAlreadyMember = "Already a member of the workspace"
+	// SMember is the member string
+	SMember = "member"
+	// SMemberUpdated is the member updated string
+	SMemberUpdated = "Member updated successfully!"
+	// SMemberRemoved is the member removed string
+	SMemberRemoved = "Member removed successfully!"
+	// SMemberNotFound is the member not found string
+	SMemberNotFound = "Member not found"
+	// SInvitation is the invitation string
+	SInvitation = "invitation"
+	// SInvitationSent is the invitation sent string
+	SInvitationSent = "Invitation sent successfully!"
+	// SInvitationDeleted is the invitation deleted string
+	SInvitationDeleted = "Invitation deleted successfully!"
+	// SInvitationNotFound is the invitation not found string
+	SInvitationNotFound = "Invitation not found"
+	// SSearchQueryMissing is the missing search query string
+	SSearchQueryMissing = "Missing: q"
 	// SMessage is the message string
 	SMessage = "message"
 	// SError is the error string
@@ -80,10 +356,78 @@ const (
 	SExpire = "expire"
 	// SToken is the token string
 	SToken = "token"
+	// SRefreshToken is the refresh token string
+	SRefreshToken = "refresh_token"
+	// SRefreshExpire is the refresh token expire string
+	SRefreshExpire = "refresh_expire"
+	// SSessionNotFound is the session not found string
+	SSessionNotFound = "Session not found"
+	// SSessionRevoked is the session revoked string
+	SSessionRevoked = "Session revoked successfully!"
+	// SSessionsRevoked is the all sessions revoked string
+	SSessionsRevoked = "All sessions revoked successfully!"
+	// SLoggedOut is the logout string
+	SLoggedOut = "Logged out successfully!"
+	// SInvalidRefreshToken is the invalid or expired refresh token string
+	SInvalidRefreshToken = "Invalid or expired refresh token"
+	// SMissingLoginValues is the missing login values string
+	SMissingLoginValues = "missing Username or Password"
+	// SFailedAuthentication is the failed authentication string
+	SFailedAuthentication = "incorrect Username or Password"
+	// SMissingToken is the missing access token string
+	SMissingToken = "Missing access token"
+	// SInvalidToken is the invalid or expired access token string
+	SInvalidToken = "Invalid or expired access token"
+	// SForbidden is the forbidden access string
+	SForbidden = "you don't have permission to access this resource"
+	// SRefreshTokenReused is the refresh token reused string
+	SRefreshTokenReused = "Refresh token already used, the session has been revoked"
+	// SAccessToken is the personal access token string
+	SAccessToken = "access_token"
+	// SAccessTokenCreated is the personal access token created string
+	SAccessTokenCreated = "Access token created successfully, copy it now, it will not be shown again"
+	// SAccessTokenRevoked is the personal access token revoked string
+	SAccessTokenRevoked = "Access token revoked successfully!"
+	// SAccessTokenNotFound is the personal access token not found string
+	SAccessTokenNotFound = "Access token not found"
+	// SAccessTokenNameTooLong is the personal access token name too long string
+	SAccessTokenNameTooLong = "Access token name too long"
+	// SAccessTokenInvalidScopes is the personal access token invalid scopes string
+	SAccessTokenInvalidScopes = "Invalid scopes, allowed: tasks:read, tasks:write, profile:read, profile:write"
+	// SAccessTokenInvalidExpiry is the personal access token expiry in the past string
+	SAccessTokenInvalidExpiry = "The expiry of the access token must be in the future"
+	// STwoFactorRequired is the two-factor authentication required string
+	STwoFactorRequired = "two_factor_required"
+	// SChallenge is the login challenge string
+	SChallenge = "challenge"
+	// SRecoveryCodes is the recovery codes string
+	SRecoveryCodes = "recovery_codes"
+	// SInvalidChallenge is the invalid or expired login challenge string
+	SInvalidChallenge = "Invalid or expired login, login again"
+	// SInvalidTwoFactorCode is the invalid two-factor code string
+	SInvalidTwoFactorCode = "Invalid authentication code"
+	// STwoFactorLocked is the two-factor authentication locked after too many wrong codes string
+	STwoFactorLocked = "Too many invalid authentication codes, try again later"
+	// STwoFactorAlreadyEnabled is the two-factor authentication already enabled string
+	STwoFactorAlreadyEnabled = "Two-factor authentication already enabled"
+	// STwoFactorNotEnabled is the two-factor authentication not enabled string
+	STwoFactorNotEnabled = "Two-factor authentication not enabled"
+	// STwoFactorNotSetUp is the two-factor authentication not set up string
+	STwoFactorNotSetUp = "Two-factor authentication not set up, set it up first"
+	// STwoFactorEnabled is the two-factor authentication enabled string
+	STwoFactorEnabled = "Two-factor authentication enabled, store the recovery codes, they will not be shown again"
+	// STwoFactorDisabled is the two-factor authentication disabled string
+	STwoFactorDisabled = "Two-factor authentication disabled successfully!"
+	// SRecoveryCodesCreated is the recovery codes regenerated string
+	SRecoveryCodesCreated = "Recovery codes regenerated, the previous ones are no longer valid"
+	// SMissingPasswordCode is the missing password or code string
+	SMissingPasswordCode = "Missing: password, code"
+	// SInsufficientScope is the missing scope of the personal access token string
+	SInsufficientScope = "The access token does not grant access to this resource"
 )
 
 func BuildConfirmEmail(user model.User, smtpUsername string) []byte {
-	var link string = URL + "v1/confirm?email=" + user.Email + "&token=" + user.VerifyToken
+	var link string = Get().URL + "v1/confirm?email=" + user.Email + "&token=" + user.VerifyToken
 
 	return []byte("To: " + user.Email + "\r\n" +
 		"From: " + smtpUsername + "\r\n" +
@@ -97,7 +441,7 @@ func BuildConfirmEmail(user model.User, smtpUsername string) []byte {
 }
 
 func BuildPasswordRecovery(user model.User, smtpUsername string) []byte {
-	var link string = URL + "v1/executePasswordRecovery?email=" + user.Email + "&token=" + user.VerifyToken
+	var link string = Get().URL + "v1/executePasswordRecovery?email=" + user.Email + "&token=" + user.VerifyToken
 
 	return []byte("To: " + user.Email + "\r\n" +
 		"From: " + smtpUsername + "\r\n" +
@@ -109,3 +453,38 @@ func BuildPasswordRecovery(user model.User, smtpUsername string) []byte {
 		"Thanks for using todoAPI\r\n" +
 		"The todoAPI team\r\n")
 }
+
+func BuildWorkspaceInvitation(invitation model.WorkspaceInvitation, workspace model.Workspace, inviter model.User, smtpUsername string) []byte {
+	var link string = Get().URL + "v1/joinWorkspace?token=" + invitation.Token
+
+	return []byte("To: " + invitation.Email + "\r\n" +
+		"From: " + smtpUsername + "\r\n" +
+		"Subject: TodoAPI: invitation to the workspace " + mailHeader(workspace.Name) + "!\r\n" +
+		"\r\n" +
+		"Hi,\r\n\r\n" +
+		inviter.Firstname + " " + inviter.Lastname + " invited you as " + invitation.Role + " in the workspace " + workspace.Name + ".\r\n" +
+		"Sign in to todoAPI with this email address and join the workspace with the following link\r\n\r\n" +
+		link + "\r\n\r\n" +
+		"Thanks for using todoAPI\r\n" +
+		"The todoAPI team\r\n")
+}
+
+func BuildTaskAssignment(task model.Task, assignee model.User, assigner model.User, smtpUsername string) []byte {
+	var link string = Get().URL + "v1/todo/get/" + strconv.FormatUint(uint64(task.ID), 10)
+
+	return []byte("To: " + assignee.Email + "\r\n" +
+		"From: " + smtpUsername + "\r\n" +
+		"Subject: TodoAPI: " + mailHeader(task.Title) + " assigned to you!\r\n" +
+		"\r\n" +
+		"Hi " + assignee.Firstname + " " + assignee.Lastname + ",\r\n\r\n" +
+		assigner.Firstname + " " + assigner.Lastname + " assigned you the task " + task.Title + "\r\n\r\n" +
+		link + "\r\n\r\n" +
+		"Thanks for using todoAPI\r\n" +
+		"The todoAPI team\r\n")
+}
+
+// mailHeader returns the value of a mail header without line breaks, which
+// would add other headers to the mail
+func mailHeader(value string) string {
+	return strings.NewReplacer("\r", " ", "\n", " ").Replace(value)
+}
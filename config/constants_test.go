@@ -0,0 +1,39 @@
+package config
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/giuliobosco/todoAPI/model"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// mailHeaders returns the header lines of the mail
+func mailHeaders(mail []byte) []string {
+	return strings.Split(strings.SplitN(string(mail), "\r\n\r\n", 2)[0], "\r\n")
+}
+
+func TestBuildWorkspaceInvitation(t *testing.T) {
+	invitation := model.WorkspaceInvitation{Email: "bob@example.com", Token: "token", Role: model.RoleMember}
+	workspace := model.Workspace{Name: "Team\r\nBcc: victim@example.com"}
+
+	headers := mailHeaders(BuildWorkspaceInvitation(invitation, workspace, model.User{}, "todo@example.com"))
+	assert.Equal(t, []string{
+		"To: bob@example.com",
+		"From: todo@example.com",
+		"Subject: TodoAPI: invitation to the workspace Team  Bcc: victim@example.com!",
+	}, headers)
+}
+
+func TestBuildTaskAssignment(t *testing.T) {
+	task := model.Task{Title: "Report\nBcc: victim@example.com"}
+	assignee := model.User{Email: "bob@example.com"}
+
+	headers := mailHeaders(BuildTaskAssignment(task, assignee, model.User{}, "todo@example.com"))
+	assert.Equal(t, []string{
+		"To: bob@example.com",
+		"From: todo@example.com",
+		"Subject: TodoAPI: Report Bcc: victim@example.com assigned to you!",
+	}, headers)
+}
@@ -0,0 +1,380 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// MinKeyLength is the minimum length in bytes of the JWT signing key
+const MinKeyLength = 32
+
+// redacted replaces the secrets in the logs
+const redacted = "****"
+
+// Secret is a configuration value hidden in the logs
+type Secret string
+
+// String returns the redacted secret
+func (s Secret) String() string {
+	if len(s) == 0 {
+		return ""
+	}
+
+	return redacted
+}
+
+// DSN is a database connection string, its password is hidden in the logs
+type DSN string
+
+// dsnPassword matches the password of a key=value connection string
+var dsnPassword = regexp.MustCompile(`(password=)('[^']*'|\S+)`)
+
+// String returns the connection string without its password
+func (d DSN) String() string {
+	if u, err := url.Parse(string(d)); err == nil && len(u.Scheme) > 0 && u.User != nil {
+		if _, ok := u.User.Password(); ok {
+			u.User = url.UserPassword(u.User.Username(), redacted)
+		}
+		// the userinfo of the url is escaped
+		return strings.Replace(u.String(), "%2A%2A%2A%2A", redacted, 1)
+	}
+
+	return dsnPassword.ReplaceAllString(string(d), "${1}"+redacted)
+}
+
+// Settings is the configuration of the API Engine, loaded by Load from the
+// defaults, the optional YAML file of CONFIG_FILE and the environment
+// variables, each one overriding the previous ones
+type Settings struct {
+	URL         string             `yaml:"url"`         // public url of the application, with the trailing slash (URL)
+	Port        string             `yaml:"port"`        // port of the HTTP server (PORT)
+	Database    DatabaseSettings   `yaml:"database"`    // database connection
+	JWT         JWTSettings        `yaml:"jwt"`         // authentication tokens
+	SMTP        SMTPSettings       `yaml:"smtp"`        // mail server
+	Storage     StorageSettings    `yaml:"storage"`     // store of the attachments
+	Trash       TrashSettings      `yaml:"trash"`       // trash of the deleted tasks
+	Attachments AttachmentSettings `yaml:"attachments"` // limits of the attachments
+}
+
+// DatabaseSettings is the configuration of the database connection
+type DatabaseSettings struct {
+	DSN             DSN           `yaml:"dsn"`               // postgres connection string (DB_DSN)
+	MaxOpenConns    int           `yaml:"max_open_conns"`    // maximum number of open connections, 0 for unlimited (DB_MAX_OPEN_CONNS)
+	MaxIdleConns    int           `yaml:"max_idle_conns"`    // maximum number of idle connections (DB_MAX_IDLE_CONNS)
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime"` // maximum lifetime of a connection, 0 for unlimited (DB_CONN_MAX_LIFETIME)
+}
+
+// Signing algorithms of the access tokens
+const (
+	AlgorithmHS256 = "HS256" // HMAC with the shared key of the configuration
+	AlgorithmRS256 = "RS256" // RSA with the rotated keys of the database
+	AlgorithmEdDSA = "EdDSA" // Ed25519 with the rotated keys of the database
+)
+
+// JWTSettings is the configuration of the authentication tokens
+type JWTSettings struct {
+	Algorithm        string        `yaml:"algorithm"`         // signing algorithm of the tokens (JWT_ALGORITHM)
+	Key              Secret        `yaml:"key"`               // signing key of the HS256 tokens (JWT_KEY)
+	EncryptionKey    Secret        `yaml:"encryption_key"`    // key encrypting the RS256 and EdDSA private keys in the database (JWT_ENCRYPTION_KEY)
+	RotationInterval time.Duration `yaml:"rotation_interval"` // lifetime of the RS256 and EdDSA signing keys (JWT_ROTATION_INTERVAL)
+	Timeout          time.Duration `yaml:"timeout"`           // validity of the access tokens (JWT_TIMEOUT)
+	RefreshTimeout   time.Duration `yaml:"refresh_timeout"`   // validity of the sessions since their last refresh (JWT_REFRESH_TIMEOUT)
+}
+
+// Asymmetric returns true if the tokens are signed with the rotated key pairs
+// of the database instead of the shared key
+func (j JWTSettings) Asymmetric() bool {
+	return j.Algorithm == AlgorithmRS256 || j.Algorithm == AlgorithmEdDSA
+}
+
+// SMTPSettings is the configuration of the mail server
+type SMTPSettings struct {
+	Server   string `yaml:"server"`   // host of the mail server (SMTP_SERVER)
+	Port     string `yaml:"port"`     // port of the mail server (SMTP_PORT)
+	Username string `yaml:"username"` // username and sender address (SMTP_USERNAME)
+	Password Secret `yaml:"password"` // password (SMTP_PASSWORD)
+}
+
+// Storage backends of the attachments
+const (
+	StorageLocal = "local" // directory of the local file system
+	StorageS3    = "s3"    // bucket of an S3 compatible service
+)
+
+// StorageSettings is the configuration of the store of the attachments
+type StorageSettings struct {
+	Backend string     `yaml:"backend"` // local or s3 (STORAGE)
+	Path    string     `yaml:"path"`    // directory of the local store (STORAGE_PATH)
+	S3      S3Settings `yaml:"s3"`      // S3 compatible service of the s3 store
+}
+
+// S3Settings is the configuration of the S3 compatible service storing the
+// attachments
+type S3Settings struct {
+	Endpoint  string `yaml:"endpoint"`   // url of the service (S3_ENDPOINT)
+	Region    string `yaml:"region"`     // region of the bucket (S3_REGION)
+	Bucket    string `yaml:"bucket"`     // bucket of the attachments (S3_BUCKET)
+	AccessKey Secret `yaml:"access_key"` // access key id (S3_ACCESS_KEY)
+	SecretKey Secret `yaml:"secret_key"` // secret access key (S3_SECRET_KEY)
+}
+
+// TrashSettings is the configuration of the trash of the deleted tasks
+type TrashSettings struct {
+	Retention     time.Duration `yaml:"retention"`      // time the deleted tasks stay in the trash (TRASH_RETENTION)
+	PurgeInterval time.Duration `yaml:"purge_interval"` // interval between the purges of the trash (TRASH_PURGE_INTERVAL)
+}
+
+// AttachmentSettings is the configuration of the limits of the attachments
+type AttachmentSettings struct {
+	MaxSize int64 `yaml:"max_size"` // maximum size in bytes of an attachment (ATTACHMENT_MAX_SIZE)
+	Quota   int64 `yaml:"quota"`    // maximum size in bytes of the attachments of a user (ATTACHMENT_QUOTA)
+}
+
+// settings is the loaded configuration
+var settings = DefaultSettings()
+
+// DefaultSettings returns the default configuration, without the database
+// connection string and the JWT keys that are required, the signing key with
+// the HS256 algorithm and the encryption key with RS256 and EdDSA
+func DefaultSettings() Settings {
+	return Settings{
+		URL:  "http://localhost:8080/",
+		Port: "8080",
+		Database: DatabaseSettings{
+			MaxOpenConns:    25,
+			MaxIdleConns:    5,
+			ConnMaxLifetime: 30 * time.Minute,
+		},
+		JWT: JWTSettings{
+			Algorithm:        AlgorithmHS256,
+			RotationInterval: 30 * 24 * time.Hour,
+			Timeout:          15 * time.Minute,
+			RefreshTimeout:   30 * 24 * time.Hour,
+		},
+		SMTP: SMTPSettings{
+			Port: "25",
+		},
+		Storage: StorageSettings{
+			Backend: StorageLocal,
+			Path:    "attachments",
+			S3: S3Settings{
+				Region: "us-east-1",
+			},
+		},
+		Trash: TrashSettings{
+			Retention:     30 * 24 * time.Hour,
+			PurgeInterval: time.Hour,
+		},
+		Attachments: AttachmentSettings{
+			MaxSize: 10 << 20,
+			Quota:   100 << 20,
+		},
+	}
+}
+
+// Load loads the configuration from the defaults, the YAML file of the
+// CONFIG_FILE environment variable, if set, and the environment variables,
+// validates it and makes it the current configuration
+func Load() (Settings, error) {
+	s := DefaultSettings()
+
+	if path := os.Getenv("CONFIG_FILE"); len(path) > 0 {
+		if err := loadFile(&s, path); err != nil {
+			return s, err
+		}
+	}
+	if err := loadEnv(&s, os.LookupEnv); err != nil {
+		return s, err
+	}
+	if err := s.Validate(); err != nil {
+		return s, err
+	}
+
+	s.URL = strings.TrimSuffix(s.URL, "/") + "/"
+	settings = s
+
+	return s, nil
+}
+
+// Get returns the current configuration
+func Get() Settings {
+	return settings
+}
+
+// loadFile overrides s with the values of the YAML file
+func loadFile(s *Settings, path string) error {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("configuration file: %s", err)
+	}
+	if err := yaml.UnmarshalStrict(content, s); err != nil {
+		return fmt.Errorf("configuration file %s: %s", path, err)
+	}
+
+	return nil
+}
+
+// loadEnv overrides s with the environment variables returned by lookup
+func loadEnv(s *Settings, lookup func(string) (string, bool)) error {
+	var errs []string
+
+	str := func(key string, out *string) {
+		if v, ok := lookup(key); ok {
+			*out = v
+		}
+	}
+	integer := func(key string, out *int) {
+		if v, ok := lookup(key); ok {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				errs = append(errs, key+" is not a number")
+				return
+			}
+			*out = n
+		}
+	}
+	size := func(key string, out *int64) {
+		if v, ok := lookup(key); ok {
+			n, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				errs = append(errs, key+" is not a number")
+				return
+			}
+			*out = n
+		}
+	}
+	duration := func(key string, out *time.Duration) {
+		if v, ok := lookup(key); ok {
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				errs = append(errs, key+" is not a duration")
+				return
+			}
+			*out = d
+		}
+	}
+
+	str("URL", &s.URL)
+	str("PORT", &s.Port)
+	str("DB_DSN", (*string)(&s.Database.DSN))
+	integer("DB_MAX_OPEN_CONNS", &s.Database.MaxOpenConns)
+	integer("DB_MAX_IDLE_CONNS", &s.Database.MaxIdleConns)
+	duration("DB_CONN_MAX_LIFETIME", &s.Database.ConnMaxLifetime)
+	str("JWT_ALGORITHM", &s.JWT.Algorithm)
+	str("JWT_KEY", (*string)(&s.JWT.Key))
+	str("JWT_ENCRYPTION_KEY", (*string)(&s.JWT.EncryptionKey))
+	duration("JWT_ROTATION_INTERVAL", &s.JWT.RotationInterval)
+	duration("JWT_TIMEOUT", &s.JWT.Timeout)
+	duration("JWT_REFRESH_TIMEOUT", &s.JWT.RefreshTimeout)
+	str("SMTP_SERVER", &s.SMTP.Server)
+	str("SMTP_PORT", &s.SMTP.Port)
+	str("SMTP_USERNAME", &s.SMTP.Username)
+	str("SMTP_PASSWORD", (*string)(&s.SMTP.Password))
+	str("STORAGE", &s.Storage.Backend)
+	str("STORAGE_PATH", &s.Storage.Path)
+	str("S3_ENDPOINT", &s.Storage.S3.Endpoint)
+	str("S3_REGION", &s.Storage.S3.Region)
+	str("S3_BUCKET", &s.Storage.S3.Bucket)
+	str("S3_ACCESS_KEY", (*string)(&s.Storage.S3.AccessKey))
+	str("S3_SECRET_KEY", (*string)(&s.Storage.S3.SecretKey))
+	duration("TRASH_RETENTION", &s.Trash.Retention)
+	duration("TRASH_PURGE_INTERVAL", &s.Trash.PurgeInterval)
+	size("ATTACHMENT_MAX_SIZE", &s.Attachments.MaxSize)
+	size("ATTACHMENT_QUOTA", &s.Attachments.Quota)
+
+	if len(errs) > 0 {
+		return errors.New("invalid configuration: " + strings.Join(errs, ", "))
+	}
+
+	return nil
+}
+
+// Validate checks the configuration, the error lists all the invalid values
+func (s Settings) Validate() error {
+	var errs []string
+
+	if u, err := url.Parse(s.URL); err != nil || len(u.Scheme) == 0 || len(u.Host) == 0 {
+		errs = append(errs, "url must be an absolute url")
+	}
+	if !validPort(s.Port) {
+		errs = append(errs, "port must be a port number")
+	}
+	if len(s.Database.DSN) == 0 {
+		errs = append(errs, "database.dsn is required")
+	}
+	if s.Database.MaxOpenConns < 0 || s.Database.MaxIdleConns < 0 || s.Database.ConnMaxLifetime < 0 {
+		errs = append(errs, "database pool sizes and lifetime can not be negative")
+	}
+	if s.Database.MaxOpenConns > 0 && s.Database.MaxIdleConns > s.Database.MaxOpenConns {
+		errs = append(errs, "database.max_idle_conns can not exceed database.max_open_conns")
+	}
+	switch {
+	case s.JWT.Algorithm == AlgorithmHS256:
+		if len(s.JWT.Key) < MinKeyLength {
+			errs = append(errs, fmt.Sprintf("jwt.key must be at least %d bytes", MinKeyLength))
+		}
+	case s.JWT.Asymmetric():
+		if len(s.JWT.EncryptionKey) < MinKeyLength {
+			errs = append(errs, fmt.Sprintf("jwt.encryption_key must be at least %d bytes", MinKeyLength))
+		}
+		if s.JWT.RotationInterval <= 0 {
+			errs = append(errs, "jwt.rotation_interval must be positive")
+		}
+	default:
+		errs = append(errs, fmt.Sprintf("jwt.algorithm must be %s, %s or %s", AlgorithmHS256, AlgorithmRS256, AlgorithmEdDSA))
+	}
+	if s.JWT.Timeout <= 0 || s.JWT.RefreshTimeout <= 0 {
+		errs = append(errs, "jwt.timeout and jwt.refresh_timeout must be positive")
+	}
+	if len(s.SMTP.Server) > 0 && !validPort(s.SMTP.Port) {
+		errs = append(errs, "smtp.port must be a port number")
+	}
+	switch s.Storage.Backend {
+	case StorageLocal:
+		if len(s.Storage.Path) == 0 {
+			errs = append(errs, "storage.path is required")
+		}
+	case StorageS3:
+		if u, err := url.Parse(s.Storage.S3.Endpoint); err != nil || len(u.Scheme) == 0 || len(u.Host) == 0 {
+			errs = append(errs, "storage.s3.endpoint must be an absolute url")
+		}
+		if len(s.Storage.S3.Region) == 0 || len(s.Storage.S3.Bucket) == 0 || len(s.Storage.S3.AccessKey) == 0 || len(s.Storage.S3.SecretKey) == 0 {
+			errs = append(errs, "storage.s3 region, bucket, access_key and secret_key are required")
+		}
+	default:
+		errs = append(errs, fmt.Sprintf("storage.backend must be %s or %s", StorageLocal, StorageS3))
+	}
+	if s.Trash.Retention <= 0 || s.Trash.PurgeInterval <= 0 {
+		errs = append(errs, "trash.retention and trash.purge_interval must be positive")
+	}
+	if s.Attachments.MaxSize <= 0 || s.Attachments.Quota <= 0 {
+		errs = append(errs, "attachments.max_size and attachments.quota must be positive")
+	}
+
+	if len(errs) > 0 {
+		return errors.New("invalid configuration: " + strings.Join(errs, ", "))
+	}
+
+	return nil
+}
+
+// String returns the configuration with its secrets redacted
+func (s Settings) String() string {
+	type plain Settings
+	return fmt.Sprintf("%+v", plain(s))
+}
+
+// validPort returns true if port is a TCP port number
+func validPort(port string) bool {
+	n, err := strconv.Atoi(port)
+	return err == nil && n > 0 && n <= 65535
+}
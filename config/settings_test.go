@@ -0,0 +1,141 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadEnv(t *testing.T) {
+	env := map[string]string{
+		"PORT":              "9090",
+		"DB_DSN":            "host=db password=secret",
+		"DB_MAX_OPEN_CONNS": "10",
+		"JWT_KEY":           "0123456789abcdef0123456789abcdef",
+		"JWT_TIMEOUT":       "15m",
+		"STORAGE":           "s3",
+		"S3_ENDPOINT":       "http://minio:9000",
+		"S3_BUCKET":         "attachments",
+		"S3_ACCESS_KEY":     "minio",
+		"S3_SECRET_KEY":     "minio_secret",
+		"TRASH_RETENTION":   "168h",
+		"ATTACHMENT_QUOTA":  "1048576",
+	}
+	lookup := func(key string) (string, bool) {
+		v, ok := env[key]
+		return v, ok
+	}
+
+	s := DefaultSettings()
+	assert.NoError(t, loadEnv(&s, lookup))
+	assert.Equal(t, "9090", s.Port)
+	assert.Equal(t, 10, s.Database.MaxOpenConns)
+	assert.Equal(t, 5, s.Database.MaxIdleConns)
+	assert.Equal(t, 15*time.Minute, s.JWT.Timeout)
+	assert.Equal(t, 30*24*time.Hour, s.JWT.RefreshTimeout)
+	assert.Equal(t, StorageS3, s.Storage.Backend)
+	assert.Equal(t, "us-east-1", s.Storage.S3.Region)
+	assert.Equal(t, Secret("minio_secret"), s.Storage.S3.SecretKey)
+	assert.Equal(t, 7*24*time.Hour, s.Trash.Retention)
+	assert.Equal(t, time.Hour, s.Trash.PurgeInterval)
+	assert.Equal(t, int64(10<<20), s.Attachments.MaxSize)
+	assert.Equal(t, int64(1<<20), s.Attachments.Quota)
+	assert.NoError(t, s.Validate())
+
+	env["DB_MAX_IDLE_CONNS"] = "many"
+	env["JWT_REFRESH_TIMEOUT"] = "1 month"
+	env["ATTACHMENT_MAX_SIZE"] = "10MB"
+	err := loadEnv(&s, lookup)
+	assert.EqualError(t, err, "invalid configuration: DB_MAX_IDLE_CONNS is not a number, JWT_REFRESH_TIMEOUT is not a duration, ATTACHMENT_MAX_SIZE is not a number")
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	dir, err := ioutil.TempDir("", "settings")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "config.yml")
+	file := "port: \"7070\"\nurl: https://todo.example.com\ndatabase:\n  dsn: host=file\n  max_idle_conns: 2\njwt:\n  key: file_key_0123456789abcdef01234567\n  timeout: 2h\n"
+	assert.NoError(t, ioutil.WriteFile(path, []byte(file), 0600))
+
+	for k, v := range map[string]string{"CONFIG_FILE": path, "DB_DSN": "host=env"} {
+		os.Setenv(k, v)
+		defer os.Unsetenv(k)
+	}
+
+	s, err := Load()
+	assert.NoError(t, err)
+	assert.Equal(t, "7070", s.Port)
+	assert.Equal(t, "https://todo.example.com/", s.URL)
+	assert.Equal(t, DSN("host=env"), s.Database.DSN)
+	assert.Equal(t, 2, s.Database.MaxIdleConns)
+	assert.Equal(t, 2*time.Hour, s.JWT.Timeout)
+	assert.Equal(t, s, Get())
+
+	assert.NoError(t, ioutil.WriteFile(path, []byte("jwt:\n  secret: x\n"), 0600))
+	_, err = Load()
+	assert.Error(t, err)
+}
+
+func TestValidate(t *testing.T) {
+	s := DefaultSettings()
+	s.Port = "80a"
+	s.JWT.Key = "short"
+	s.Database.MaxOpenConns = 2
+
+	assert.EqualError(t, s.Validate(), "invalid configuration: port must be a port number, database.dsn is required, database.max_idle_conns can not exceed database.max_open_conns, jwt.key must be at least 32 bytes")
+
+	// the asymmetric algorithms do not need the shared key
+	s = DefaultSettings()
+	s.Database.DSN = "host=db"
+	s.JWT.Algorithm = AlgorithmEdDSA
+	assert.EqualError(t, s.Validate(), "invalid configuration: jwt.encryption_key must be at least 32 bytes")
+	s.JWT.EncryptionKey = "0123456789abcdef0123456789abcdef"
+	assert.NoError(t, s.Validate())
+
+	s.JWT.Algorithm = "none"
+	assert.EqualError(t, s.Validate(), "invalid configuration: jwt.algorithm must be HS256, RS256 or EdDSA")
+
+	s = DefaultSettings()
+	s.Database.DSN = "host=db"
+	s.JWT.Key = "0123456789abcdef0123456789abcdef"
+	s.Storage.Backend = StorageS3
+	s.Storage.S3.Endpoint = "minio:9000"
+	s.Trash.Retention = 0
+	s.Attachments.Quota = -1
+	assert.EqualError(t, s.Validate(), "invalid configuration: storage.s3.endpoint must be an absolute url, "+
+		"storage.s3 region, bucket, access_key and secret_key are required, "+
+		"trash.retention and trash.purge_interval must be positive, attachments.max_size and attachments.quota must be positive")
+
+	s.Storage.Backend = "ftp"
+	s.Trash.Retention = time.Hour
+	s.Attachments.Quota = 1
+	assert.EqualError(t, s.Validate(), "invalid configuration: storage.backend must be local or s3")
+}
+
+func TestSettingsRedaction(t *testing.T) {
+	s := DefaultSettings()
+	s.Database.DSN = "host=db user=admin password=123 sslmode=disable"
+	s.JWT.Key = "0123456789abcdef0123456789abcdef"
+	s.JWT.EncryptionKey = "encryption_key_0123456789abcdef01"
+	s.SMTP.Password = "mail_secret"
+	s.Storage.S3.AccessKey = "s3_access_key"
+	s.Storage.S3.SecretKey = "s3_secret_key"
+
+	out := fmt.Sprint(s)
+	assert.Contains(t, out, "host=db user=admin password=**** sslmode=disable")
+	assert.NotContains(t, out, "123")
+	assert.NotContains(t, out, "0123456789abcdef")
+	assert.NotContains(t, out, "encryption_key_")
+	assert.NotContains(t, out, "mail_secret")
+	assert.NotContains(t, out, "s3_access_key")
+	assert.NotContains(t, out, "s3_secret_key")
+
+	assert.Equal(t, "postgres://admin:****@db:5432/tododb", DSN("postgres://admin:123@db:5432/tododb").String())
+	assert.Equal(t, "", Secret("").String())
+}
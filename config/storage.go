@@ -0,0 +1,34 @@
+package config
+
+import (
+	"log"
+
+	"github.com/giuliobosco/todoAPI/storage"
+)
+
+// Store is the store of the attachments
+var Store storage.Store
+
+// InitStore initialize the store of the attachments of the configuration: the
+// bucket of the S3 compatible service with the s3 backend, otherwise the
+// directory of the local backend.
+func InitStore() storage.Store {
+	s := Get().Storage
+	if s.Backend == StorageS3 {
+		Store = storage.NewS3Store(s.S3.Endpoint, s.S3.Region, s.S3.Bucket, string(s.S3.AccessKey), string(s.S3.SecretKey))
+		return Store
+	}
+
+	local, err := storage.NewLocalStore(s.Path)
+	if err != nil {
+		log.Panicf("error: %s", err)
+	}
+	Store = local
+
+	return Store
+}
+
+// GetStore returns the store of the attachments.
+func GetStore() storage.Store {
+	return Store
+}
@@ -0,0 +1,99 @@
+package controller
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/giuliobosco/todoAPI/config"
+	"github.com/giuliobosco/todoAPI/model"
+	"github.com/giuliobosco/todoAPI/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+const sAccessToken string = config.SAccessToken
+
+// AccessTokenRequest is the body of the creation of a personal access token
+type AccessTokenRequest struct {
+	Name      string     `json:"name"`       // name of the token
+	Scopes    string     `json:"scopes"`     // space separated scopes of the token
+	ExpiresAt *time.Time `json:"expires_at"` // expiration of the token, nil if it does not expire
+}
+
+// FetchAccessTokens is the function for fetch the personal access tokens of
+// the user, without the tokens
+func FetchAccessTokens(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	accessTokens := []model.AccessToken{}
+	config.GetDB().Where("user_id = ?", user.ID).Order("created_at desc").Find(&accessTokens)
+
+	c.JSON(http.StatusOK, accessTokens)
+}
+
+// CreateAccessToken is the function for create a personal access token of the
+// user, the token is only returned by this response
+func CreateAccessToken(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var req AccessTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{sError: err.Error()})
+		return
+	}
+
+	accessToken := model.AccessToken{
+		UserID:    user.ID,
+		Name:      strings.TrimSpace(req.Name),
+		Scopes:    strings.Join(strings.Fields(req.Scopes), " "),
+		ExpiresAt: req.ExpiresAt,
+	}
+	if ok, err := utils.AccessTokenValidator(accessToken); !ok {
+		c.JSON(http.StatusBadRequest, gin.H{sError: err.Error()})
+		return
+	}
+
+	token, err := utils.NewAccessToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{sError: err.Error()})
+		return
+	}
+	accessToken.TokenHash = utils.HashToken(token)
+	accessToken.Prefix = token[:len(config.AccessTokenPrefix)+4]
+
+	if err := config.GetDB().Save(&accessToken).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{sError: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{sMessage: config.SAccessTokenCreated, config.SToken: token, sAccessToken: accessToken})
+}
+
+// RevokeAccessToken is the function for revoke a personal access token of the
+// user
+func RevokeAccessToken(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var accessToken model.AccessToken
+	if err := config.GetDB().Where("id = ? AND user_id = ?", c.Param("id"), user.ID).First(&accessToken).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{sMessage: config.SAccessTokenNotFound})
+		return
+	}
+
+	if err := config.GetDB().Delete(&accessToken).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{sError: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{sMessage: config.SAccessTokenRevoked, sAccessToken: accessToken})
+}
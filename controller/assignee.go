@@ -0,0 +1,152 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/giuliobosco/todoAPI/config"
+	"github.com/giuliobosco/todoAPI/model"
+	"github.com/giuliobosco/todoAPI/policy"
+	"github.com/giuliobosco/todoAPI/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+)
+
+const sAssignee string = config.SAssignee
+
+// AssignRequest is the body of the assignment of a task
+type AssignRequest struct {
+	Email string `json:"email"` // email of the assigned user
+}
+
+// AssignTask is the function for assign a task to a user that can access it,
+// the user is notified by email. A task can have more assignees, assigning
+// again the same user has no effect.
+func AssignTask(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	todo, ok := userTask(c, user, policy.Update, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	var req AssignRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{sError: err.Error()})
+		return
+	}
+	if len(strings.TrimSpace(req.Email)) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{sError: "Missing: email"})
+		return
+	}
+
+	// the unknown emails and the users without access get the same response,
+	// so the assignments do not tell which emails are registered
+	var assigned model.User
+	config.GetDB().Where("email = ? AND active = ?", strings.TrimSpace(req.Email), true).First(&assigned)
+	if assigned.ID <= 0 || !policy.Find(config.GetDB(), assigned, policy.Read, &model.Task{}, todo.ID) {
+		c.JSON(http.StatusBadRequest, gin.H{sError: config.SAssigneeInvalidEmail})
+		return
+	}
+
+	assignee := model.Assignee{TaskID: todo.ID, UserID: assigned.ID}
+	config.GetDB().Where(assignee).First(&assignee)
+	if assignee.ID > 0 {
+		assignee.Email = assigned.Email
+		c.JSON(http.StatusOK, gin.H{sMessage: config.STaskAssigned, sAssignee: assignee})
+		return
+	}
+
+	assignee.AssignedBy = user.ID
+	err := config.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&assignee).Error; err != nil {
+			return err
+		}
+
+		return touchTask(tx, todo)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{sError: err.Error()})
+		return
+	}
+	assignee.Email = assigned.Email
+
+	if assigned.ID != user.ID {
+		utils.TaskAssignmentSendMail(todo, assigned, user)
+	}
+
+	c.JSON(http.StatusCreated, gin.H{sMessage: config.STaskAssigned, sAssignee: assignee})
+}
+
+// UnassignTask is the function for remove an assignee (user parameter, the id
+// of the user) from a task, by a user that can update the task or by the
+// assignee itself
+func UnassignTask(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	action := policy.Update
+	if c.Param("user") == strconv.FormatUint(uint64(user.ID), 10) {
+		action = policy.Read
+	}
+
+	todo, ok := userTask(c, user, action, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	var assignee model.Assignee
+	if err := config.GetDB().Where("task_id = ? AND user_id = ?", todo.ID, c.Param("user")).First(&assignee).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{sMessage: config.SAssigneeNotFound})
+		return
+	}
+
+	err := config.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Delete(&assignee).Error; err != nil {
+			return err
+		}
+
+		return touchTask(tx, todo)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{sError: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{sMessage: config.STaskUnassigned, sAssignee: assignee})
+}
+
+// FetchAssignedTasks is the function for fetch a page of the tasks assigned
+// to the user, filtered and sorted like FetchAllTask. With the workspace
+// header only the tasks of the workspace are listed.
+func FetchAssignedTasks(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	workspaceID, ok := activeWorkspace(c, user, policy.Read)
+	if !ok {
+		return
+	}
+
+	db := policy.ScopeTasks(config.GetDB(), user, policy.Read).Where("id IN (SELECT task_id FROM assignees WHERE user_id = ?)", user.ID)
+	if workspaceID != nil {
+		db = db.Where("workspace_id = ?", *workspaceID)
+	}
+
+	listTasks(c, db)
+}
+
+// touchTask increments the version of the task, for the changes of its
+// relations returned with the task
+func touchTask(tx *gorm.DB, todo model.Task) error {
+	return tx.Model(&todo).Omit("Tags").Update("updated_at", time.Now()).Error
+}
@@ -0,0 +1,235 @@
+package controller
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"path/filepath"
+
+	"github.com/giuliobosco/todoAPI/config"
+	"github.com/giuliobosco/todoAPI/model"
+	"github.com/giuliobosco/todoAPI/policy"
+	"github.com/giuliobosco/todoAPI/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+)
+
+const sAttachment string = config.SAttachment
+
+// multipartOverhead is the size allowed for the multipart encoding of an
+// upload, besides the file
+const multipartOverhead = 1 << 20
+
+// sniffLength is the number of bytes used to detect the content type
+const sniffLength = 512
+
+// FetchTaskAttachments is the function for fetch the attachments of a task,
+// with the attachment quota of the user
+func FetchTaskAttachments(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	todo, ok := userTask(c, user, policy.Read, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	attachments := []model.Attachment{}
+	config.GetDB().Where("task_id = ?", todo.ID).Order("created_at asc").Find(&attachments)
+
+	used, err := utils.AttachmentUsage(config.GetDB(), user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{sError: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{sData: attachments, config.SQuota: gin.H{"used": used, "limit": config.Get().Attachments.Quota}})
+}
+
+// UploadTaskAttachment is the function for attach a file (multipart field
+// file) to a task. The file must be smaller than the maximum attachment size,
+// of an allowed type and fit in the attachment quota of the user.
+func UploadTaskAttachment(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	todo, ok := userTask(c, user, policy.Update, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, config.Get().Attachments.MaxSize+multipartOverhead)
+	file, header, err := c.Request.FormFile("file")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{sError: config.SAttachmentTooLarge})
+		} else {
+			c.JSON(http.StatusBadRequest, gin.H{sError: "Missing: file"})
+		}
+		return
+	}
+	defer file.Close()
+
+	if header.Size > config.Get().Attachments.MaxSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{sError: config.SAttachmentTooLarge})
+		return
+	}
+
+	head := make([]byte, sniffLength)
+	n, err := io.ReadFull(file, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		c.JSON(http.StatusBadRequest, gin.H{sError: err.Error()})
+		return
+	}
+	head = head[:n]
+	contentType, allowed := utils.AttachmentContentType(head)
+	if !allowed {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{sError: config.SAttachmentType})
+		return
+	}
+
+	// fail early, the quota is enforced when the attachment is saved
+	if err := checkAttachmentQuota(config.GetDB(), user, header.Size); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	token, err := utils.GenerateRandomStringURLSafe(config.TokenLength)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{sError: err.Error()})
+		return
+	}
+
+	attachment := model.Attachment{
+		TaskID:      todo.ID,
+		UserID:      user.ID,
+		Filename:    filepath.Base(header.Filename),
+		ContentType: contentType,
+		Size:        header.Size,
+		StorageKey:  fmt.Sprintf("%d/%s", user.ID, token),
+	}
+
+	content := io.MultiReader(bytes.NewReader(head), file)
+	if err := config.GetStore().Put(attachment.StorageKey, content, attachment.Size, contentType); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{sError: err.Error()})
+		return
+	}
+	err = config.GetDB().Transaction(func(tx *gorm.DB) error {
+		// the user is locked so the concurrent uploads do not exceed the quota
+		if err := tx.Set("gorm:query_option", "FOR UPDATE").Select("id").First(&model.User{}, user.ID).Error; err != nil {
+			return err
+		}
+		if err := checkAttachmentQuota(tx, user, attachment.Size); err != nil {
+			return err
+		}
+
+		return tx.Save(&attachment).Error
+	})
+	if err != nil {
+		deleteAttachmentContent(attachment)
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{sMessage: config.SAttachmentCreated, sAttachment: attachment})
+}
+
+// DownloadTaskAttachment is the function for download the content of an
+// attachment
+func DownloadTaskAttachment(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	attachment, ok := taskAttachment(c, user, policy.Read)
+	if !ok {
+		return
+	}
+
+	content, err := config.GetStore().Get(attachment.StorageKey)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{sError: err.Error()})
+		return
+	}
+	defer content.Close()
+
+	disposition := mime.FormatMediaType("attachment", map[string]string{"filename": attachment.Filename})
+	c.DataFromReader(http.StatusOK, attachment.Size, attachment.ContentType, content, map[string]string{
+		"Content-Disposition":    disposition,
+		"X-Content-Type-Options": "nosniff",
+	})
+}
+
+// DeleteTaskAttachment is the function for permanently delete an attachment,
+// its size is released from the quota of the user that uploaded it
+func DeleteTaskAttachment(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	attachment, ok := taskAttachment(c, user, policy.Update)
+	if !ok {
+		return
+	}
+
+	if err := config.GetDB().Unscoped().Delete(&attachment).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{sError: err.Error()})
+		return
+	}
+	deleteAttachmentContent(attachment)
+
+	c.JSON(http.StatusOK, gin.H{sMessage: config.SAttachmentDeleted, sAttachment: attachment})
+}
+
+// checkAttachmentQuota returns an error if an attachment of size bytes does
+// not fit in the attachment quota of the user
+func checkAttachmentQuota(db *gorm.DB, user model.User, size int64) error {
+	used, err := utils.AttachmentUsage(db, user.ID)
+	if err != nil {
+		return err
+	}
+	if used+size > config.Get().Attachments.Quota {
+		return newStatusError(http.StatusRequestEntityTooLarge, config.SAttachmentQuota)
+	}
+
+	return nil
+}
+
+// taskAttachment loads the attachment (attachment parameter) of the task (id
+// parameter) if the user can perform the action on the task, otherwise
+// responds with not found and returns false
+func taskAttachment(c *gin.Context, user model.User, action policy.Action) (model.Attachment, bool) {
+	var attachment model.Attachment
+
+	todo, ok := userTask(c, user, action, c.Param("id"))
+	if !ok {
+		return attachment, false
+	}
+
+	if err := config.GetDB().Where("task_id = ? AND id = ?", todo.ID, c.Param("attachment")).First(&attachment).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{sMessage: config.SAttachmentNotFound})
+		return attachment, false
+	}
+
+	return attachment, true
+}
+
+// deleteAttachmentContent deletes the content of the attachment from the
+// store, the errors are only logged
+func deleteAttachmentContent(attachment model.Attachment) {
+	if err := config.GetStore().Delete(attachment.StorageKey); err != nil {
+		log.Printf("attachment %s delete error: %s", attachment.StorageKey, err)
+	}
+}
@@ -0,0 +1,324 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/giuliobosco/todoAPI/config"
+	"github.com/giuliobosco/todoAPI/model"
+	"github.com/giuliobosco/todoAPI/policy"
+	"github.com/giuliobosco/todoAPI/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+)
+
+// Operations of a batch
+const (
+	batchCreate   = "create"
+	batchUpdate   = "update"
+	batchComplete = "complete"
+	batchDelete   = "delete"
+	batchMove     = "move"
+	batchRetag    = "retag"
+)
+
+// Modes of a batch
+const (
+	batchAtomic     = "atomic"
+	batchBestEffort = "best_effort"
+)
+
+// batchSavepoint is the savepoint of an operation in best effort mode
+const batchSavepoint = "batch_operation"
+
+// BatchRequest is the body of a batch of task operations
+type BatchRequest struct {
+	Mode       string           `json:"mode"`       // atomic (default): all or nothing, best_effort: failed operations are skipped
+	Operations []BatchOperation `json:"operations"` // operations, executed in order
+}
+
+// BatchOperation is an operation of a batch
+type BatchOperation struct {
+	Op        string          `json:"op"`         // create, update, complete, delete, move or retag
+	ID        uint            `json:"id"`         // id of the task, except for create
+	Task      *model.Task     `json:"task"`       // task to create
+	Patch     json.RawMessage `json:"patch"`      // merge patch of the task to update
+	ProjectID *uint           `json:"project_id"` // project of move, null for the inbox
+	TagIDs    []uint          `json:"tag_ids"`    // tags of retag, replacing the tags of the task
+}
+
+// BatchResult is the result of an operation of a batch
+type BatchResult struct {
+	Index  int         `json:"index"`           // position of the operation in the batch
+	Status int         `json:"status"`          // HTTP status of the operation
+	Error  string      `json:"error,omitempty"` // error of the operation, if failed
+	Task   *model.Task `json:"task,omitempty"`  // task of the operation, if succeeded
+}
+
+// BatchTasks is the function for execute a batch of task operations in a
+// single transaction. In atomic mode the first failed operation rolls back
+// the whole batch, the operations before it are reported as failed
+// dependencies. In best effort mode only the failed operations are rolled
+// back.
+func BatchTasks(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	workspaceID, ok := activeWorkspace(c, user, policy.Update)
+	if !ok {
+		return
+	}
+
+	var batch BatchRequest
+	if err := c.ShouldBindJSON(&batch); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{sError: err.Error()})
+		return
+	}
+	if len(batch.Mode) == 0 {
+		batch.Mode = batchAtomic
+	}
+	if batch.Mode != batchAtomic && batch.Mode != batchBestEffort {
+		c.JSON(http.StatusBadRequest, gin.H{sError: config.SBatchInvalidMode})
+		return
+	}
+	if len(batch.Operations) == 0 || len(batch.Operations) > config.MaxBatchOperations {
+		c.JSON(http.StatusBadRequest, gin.H{sError: config.SBatchInvalidSize})
+		return
+	}
+	for i, o := range batch.Operations {
+		if !validBatchOperation(o.Op) {
+			c.JSON(http.StatusBadRequest, gin.H{sError: config.SBatchInvalidOperation + ": " + o.Op, sData: []BatchResult{{Index: i, Status: http.StatusBadRequest}}})
+			return
+		}
+	}
+
+	tx := config.GetDB().Begin()
+	if tx.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{sError: tx.Error.Error()})
+		return
+	}
+
+	results := []BatchResult{}
+	for i, o := range batch.Operations {
+		if batch.Mode == batchBestEffort {
+			if err := tx.Exec("SAVEPOINT " + batchSavepoint).Error; err != nil {
+				tx.Rollback()
+				c.JSON(http.StatusInternalServerError, gin.H{sError: err.Error()})
+				return
+			}
+		}
+
+		todo, status, err := runBatchOperation(tx, user, workspaceID, o)
+		if err != nil {
+			results = append(results, BatchResult{Index: i, Status: errorStatus(err), Error: err.Error()})
+
+			if batch.Mode == batchAtomic {
+				tx.Rollback()
+				for j := range results[:i] {
+					results[j] = BatchResult{Index: j, Status: http.StatusFailedDependency, Error: config.SBatchOperationRolledBack}
+				}
+				c.JSON(errorStatus(err), gin.H{sError: config.SBatchRolledBack, sData: results})
+				return
+			}
+
+			if err := tx.Exec("ROLLBACK TO SAVEPOINT " + batchSavepoint).Error; err != nil {
+				tx.Rollback()
+				c.JSON(http.StatusInternalServerError, gin.H{sError: err.Error()})
+				return
+			}
+			continue
+		}
+
+		if batch.Mode == batchBestEffort {
+			if err := tx.Exec("RELEASE SAVEPOINT " + batchSavepoint).Error; err != nil {
+				tx.Rollback()
+				c.JSON(http.StatusInternalServerError, gin.H{sError: err.Error()})
+				return
+			}
+		}
+		results = append(results, BatchResult{Index: i, Status: status, Task: todo})
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{sError: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{sData: results})
+}
+
+// validBatchOperation returns true if op is an operation of a batch
+func validBatchOperation(op string) bool {
+	switch op {
+	case batchCreate, batchUpdate, batchComplete, batchDelete, batchMove, batchRetag:
+		return true
+	}
+
+	return false
+}
+
+// runBatchOperation executes the operation in the transaction, the tasks are
+// created in the workspace, returns the task and the HTTP status of the
+// operation
+func runBatchOperation(tx *gorm.DB, user model.User, workspaceID *uint, o BatchOperation) (*model.Task, int, error) {
+	if o.Op == batchCreate {
+		todo, err := batchCreateTask(tx, user, workspaceID, o.Task)
+		return todo, http.StatusCreated, err
+	}
+
+	action := policy.Update
+	if o.Op == batchDelete {
+		action = policy.Delete
+	}
+
+	var todo model.Task
+	if !policy.Find(tx.Preload("Tags"), user, action, &todo, o.ID) {
+		return nil, 0, newStatusError(http.StatusNotFound, config.STaskNotFound)
+	}
+
+	before := todo
+	revision := model.ActionUpdate
+
+	var err error
+	switch o.Op {
+	case batchUpdate:
+		err = batchPatchTask(tx, user, &todo, o.Patch)
+	case batchComplete:
+		err = batchPatchTask(tx, user, &todo, json.RawMessage(`{"completed": true}`))
+	case batchMove:
+		if err = checkTaskProject(tx, user, todo.WorkspaceID, o.ProjectID); err == nil {
+			err = tx.Model(&todo).Omit("Tags").Update("project_id", o.ProjectID).Error
+		}
+	case batchRetag:
+		err = batchRetagTask(tx, user, &todo, o.TagIDs)
+	case batchDelete:
+		revision = model.ActionDelete
+		err = tx.Delete(&todo).Error
+	}
+	if err == nil {
+		err = utils.RecordTaskRevision(tx, user.ID, revision, &before, todo)
+	}
+
+	return &todo, http.StatusOK, err
+}
+
+// batchCreateTask creates the task of the workspace in the transaction
+func batchCreateTask(tx *gorm.DB, user model.User, workspaceID *uint, todo *model.Task) (*model.Task, error) {
+	if todo == nil {
+		return nil, newStatusError(http.StatusBadRequest, "Missing: task")
+	}
+	todo.WorkspaceID = workspaceID
+
+	if ok, err := utils.TaskValidator(*todo); !ok {
+		return nil, newStatusError(http.StatusBadRequest, err.Error())
+	}
+	if err := checkTaskProject(tx, user, workspaceID, todo.ProjectID); err != nil {
+		return nil, err
+	}
+	if err := checkTaskParent(tx, user, workspaceID, 0, todo.ParentID); err != nil {
+		return nil, err
+	}
+
+	todo.Base = model.Base{}
+	todo.UserID = user.ID
+	todo.Tags = nil
+	todo.NextID = nil
+	todo.Occurrence = 0
+	if len(todo.Recurrence) > 0 {
+		todo.Occurrence = 1
+	}
+
+	if err := tx.Save(todo).Error; err != nil {
+		return nil, err
+	}
+
+	return todo, utils.RecordTaskRevision(tx, user.ID, model.ActionCreate, nil, *todo)
+}
+
+// batchRetagTask replaces the tags of the task with the tags the user can
+// read in the transaction, an empty list removes all the tags
+func batchRetagTask(tx *gorm.DB, user model.User, todo *model.Task, tagIDs []uint) error {
+	ids := make(map[uint]bool)
+	for _, id := range tagIDs {
+		ids[id] = true
+	}
+
+	tags := []model.Tag{}
+	if len(ids) > 0 {
+		if err := policy.Scope(tx, user, policy.Read).Where("id IN (?)", tagIDs).Find(&tags).Error; err != nil {
+			return err
+		}
+	}
+	if len(tags) != len(ids) {
+		return newStatusError(http.StatusNotFound, config.STagNotFound)
+	}
+
+	if err := tx.Model(todo).Association("Tags").Replace(tags).Error; err != nil {
+		return err
+	}
+	if err := tx.Model(todo).Omit("Tags").Update("version", gorm.Expr("version + 1")).Error; err != nil {
+		return err
+	}
+
+	return tx.Model(todo).Association("Tags").Find(&todo.Tags).Error
+}
+
+// batchPatchTask applies the merge patch to the task in the transaction,
+// completing a task with incomplete subtasks or open blockers fails and
+// completing a recurrent task creates its next occurrence
+func batchPatchTask(tx *gorm.DB, user model.User, todo *model.Task, body json.RawMessage) error {
+	if body == nil {
+		return newStatusError(http.StatusBadRequest, "Missing: patch")
+	}
+
+	patch, err := utils.ParseMergePatch(body)
+	if err != nil {
+		return newStatusError(http.StatusBadRequest, err.Error())
+	}
+	newTodo, updates, err := utils.ApplyTaskPatch(*todo, patch)
+	if err != nil {
+		return newStatusError(http.StatusBadRequest, err.Error())
+	}
+
+	if ok, err := utils.TaskValidator(newTodo); !ok {
+		return newStatusError(http.StatusBadRequest, err.Error())
+	}
+	if _, ok := updates["project_id"]; ok {
+		if err := checkTaskProject(tx, user, newTodo.WorkspaceID, newTodo.ProjectID); err != nil {
+			return err
+		}
+	}
+	if _, ok := updates["parent_id"]; ok {
+		if err := checkTaskParent(tx, user, newTodo.WorkspaceID, todo.ID, newTodo.ParentID); err != nil {
+			return err
+		}
+	}
+
+	completing := newTodo.Completed && !todo.Completed
+	if completing {
+		if err := checkTaskBlockers(tx, *todo, false); err != nil {
+			return err
+		}
+		if err := checkTaskChildren(tx, *todo, config.ChildrenBlock); err != nil {
+			return err
+		}
+	}
+	if recurrence, ok := updates["recurrence"].(string); ok && len(recurrence) > 0 && todo.Occurrence == 0 {
+		updates["occurrence"] = 1
+	}
+
+	if err := tx.Model(todo).Omit("Tags").Updates(updates).Error; err != nil {
+		return err
+	}
+	if err := tx.Preload("Tags").First(todo, todo.ID).Error; err != nil {
+		return newStatusError(http.StatusNotFound, config.STaskNotFound)
+	}
+
+	if completing {
+		_, err = spawnNextOccurrence(tx, user, todo)
+	}
+
+	return err
+}
@@ -0,0 +1,162 @@
+package controller
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/giuliobosco/todoAPI/config"
+	"github.com/giuliobosco/todoAPI/model"
+	"github.com/giuliobosco/todoAPI/policy"
+	"github.com/giuliobosco/todoAPI/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+const sComment string = config.SComment
+
+// FetchTaskComments is the function for fetch a page of the comments of a
+// task, the oldest first (order=desc for the newest first)
+func FetchTaskComments(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	order := c.DefaultQuery("order", "asc")
+	if order != "asc" && order != "desc" {
+		c.JSON(http.StatusBadRequest, gin.H{sError: config.SInvalidOrder})
+		return
+	}
+	page, err := utils.ParsePage(c.Request.URL.Query(), order == "desc", true)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{sError: err.Error()})
+		return
+	}
+
+	todo, ok := userTask(c, user, policy.Read, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	comments := []model.Comment{}
+	if err := page.Apply(config.GetDB().Where("task_id = ?", todo.ID)).Find(&comments).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{sError: err.Error()})
+		return
+	}
+	pagination := page.Paginate(&comments, func(i int) model.Base { return comments[i].Base })
+
+	c.JSON(http.StatusOK, gin.H{sData: comments, sPagination: pagination})
+}
+
+// CreateTaskComment is the function for comment a task
+func CreateTaskComment(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var comment model.Comment
+	if err := c.ShouldBindJSON(&comment); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{sError: err.Error()})
+		return
+	}
+
+	todo, ok := userTask(c, user, policy.Read, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	if ok, err := utils.CommentValidator(comment); !ok {
+		c.JSON(http.StatusBadRequest, gin.H{sError: err.Error()})
+		return
+	}
+
+	comment.Base = model.Base{}
+	comment.TaskID = todo.ID
+	comment.UserID = user.ID
+	comment.EditedAt = nil
+	if err := config.GetDB().Save(&comment).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{sError: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{sMessage: config.SCommentCreated, sComment: comment})
+}
+
+// UpdateTaskComment is the function for edit the body of a comment, only the
+// author can edit a comment
+func UpdateTaskComment(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var newComment model.Comment
+	if err := c.ShouldBindJSON(&newComment); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{sError: err.Error()})
+		return
+	}
+	if ok, err := utils.CommentValidator(newComment); !ok {
+		c.JSON(http.StatusBadRequest, gin.H{sError: err.Error()})
+		return
+	}
+
+	comment, ok := userTaskComment(c, user, policy.Update)
+	if !ok || !preconditionMet(c, comment.Version) {
+		return
+	}
+
+	db := config.GetDB().Model(&comment)
+	if hasIfMatch(c) {
+		db = db.Where("version = ?", comment.Version)
+	}
+	if result := db.Updates(map[string]interface{}{"body": newComment.Body, "edited_at": time.Now()}); result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{sError: result.Error.Error()})
+		return
+	} else if hasIfMatch(c) && result.RowsAffected == 0 {
+		c.JSON(http.StatusPreconditionFailed, gin.H{sError: config.SPreconditionFailed})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{sMessage: config.SCommentUpdated, sComment: comment})
+}
+
+// DeleteTaskComment is the function for delete a comment, only the author can
+// delete a comment
+func DeleteTaskComment(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	comment, ok := userTaskComment(c, user, policy.Delete)
+	if !ok {
+		return
+	}
+
+	if err := config.GetDB().Delete(&comment).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{sError: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{sMessage: config.SCommentDeleted, sComment: comment})
+}
+
+// userTaskComment loads the comment (comment parameter) of the task (id
+// parameter) if the user can read the task and perform the action on the
+// comment, otherwise responds with not found and returns false
+func userTaskComment(c *gin.Context, user model.User, action policy.Action) (model.Comment, bool) {
+	var comment model.Comment
+
+	todo, ok := userTask(c, user, policy.Read, c.Param("id"))
+	if !ok {
+		return comment, false
+	}
+
+	if !policy.Find(config.GetDB().Where("task_id = ?", todo.ID), user, action, &comment, c.Param("comment")) {
+		c.JSON(http.StatusNotFound, gin.H{sMessage: config.SCommentNotFound})
+		return comment, false
+	}
+
+	return comment, true
+}
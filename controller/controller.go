@@ -1,20 +1,41 @@
 package controller
 
 import (
+	"encoding/json"
 	"net/http"
+	"time"
 
+	"github.com/giuliobosco/todoAPI/auth"
 	"github.com/giuliobosco/todoAPI/config"
 	"github.com/giuliobosco/todoAPI/model"
+	"github.com/giuliobosco/todoAPI/policy"
 	"github.com/giuliobosco/todoAPI/utils"
 
-	jwtapple2 "github.com/appleboy/gin-jwt/v2"
 	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
 )
 
 const sMessage string = config.SMessage
 const sError string = config.SError
 const sData string = config.SData
 const sTask string = config.STask
+const sPagination string = config.SPagination
+
+// currentUser loads the user identified by the JWT claims, if the user does
+// not exist responds with bad request and returns false
+func currentUser(c *gin.Context) (model.User, bool) {
+	claims := auth.ExtractClaims(c)
+
+	var user model.User
+	config.GetDB().Where("id = ?", claims[config.IdentityKey]).First(&user)
+
+	if user.ID <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{sError: config.SUserInvalid})
+		return user, false
+	}
+
+	return user, true
+}
 
 // RegisterEndPoint registration API End Point
 func RegisterEndPoint(c *gin.Context) {
@@ -37,6 +58,7 @@ func RegisterEndPoint(c *gin.Context) {
 	}
 
 	user.Active = false
+	user.TOTPEnabled = false
 	var err error
 	user.VerifyToken, err = utils.GenerateRandomStringURLSafe(config.TokenLength)
 
@@ -120,8 +142,19 @@ func ExecutePasswordRecovery(c *gin.Context) {
 		return
 	}
 
-	config.GetDB().Model(&user).Update("verify_token", user.VerifyToken)
-	config.GetDB().Model(&user).Update("password", user.Password)
+	// the password is changed only if the sessions are revoked
+	err = config.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&user).Updates(map[string]interface{}{"verify_token": user.VerifyToken, "password": user.Password}).Error; err != nil {
+			return err
+		}
+
+		_, err := utils.RevokeSessions(tx.Where("user_id = ?", user.ID))
+		return err
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{sError: err.Error()})
+		return
+	}
 
 	c.JSON(http.StatusOK, gin.H{sMessage: config.SUserPasswordUpdated})
 }
@@ -132,7 +165,7 @@ type PasswordRecovery struct {
 }
 
 func UpdatePassword(c *gin.Context) {
-	claims := jwtapple2.ExtractClaims(c)
+	claims := auth.ExtractClaims(c)
 
 	var user model.User
 	config.GetDB().Where("id = ?", claims[config.IdentityKey]).First(&user)
@@ -164,13 +197,26 @@ func UpdatePassword(c *gin.Context) {
 		return
 	}
 
-	config.GetDB().Model(&user).Update("password", pr.NewPassword)
+	err = config.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&user).Update("password", pr.NewPassword).Error; err != nil {
+			return err
+		}
+
+		// the other devices have to login again with the new password
+		_, err := utils.RevokeSessions(tx.Where("user_id = ? AND id <> ?", user.ID, currentSession(c)))
+		return err
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{sError: err.Error()})
+		return
+	}
 
 	c.JSON(http.StatusOK, gin.H{sMessage: config.SUserPasswordUpdated})
 }
 
+// FetchUser fetch the user, with the ETag header of its version
 func FetchUser(c *gin.Context) {
-	claims := jwtapple2.ExtractClaims(c)
+	claims := auth.ExtractClaims(c)
 
 	var user model.User
 	config.GetDB().Where("id = ?", claims[config.IdentityKey]).First(&user)
@@ -181,12 +227,16 @@ func FetchUser(c *gin.Context) {
 	}
 	user.Password = ""
 
+	if notModified(c, user.Version) {
+		return
+	}
+
 	c.JSON(http.StatusOK, user)
 }
 
-// UpdateUser update user
+// UpdateUser update user, honouring the If-Match header
 func UpdateUser(c *gin.Context) {
-	claims := jwtapple2.ExtractClaims(c)
+	claims := auth.ExtractClaims(c)
 
 	var dbUser model.User
 	config.GetDB().Where("id = ?", claims[config.IdentityKey]).First(&dbUser)
@@ -196,6 +246,10 @@ func UpdateUser(c *gin.Context) {
 		return
 	}
 
+	if !preconditionMet(c, dbUser.Version) {
+		return
+	}
+
 	var user model.User
 	if err := c.ShouldBindJSON(&user); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{sError: err.Error()})
@@ -206,7 +260,13 @@ func UpdateUser(c *gin.Context) {
 		return
 	}
 
-	if dbUser.Email != user.Email {
+	updates := map[string]interface{}{
+		"firstname": user.Firstname,
+		"lastname":  user.Lastname,
+	}
+
+	emailChanged := dbUser.Email != user.Email
+	if emailChanged {
 		var userCheck model.User
 		config.GetDB().First(&userCheck, "email = ?", user.Email)
 
@@ -223,20 +283,32 @@ func UpdateUser(c *gin.Context) {
 			c.JSON(http.StatusInternalServerError, gin.H{sError: config.SUserFailUpdate})
 			return
 		}
-		config.GetDB().Model(&dbUser).Update("email", user.Email)
-		config.GetDB().Model(&dbUser).Update("active", user.Active)
-		config.GetDB().Model(&dbUser).Update("verify_token", user.VerifyToken)
-		utils.UserConfirmationSendMail(user)
+		updates["email"] = user.Email
+		updates["active"] = user.Active
+		updates["verify_token"] = user.VerifyToken
+	}
+
+	db := config.GetDB().Model(&dbUser)
+	if hasIfMatch(c) {
+		db = db.Where("version = ?", dbUser.Version)
+	}
+	if result := db.Updates(updates); result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{sError: config.SUserFailUpdate})
+		return
+	} else if hasIfMatch(c) && result.RowsAffected == 0 {
+		c.JSON(http.StatusPreconditionFailed, gin.H{sError: config.SPreconditionFailed})
+		return
 	}
 
-	config.GetDB().Model(&dbUser).Update("firstname", user.Firstname)
-	config.GetDB().Model(&dbUser).Update("lastname", user.Lastname)
+	if emailChanged {
+		utils.UserConfirmationSendMail(user)
+	}
 
 	c.JSON(http.StatusCreated, gin.H{sMessage: config.SUserUpdated})
 }
 
 func DeleteUser(c *gin.Context) {
-	claims := jwtapple2.ExtractClaims(c)
+	claims := auth.ExtractClaims(c)
 
 	var dbUser model.User
 	config.GetDB().Where("id = ?", claims[config.IdentityKey]).First(&dbUser)
@@ -264,7 +336,7 @@ func DeleteUser(c *gin.Context) {
 
 // CreateTask is the function for create a task
 func CreateTask(c *gin.Context) {
-	claims := jwtapple2.ExtractClaims(c)
+	claims := auth.ExtractClaims(c)
 
 	var user model.User
 	config.GetDB().Where("id = ?", claims[config.IdentityKey]).First(&user)
@@ -273,21 +345,55 @@ func CreateTask(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{sError: config.SUserInvalid})
 		return
 	}
+	workspaceID, ok := activeWorkspace(c, user, policy.Update)
+	if !ok {
+		return
+	}
 
 	var todo model.Task
 	if err := c.ShouldBindJSON(&todo); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{sError: err.Error()})
 		return
 	}
+	todo.WorkspaceID = workspaceID
+
+	if ok, err := utils.TaskValidator(todo); !ok {
+		c.JSON(http.StatusBadRequest, gin.H{sError: err.Error()})
+		return
+	}
+	if !validTaskProject(c, user, todo) {
+		return
+	}
+	if !validTaskParent(c, user, todo.WorkspaceID, 0, todo.ParentID) {
+		return
+	}
 
 	todo.UserID = user.ID
-	config.GetDB().Save(&todo)
+	todo.Tags = nil
+	todo.NextID = nil
+	todo.Occurrence = 0
+	if len(todo.Recurrence) > 0 {
+		todo.Occurrence = 1
+	}
+	err := config.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&todo).Error; err != nil {
+			return err
+		}
+
+		return utils.RecordTaskRevision(tx, user.ID, model.ActionCreate, nil, todo)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{sError: err.Error()})
+		return
+	}
+
 	c.JSON(http.StatusCreated, gin.H{sMessage: config.STaskCreated, sTask: todo})
 }
 
-// FetchAllTask is the function for fetch all tasks
+// FetchAllTask is the function for fetch a page of the tasks, filtered and
+// sorted by the query parameters (see utils.ParseTaskQuery)
 func FetchAllTask(c *gin.Context) {
-	claims := jwtapple2.ExtractClaims(c)
+	claims := auth.ExtractClaims(c)
 
 	var user model.User
 	config.GetDB().Where("id = ?", claims[config.IdentityKey]).First(&user)
@@ -297,43 +403,171 @@ func FetchAllTask(c *gin.Context) {
 		return
 	}
 
+	workspaceID, ok := activeWorkspace(c, user, policy.Read)
+	if !ok {
+		return
+	}
+
+	listTasks(c, workspaceTasks(config.GetDB(), user, workspaceID))
+}
+
+// listTasks responds with a page of the tasks selected by db, filtered and
+// sorted by the query parameters
+func listTasks(c *gin.Context, db *gorm.DB) {
+	q, err := utils.ParseTaskQuery(c.Request.URL.Query())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{sError: err.Error()})
+		return
+	}
+
+	todos := []model.Task{}
+	if err := q.Apply(db.Preload("Tags")).Find(&todos).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{sError: err.Error()})
+		return
+	}
+	pagination := q.Page.Paginate(&todos, func(i int) model.Base { return todos[i].Base })
+	if err := utils.CountTaskComments(config.GetDB(), todos); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{sError: err.Error()})
+		return
+	}
+	if err := utils.LoadTaskAssignees(config.GetDB(), todos); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{sError: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{sData: todos, sPagination: pagination})
+}
+
+// FetchOverdueTasks is the function for fetch the not completed tasks with
+// the due date in the past
+func FetchOverdueTasks(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	workspaceID, ok := activeWorkspace(c, user, policy.Read)
+	if !ok {
+		return
+	}
+
 	var todos []model.Task
-	config.GetDB().Where("user_id = ?", user.ID).Order("created_at desc").Find(&todos)
+	workspaceTasks(config.GetDB(), user, workspaceID).Where("completed = ? AND due_at < ?", false, time.Now()).Order("due_at asc").Find(&todos)
+	if err := utils.CountTaskComments(config.GetDB(), todos); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{sError: err.Error()})
+		return
+	}
+	if err := utils.LoadTaskAssignees(config.GetDB(), todos); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{sError: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{sData: todos})
+}
+
+// FetchTasksDueToday is the function for fetch the tasks due today, the day
+// is computed in the timezone of the tz query parameter (default UTC)
+func FetchTasksDueToday(c *gin.Context) {
+	loc, err := utils.LoadLocation(c.Query("tz"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{sError: err.Error()})
+		return
+	}
+
+	from, to := utils.DayBounds(time.Now().In(loc))
+	fetchTasksDueBetween(c, from, to)
+}
+
+// FetchTasksDueThisWeek is the function for fetch the tasks due this week
+// (monday to sunday), the week is computed in the timezone of the tz query
+// parameter (default UTC)
+func FetchTasksDueThisWeek(c *gin.Context) {
+	loc, err := utils.LoadLocation(c.Query("tz"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{sError: err.Error()})
+		return
+	}
+
+	from, to := utils.WeekBounds(time.Now().In(loc))
+	fetchTasksDueBetween(c, from, to)
+}
+
+// FetchTasksDueRange is the function for fetch the tasks due between the from
+// and to query parameters
+func FetchTasksDueRange(c *gin.Context) {
+	loc, err := utils.LoadLocation(c.Query("tz"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{sError: err.Error()})
+		return
+	}
 
-	if len(todos) <= 0 {
-		c.JSON(http.StatusNotFound, gin.H{sMessage: config.STaskNotFound, sData: todos})
+	from, to, err := utils.ParseDateRange(c.Query("from"), c.Query("to"), loc)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{sError: err.Error()})
+		return
+	}
+
+	fetchTasksDueBetween(c, from, to)
+}
+
+// fetchTasksDueBetween responds with the tasks of the user due in [from, to)
+func fetchTasksDueBetween(c *gin.Context, from time.Time, to time.Time) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	workspaceID, ok := activeWorkspace(c, user, policy.Read)
+	if !ok {
+		return
+	}
+
+	var todos []model.Task
+	workspaceTasks(config.GetDB(), user, workspaceID).Where("due_at >= ? AND due_at < ?", from, to).Order("due_at asc").Find(&todos)
+	if err := utils.CountTaskComments(config.GetDB(), todos); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{sError: err.Error()})
+		return
+	}
+	if err := utils.LoadTaskAssignees(config.GetDB(), todos); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{sError: err.Error()})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{sData: todos})
 }
 
-// FetchSingleTask is the function for fetch a single task by id
+// FetchSingleTask is the function for fetch a single task by id, with the
+// ETag header of its version
 func FetchSingleTask(c *gin.Context) {
-	todoID := c.Param("id")
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
 
-	if len(todoID) <= 0 {
-		c.JSON(http.StatusBadRequest, gin.H{sError: config.STaskInvalid})
+	todo, ok := userTask(c, user, policy.Read, c.Param("id"))
+	if !ok {
 		return
 	}
 
-	var todo model.Task
-	config.GetDB().First(&todo, todoID)
+	if notModified(c, todo.Version) {
+		return
+	}
 
-	if todo.ID == 0 {
-		c.JSON(http.StatusNotFound, gin.H{sMessage: config.STaskNotFound})
+	todos := []model.Task{todo}
+	if err := utils.LoadTaskAssignees(config.GetDB(), todos); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{sError: err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, todo)
+	c.JSON(http.StatusOK, todos[0])
 }
 
-// UpdateTask is the function for update a task by id
+// UpdateTask is the function for update a task by id, completing a task with
+// incomplete subtasks follows the children query parameter (block or complete).
+// Completing a recurrent task creates its next occurrence.
 func UpdateTask(c *gin.Context) {
-	todoID := c.Param("id")
-
-	if len(todoID) <= 0 {
-		c.JSON(http.StatusBadRequest, gin.H{sError: config.SUserInvalid})
+	user, ok := currentUser(c)
+	if !ok {
 		return
 	}
 
@@ -343,40 +577,212 @@ func UpdateTask(c *gin.Context) {
 		return
 	}
 
-	var todo model.Task
-	config.GetDB().First(&todo, todoID)
+	todo, ok := userTask(c, user, policy.Update, c.Param("id"))
+	if !ok || !preconditionMet(c, todo.Version) {
+		return
+	}
 
-	if todo.ID <= 0 {
-		c.JSON(http.StatusNotFound, gin.H{sMessage: config.STaskNotFound})
+	if ok, err := utils.TaskValidator(newTodo); !ok {
+		c.JSON(http.StatusBadRequest, gin.H{sError: err.Error()})
+		return
+	}
+	newTodo.WorkspaceID = todo.WorkspaceID
+	if !validTaskProject(c, user, newTodo) {
+		return
+	}
+	if !validTaskParent(c, user, todo.WorkspaceID, todo.ID, newTodo.ParentID) {
+		return
+	}
+	if newTodo.Completed && !todo.Completed && !validTaskBlockers(c, todo) {
+		return
+	}
+	if newTodo.Completed && !todo.Completed && !completeTaskChildren(c, todo) {
+		return
+	}
+
+	updates := map[string]interface{}{
+		"title":       newTodo.Title,
+		"description": newTodo.Description,
+		"completed":   newTodo.Completed,
+		"priority":    newTodo.Priority,
+		"start_at":    newTodo.StartAt,
+		"due_at":      newTodo.DueAt,
+		"project_id":  newTodo.ProjectID,
+		"parent_id":   newTodo.ParentID,
+		"recurrence":  newTodo.Recurrence,
+	}
+	saveTaskUpdates(c, user, todo, updates, model.ActionUpdate)
+}
+
+// PatchTask is the function for partially update a task by id, only the
+// fields of the patch are modified. The body is a RFC 7396 JSON Merge Patch
+// (application/merge-patch+json or application/json) or a RFC 6902 JSON Patch
+// (application/json-patch+json). Subtasks and recurrences are handled like
+// UpdateTask.
+func PatchTask(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
 		return
 	}
 
-	config.GetDB().Model(&todo).Update("title", newTodo.Title)
-	config.GetDB().Model(&todo).Update("description", newTodo.Description)
-	config.GetDB().Model(&todo).Update("completed", newTodo.Completed)
+	todo, ok := userTask(c, user, policy.Update, c.Param("id"))
+	if !ok || !preconditionMet(c, todo.Version) {
+		return
+	}
 
-	config.GetDB().First(&todo, todoID)
+	body, err := c.GetRawData()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{sError: err.Error()})
+		return
+	}
 
-	c.JSON(http.StatusOK, gin.H{sMessage: config.STaskUpdated, sTask: todo})
+	var patch map[string]json.RawMessage
+	switch c.ContentType() {
+	case config.MergePatchContentType, gin.MIMEJSON:
+		patch, err = utils.ParseMergePatch(body)
+	case config.JSONPatchContentType:
+		patch, err = utils.ParseJSONPatch(body, todo)
+	default:
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{sError: config.SUnsupportedMediaType})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{sError: err.Error()})
+		return
+	}
+
+	newTodo, updates, err := utils.ApplyTaskPatch(todo, patch)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{sError: err.Error()})
+		return
+	}
+
+	if ok, err := utils.TaskValidator(newTodo); !ok {
+		c.JSON(http.StatusBadRequest, gin.H{sError: err.Error()})
+		return
+	}
+	if _, ok := updates["project_id"]; ok && !validTaskProject(c, user, newTodo) {
+		return
+	}
+	if _, ok := updates["parent_id"]; ok && !validTaskParent(c, user, todo.WorkspaceID, todo.ID, newTodo.ParentID) {
+		return
+	}
+	if newTodo.Completed && !todo.Completed && !validTaskBlockers(c, todo) {
+		return
+	}
+	if newTodo.Completed && !todo.Completed && !completeTaskChildren(c, todo) {
+		return
+	}
+
+	saveTaskUpdates(c, user, todo, updates, model.ActionUpdate)
+}
+
+// saveTaskUpdates updates the columns of the task in a single statement, only
+// if its version is unchanged when the request has the If-Match header,
+// records the change in the history and responds with the updated task. If
+// the update (not a revert) completes a recurrent task the next occurrence is
+// created.
+func saveTaskUpdates(c *gin.Context, user model.User, todo model.Task, updates map[string]interface{}, action string) {
+	before := todo
+
+	if recurrence, ok := updates["recurrence"].(string); ok && len(recurrence) > 0 && todo.Occurrence == 0 {
+		updates["occurrence"] = 1
+	}
+
+	var next *model.Task
+	err := config.GetDB().Transaction(func(tx *gorm.DB) error {
+		if len(updates) > 0 {
+			db := tx.Model(&todo).Omit("Tags")
+			if hasIfMatch(c) {
+				db = db.Where("version = ?", todo.Version)
+			}
+
+			if result := db.Updates(updates); result.Error != nil {
+				return result.Error
+			} else if hasIfMatch(c) && result.RowsAffected == 0 {
+				return newStatusError(http.StatusPreconditionFailed, config.SPreconditionFailed)
+			}
+		}
+
+		if err := tx.Preload("Tags").First(&todo, todo.ID).Error; err != nil {
+			return err
+		}
+		if err := utils.RecordTaskRevision(tx, user.ID, action, &before, todo); err != nil {
+			return err
+		}
+
+		var err error
+		if todo.Completed && !before.Completed && action != model.ActionRevert {
+			next, err = spawnNextOccurrence(tx, user, &todo)
+		}
+		return err
+	})
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	message := config.STaskUpdated
+	if action == model.ActionRevert {
+		message = config.STaskReverted
+	}
+	if next != nil {
+		c.JSON(http.StatusOK, gin.H{sMessage: message, sTask: todo, config.SNext: next})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{sMessage: message, sTask: todo})
 }
 
-// DeleteTask is the function for delete a task by id
+// DeleteTask is the function for delete a task by id, honouring the If-Match
+// header
 func DeleteTask(c *gin.Context) {
-	var todo model.Task
-	todoID := c.Param("id")
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
 
-	if len(todoID) <= 0 {
-		c.JSON(http.StatusBadRequest, gin.H{sError: config.STaskNotFound})
+	todo, ok := userTask(c, user, policy.Delete, c.Param("id"))
+	if !ok || !preconditionMet(c, todo.Version) {
 		return
 	}
 
-	config.GetDB().First(&todo, todoID)
+	err := config.GetDB().Transaction(func(tx *gorm.DB) error {
+		db := tx
+		if hasIfMatch(c) {
+			db = db.Where("version = ?", todo.Version)
+		}
 
-	if todo.ID == 0 {
-		c.JSON(http.StatusNotFound, gin.H{sMessage: config.STaskNotFound})
+		if result := db.Delete(&todo); result.Error != nil {
+			return result.Error
+		} else if hasIfMatch(c) && result.RowsAffected == 0 {
+			return newStatusError(http.StatusPreconditionFailed, config.SPreconditionFailed)
+		}
+
+		return utils.RecordTaskRevision(tx, user.ID, model.ActionDelete, &todo, todo)
+	})
+	if err != nil {
+		respondError(c, err)
 		return
 	}
 
-	config.GetDB().Delete(&todo)
 	c.JSON(http.StatusOK, gin.H{sMessage: config.STaskDeleted, sTask: todo})
 }
+
+// userTask loads the task by id, with its tags, if the user can perform the
+// action on it, otherwise responds with not found and returns false
+func userTask(c *gin.Context, user model.User, action policy.Action, todoID string) (model.Task, bool) {
+	var todo model.Task
+
+	if len(todoID) <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{sError: config.STaskInvalid})
+		return todo, false
+	}
+
+	if !policy.Find(config.GetDB().Preload("Tags"), user, action, &todo, todoID) {
+		c.JSON(http.StatusNotFound, gin.H{sMessage: config.STaskNotFound})
+		return todo, false
+	}
+
+	return todo, true
+}
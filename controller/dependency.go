@@ -0,0 +1,178 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/giuliobosco/todoAPI/config"
+	"github.com/giuliobosco/todoAPI/model"
+	"github.com/giuliobosco/todoAPI/policy"
+	"github.com/giuliobosco/todoAPI/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+)
+
+const sDependency string = config.SDependency
+
+// FetchTaskBlockers is the function for fetch the tasks blocking a task and
+// the tasks blocked by it
+func FetchTaskBlockers(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	todo, ok := userTask(c, user, policy.Read, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	blockers := []model.Task{}
+	policy.ScopeTasks(config.GetDB(), user, policy.Read).
+		Where("id IN (SELECT blocker_id FROM task_dependencies WHERE task_id = ?)", todo.ID).
+		Order("id asc").
+		Find(&blockers)
+	blocking := []model.Task{}
+	policy.ScopeTasks(config.GetDB(), user, policy.Read).
+		Where("id IN (SELECT task_id FROM task_dependencies WHERE blocker_id = ?)", todo.ID).
+		Order("id asc").
+		Find(&blocking)
+
+	c.JSON(http.StatusOK, gin.H{"blockers": blockers, "blocking": blocking})
+}
+
+// AddTaskBlocker is the function for block a task by another task (blocker
+// parameter), the dependencies can not create cycles
+func AddTaskBlocker(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	todo, ok := userTask(c, user, policy.Update, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	var blocker model.Task
+	if !policy.Find(config.GetDB(), user, policy.Read, &blocker, c.Param("blocker")) {
+		c.JSON(http.StatusBadRequest, gin.H{sError: config.STaskInvalidBlocker})
+		return
+	}
+
+	dependency := model.TaskDependency{TaskID: todo.ID, BlockerID: blocker.ID}
+	status := http.StatusCreated
+	err := config.GetDB().Transaction(func(tx *gorm.DB) error {
+		// the check locks the tasks it visits, so it sees the concurrent
+		// additions on them
+		if cycle, err := utils.DependencyCycle(tx, todo.ID, blocker.ID); err != nil {
+			return err
+		} else if cycle {
+			return newStatusError(http.StatusConflict, config.STaskDependencyCycle)
+		}
+
+		if err := tx.Where(dependency).First(&dependency).Error; err == nil {
+			status = http.StatusOK
+			return nil
+		} else if !gorm.IsRecordNotFoundError(err) {
+			return err
+		}
+		if err := tx.Save(&dependency).Error; err != nil {
+			return err
+		}
+
+		return touchTask(tx, todo)
+	})
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(status, gin.H{sMessage: config.SDependencyAdded, sDependency: dependency})
+}
+
+// RemoveTaskBlocker is the function for remove the blocker (blocker
+// parameter) of a task
+func RemoveTaskBlocker(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	todo, ok := userTask(c, user, policy.Update, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	var dependency model.TaskDependency
+	if err := config.GetDB().Where("task_id = ? AND blocker_id = ?", todo.ID, c.Param("blocker")).First(&dependency).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{sMessage: config.SDependencyNotFound})
+		return
+	}
+
+	err := config.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Delete(&dependency).Error; err != nil {
+			return err
+		}
+
+		return touchTask(tx, todo)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{sError: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{sMessage: config.SDependencyRemoved, sDependency: dependency})
+}
+
+// FetchTaskGraph is the function for fetch the dependency graph of the open
+// tasks of the user, or of the workspace with the workspace header, with the
+// tasks ordered after their blockers and the tasks that can be worked on now
+func FetchTaskGraph(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	workspaceID, ok := activeWorkspace(c, user, policy.Read)
+	if !ok {
+		return
+	}
+
+	todos := []model.Task{}
+	workspaceTasks(config.GetDB(), user, workspaceID).
+		Where("completed = ?", false).
+		Order("priority desc").Order("due_at asc").Order("id asc").
+		Find(&todos)
+
+	ids := make([]uint, len(todos))
+	for i, t := range todos {
+		ids[i] = t.ID
+	}
+
+	c.JSON(http.StatusOK, utils.BuildTaskGraph(todos, utils.OpenDependencies(config.GetDB(), ids)))
+}
+
+// validTaskBlockers checks that the task being completed has no open
+// blockers, unless the force query parameter is true, otherwise responds with
+// conflict and returns false
+func validTaskBlockers(c *gin.Context, todo model.Task) bool {
+	force, _ := strconv.ParseBool(c.Query("force"))
+
+	if err := checkTaskBlockers(config.GetDB(), todo, force); err != nil {
+		respondError(c, err)
+		return false
+	}
+
+	return true
+}
+
+// checkTaskBlockers returns a conflict error if the task has open blockers
+// and force is false
+func checkTaskBlockers(db *gorm.DB, todo model.Task, force bool) error {
+	if force || len(utils.OpenBlockerIDs(db, todo.ID)) == 0 {
+		return nil
+	}
+
+	return newStatusError(http.StatusConflict, config.STaskBlocked)
+}
@@ -0,0 +1,38 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// statusError is an error with the HTTP status of its response
+type statusError struct {
+	status  int    // HTTP status of the error
+	message string // message of the error
+}
+
+// Error returns the message of the error
+func (e *statusError) Error() string {
+	return e.message
+}
+
+// newStatusError returns an error responded with the HTTP status
+func newStatusError(status int, message string) error {
+	return &statusError{status: status, message: message}
+}
+
+// errorStatus returns the HTTP status of the error, internal server error if
+// the error has no status
+func errorStatus(err error) int {
+	if e, ok := err.(*statusError); ok {
+		return e.status
+	}
+
+	return http.StatusInternalServerError
+}
+
+// respondError responds with the status and the message of the error
+func respondError(c *gin.Context, err error) {
+	c.JSON(errorStatus(err), gin.H{sError: err.Error()})
+}
@@ -0,0 +1,40 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/giuliobosco/todoAPI/config"
+	"github.com/giuliobosco/todoAPI/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// notModified sets the ETag header of the object version and, if the
+// If-None-Match header matches it, responds with not modified and returns true
+func notModified(c *gin.Context, version uint) bool {
+	c.Header("ETag", utils.ETag(version))
+
+	if h := c.GetHeader("If-None-Match"); len(h) > 0 && utils.ETagMatches(h, version) {
+		c.Status(http.StatusNotModified)
+		return true
+	}
+
+	return false
+}
+
+// preconditionMet checks the If-Match header, if present, against the object
+// version. If it does not match responds with precondition failed and returns
+// false.
+func preconditionMet(c *gin.Context, version uint) bool {
+	if h := c.GetHeader("If-Match"); len(h) > 0 && !utils.ETagMatches(h, version) {
+		c.JSON(http.StatusPreconditionFailed, gin.H{sError: config.SPreconditionFailed})
+		return false
+	}
+
+	return true
+}
+
+// hasIfMatch returns true if the request is conditional on the object version
+func hasIfMatch(c *gin.Context) bool {
+	return len(c.GetHeader("If-Match")) > 0
+}
@@ -0,0 +1,69 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/giuliobosco/todoAPI/config"
+	"github.com/giuliobosco/todoAPI/model"
+	"github.com/giuliobosco/todoAPI/policy"
+	"github.com/giuliobosco/todoAPI/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// FetchTaskHistory is the function for fetch the history of a task, the
+// oldest revision first
+func FetchTaskHistory(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	todo, ok := userTask(c, user, policy.Read, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	revisions := []model.TaskRevision{}
+	config.GetDB().Where("task_id = ?", todo.ID).Order("revision asc").Find(&revisions)
+
+	c.JSON(http.StatusOK, gin.H{sData: revisions})
+}
+
+// RevertTask is the function for revert a task to the state of a revision of
+// its history, the revert is recorded as a new revision. The tags of the task
+// are not reverted, completing a task with incomplete subtasks follows the
+// children query parameter like UpdateTask.
+func RevertTask(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	todo, ok := userTask(c, user, policy.Update, c.Param("id"))
+	if !ok || !preconditionMet(c, todo.Version) {
+		return
+	}
+
+	var revision model.TaskRevision
+	if err := config.GetDB().Where("task_id = ? AND revision = ?", todo.ID, c.Param("revision")).First(&revision).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{sMessage: config.SRevisionNotFound})
+		return
+	}
+
+	revision.Task.WorkspaceID = todo.WorkspaceID
+	if !validTaskProject(c, user, revision.Task) {
+		return
+	}
+	if !validTaskParent(c, user, todo.WorkspaceID, todo.ID, revision.Task.ParentID) {
+		return
+	}
+	if revision.Task.Completed && !todo.Completed && !validTaskBlockers(c, todo) {
+		return
+	}
+	if revision.Task.Completed && !todo.Completed && !completeTaskChildren(c, todo) {
+		return
+	}
+
+	saveTaskUpdates(c, user, todo, utils.TaskRevertUpdates(revision), model.ActionRevert)
+}
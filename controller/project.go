@@ -0,0 +1,243 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/giuliobosco/todoAPI/config"
+	"github.com/giuliobosco/todoAPI/model"
+	"github.com/giuliobosco/todoAPI/policy"
+	"github.com/giuliobosco/todoAPI/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+)
+
+const sProject string = config.SProject
+
+// CreateProject is the function for create a project
+func CreateProject(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	workspaceID, ok := activeWorkspace(c, user, policy.Update)
+	if !ok {
+		return
+	}
+
+	var project model.Project
+	if err := c.ShouldBindJSON(&project); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{sError: err.Error()})
+		return
+	}
+	project.Name = strings.TrimSpace(project.Name)
+	if ok, err := utils.ProjectValidator(project); !ok {
+		c.JSON(http.StatusBadRequest, gin.H{sError: err.Error()})
+		return
+	}
+
+	project.ID = 0
+	project.UserID = user.ID
+	project.WorkspaceID = workspaceID
+	config.GetDB().Save(&project)
+
+	c.JSON(http.StatusCreated, gin.H{sMessage: config.SProjectCreated, sProject: project})
+}
+
+// FetchAllProjects is the function for fetch the projects of the user, the
+// archived projects are included only with the archived=true query parameter
+func FetchAllProjects(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	workspaceID, ok := activeWorkspace(c, user, policy.Read)
+	if !ok {
+		return
+	}
+
+	db := workspaceProjects(config.GetDB(), user, workspaceID)
+	if archived, _ := strconv.ParseBool(c.Query("archived")); !archived {
+		db = db.Where("archived = ?", false)
+	}
+
+	projects := []model.Project{}
+	db.Order("sort_order asc").Order("id asc").Find(&projects)
+
+	c.JSON(http.StatusOK, gin.H{sData: projects})
+}
+
+// FetchSingleProject is the function for fetch a single project by id
+func FetchSingleProject(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	project, ok := userProject(c, user, policy.Read, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, project)
+}
+
+// FetchProjectTasks is the function for fetch a page of the tasks of a
+// project, filtered and sorted like FetchAllTask
+func FetchProjectTasks(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	project, ok := userProject(c, user, policy.Read, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	listTasks(c, policy.ScopeTasks(config.GetDB(), user, policy.Read).Where("project_id = ?", project.ID))
+}
+
+// UpdateProject is the function for update a project by id
+func UpdateProject(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	project, ok := userProject(c, user, policy.Update, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	var newProject model.Project
+	if err := c.ShouldBindJSON(&newProject); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{sError: err.Error()})
+		return
+	}
+	newProject.Name = strings.TrimSpace(newProject.Name)
+	if ok, err := utils.ProjectValidator(newProject); !ok {
+		c.JSON(http.StatusBadRequest, gin.H{sError: err.Error()})
+		return
+	}
+
+	config.GetDB().Model(&project).Updates(map[string]interface{}{
+		"name":       newProject.Name,
+		"color":      newProject.Color,
+		"archived":   newProject.Archived,
+		"sort_order": newProject.SortOrder,
+	})
+
+	c.JSON(http.StatusOK, gin.H{sMessage: config.SProjectUpdated, sProject: project})
+}
+
+// DeleteProject is the function for delete a project by id, the tasks query
+// parameter chooses what happens to the tasks of the project: inbox (default)
+// moves them to the inbox, cascade deletes them. The project is no more shared.
+func DeleteProject(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	mode := c.DefaultQuery("tasks", config.ProjectDeleteInbox)
+	if mode != config.ProjectDeleteInbox && mode != config.ProjectDeleteCascade {
+		c.JSON(http.StatusBadRequest, gin.H{sError: config.SProjectInvalidDeleteMode})
+		return
+	}
+
+	project, ok := userProject(c, user, policy.Delete, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	err := config.GetDB().Transaction(func(tx *gorm.DB) error {
+		tasks := policy.ScopeTasks(tx.Model(&model.Task{}), user, policy.Delete).Where("project_id = ?", project.ID)
+
+		var todos []model.Task
+		if err := tasks.Preload("Tags").Find(&todos).Error; err != nil {
+			return err
+		}
+
+		var err error
+		if mode == config.ProjectDeleteCascade {
+			err = tasks.Delete(&model.Task{}).Error
+		} else {
+			err = tasks.Update("project_id", nil).Error
+		}
+		if err != nil {
+			return err
+		}
+
+		for _, todo := range todos {
+			after, action := todo, model.ActionDelete
+			if mode == config.ProjectDeleteInbox {
+				after.ProjectID, after.Version, action = nil, todo.Version+1, model.ActionUpdate
+			}
+			if err := utils.RecordTaskRevision(tx, user.ID, action, &todo, after); err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Exec("DELETE FROM shares WHERE resource_type = ? AND resource_id = ?", model.ShareProject, project.ID).Error; err != nil {
+			return err
+		}
+
+		return tx.Delete(&project).Error
+	})
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{sError: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{sMessage: config.SProjectDeleted, sProject: project})
+}
+
+// userProject loads the project by id if the user can perform the action on
+// it, otherwise responds with not found and returns false
+func userProject(c *gin.Context, user model.User, action policy.Action, projectID string) (model.Project, bool) {
+	var project model.Project
+
+	if len(projectID) <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{sError: config.SProjectInvalid})
+		return project, false
+	}
+
+	if !policy.Find(config.GetDB(), user, action, &project, projectID) {
+		c.JSON(http.StatusNotFound, gin.H{sMessage: config.SProjectNotFound})
+		return project, false
+	}
+
+	return project, true
+}
+
+// validTaskProject checks that the user can add tasks to the project of the
+// task, if any, otherwise responds with bad request and returns false
+func validTaskProject(c *gin.Context, user model.User, todo model.Task) bool {
+	if err := checkTaskProject(config.GetDB(), user, todo.WorkspaceID, todo.ProjectID); err != nil {
+		respondError(c, err)
+		return false
+	}
+
+	return true
+}
+
+// checkTaskProject checks that the user can add tasks of the workspace to the
+// project, nil is the inbox
+func checkTaskProject(db *gorm.DB, user model.User, workspaceID *uint, projectID *uint) error {
+	if projectID == nil {
+		return nil
+	}
+
+	var project model.Project
+	if !policy.Find(db, user, policy.Update, &project, *projectID) || !sameWorkspace(project.WorkspaceID, workspaceID) {
+		return newStatusError(http.StatusBadRequest, config.SProjectInvalid)
+	}
+
+	return nil
+}
@@ -0,0 +1,104 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/giuliobosco/todoAPI/config"
+	"github.com/giuliobosco/todoAPI/model"
+	"github.com/giuliobosco/todoAPI/policy"
+	"github.com/giuliobosco/todoAPI/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+)
+
+// maxOccurrencesPreview is the maximum number of occurrences of a preview
+const maxOccurrencesPreview = 100
+
+// FetchTaskOccurrences is the function for preview the next n (query
+// parameter, default 10) occurrences of a recurrent task
+func FetchTaskOccurrences(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	n, err := strconv.Atoi(c.DefaultQuery("n", "10"))
+	if err != nil || n <= 0 || n > maxOccurrencesPreview {
+		c.JSON(http.StatusBadRequest, gin.H{sError: config.SInvalidLimit})
+		return
+	}
+
+	todo, ok := userTask(c, user, policy.Read, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	occurrences := []time.Time{}
+	if len(todo.Recurrence) > 0 && todo.DueAt != nil {
+		rule, err := utils.ParseRRule(todo.Recurrence)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{sError: err.Error()})
+			return
+		}
+		occurrences = append(occurrences, rule.Occurrences(*todo.DueAt, todo.Occurrence, n)...)
+	}
+
+	c.JSON(http.StatusOK, gin.H{sTask: todo, config.SOccurrences: occurrences})
+}
+
+// spawnNextOccurrence creates the occurrence following the completed task,
+// with the due date (and start date) shifted by the recurrence rule and the
+// same tags, recorded in the history as created by the user. Returns nil if
+// the task is not recurrent, the recurrence is over or the next occurrence has
+// already been created by a previous completion, otherwise the next occurrence
+// is recorded in the task.
+func spawnNextOccurrence(db *gorm.DB, user model.User, todo *model.Task) (*model.Task, error) {
+	if len(todo.Recurrence) == 0 || todo.DueAt == nil || todo.NextID != nil {
+		return nil, nil
+	}
+
+	rule, err := utils.ParseRRule(todo.Recurrence)
+	if err != nil {
+		return nil, nil
+	}
+
+	due, ok := rule.Next(*todo.DueAt, todo.Occurrence)
+	if !ok {
+		return nil, nil
+	}
+
+	next := model.Task{
+		Title:       todo.Title,
+		Description: todo.Description,
+		UserID:      todo.UserID,
+		Priority:    todo.Priority,
+		DueAt:       &due,
+		Recurrence:  todo.Recurrence,
+		Occurrence:  todo.Occurrence + 1,
+		ParentID:    todo.ParentID,
+		ProjectID:   todo.ProjectID,
+		WorkspaceID: todo.WorkspaceID,
+	}
+	if todo.StartAt != nil {
+		start := todo.StartAt.Add(due.Sub(*todo.DueAt))
+		next.StartAt = &start
+	}
+
+	if err := db.Save(&next).Error; err != nil {
+		return nil, err
+	}
+	if len(todo.Tags) > 0 {
+		if err := db.Model(&next).Association("Tags").Append(todo.Tags).Error; err != nil {
+			return nil, err
+		}
+		next.Tags = todo.Tags
+	}
+	if err := db.Model(todo).Omit("Tags").UpdateColumn("next_id", next.ID).Error; err != nil {
+		return nil, err
+	}
+
+	return &next, utils.RecordTaskRevision(db, user.ID, model.ActionCreate, nil, next)
+}
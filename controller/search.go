@@ -0,0 +1,56 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/giuliobosco/todoAPI/config"
+	"github.com/giuliobosco/todoAPI/policy"
+	"github.com/giuliobosco/todoAPI/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SearchTasks is the function for search the tasks the user can read by the
+// keywords of the q query parameter, the best matches first. With the
+// workspace header only the tasks of the workspace are searched.
+func SearchTasks(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	workspaceID, ok := activeWorkspace(c, user, policy.Read)
+	if !ok {
+		return
+	}
+
+	query := strings.TrimSpace(c.Query("q"))
+	if len(query) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{sError: config.SSearchQueryMissing})
+		return
+	}
+
+	limit := utils.DefaultPageLimit
+	if l, present := c.GetQuery("limit"); present {
+		var err error
+		limit, err = strconv.Atoi(l)
+		if err != nil || limit <= 0 || limit > utils.MaxPageLimit {
+			c.JSON(http.StatusBadRequest, gin.H{sError: config.SInvalidLimit})
+			return
+		}
+	}
+
+	db := config.GetDB()
+	scoped := policy.ScopeTasks(db, user, policy.Read)
+	if workspaceID != nil {
+		scoped = scoped.Where("workspace_id = ?", *workspaceID)
+	}
+	results, err := utils.NewTaskSearcher(db).Search(scoped, query, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{sError: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{sData: results})
+}
@@ -0,0 +1,106 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/giuliobosco/todoAPI/auth"
+	"github.com/giuliobosco/todoAPI/config"
+	"github.com/giuliobosco/todoAPI/model"
+	"github.com/giuliobosco/todoAPI/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// FetchSessions is the function for fetch the active sessions of the user,
+// the session of the request is marked as current
+func FetchSessions(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	sessions := []model.Session{}
+	config.GetDB().
+		Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", user.ID, time.Now()).
+		Order("last_used_at desc").
+		Find(&sessions)
+
+	current := currentSession(c)
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == current
+	}
+
+	c.JSON(http.StatusOK, sessions)
+}
+
+// RevokeSession is the function for revoke a session of the user, its access
+// tokens and refresh token stop working
+func RevokeSession(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var session model.Session
+	if err := config.GetDB().Where("id = ? AND user_id = ? AND revoked_at IS NULL", c.Param("id"), user.ID).First(&session).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{sMessage: config.SSessionNotFound})
+		return
+	}
+
+	if _, err := utils.RevokeSessions(config.GetDB().Where("id = ?", session.ID)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{sError: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{sMessage: config.SSessionRevoked})
+}
+
+// RevokeAllSessions is the function for revoke all the sessions of the user,
+// with the others query parameter the session of the request is kept
+func RevokeAllSessions(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	db := config.GetDB().Where("user_id = ?", user.ID)
+	if others, _ := strconv.ParseBool(c.Query("others")); others {
+		db = db.Where("id <> ?", currentSession(c))
+	}
+
+	revoked, err := utils.RevokeSessions(db)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{sError: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{sMessage: config.SSessionsRevoked, "revoked": revoked})
+}
+
+// Logout is the function for revoke the session of the request
+func Logout(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	if _, err := utils.RevokeSessions(config.GetDB().Where("id = ? AND user_id = ?", currentSession(c), user.ID)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{sError: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{sMessage: config.SLoggedOut})
+}
+
+// currentSession returns the id of the session of the JWT claims, 0 without
+// session
+func currentSession(c *gin.Context) uint {
+	// the numbers of the claims are decoded as float64
+	if sid, ok := auth.ExtractClaims(c)[config.SessionKey].(float64); ok && sid > 0 {
+		return uint(sid)
+	}
+
+	return 0
+}
@@ -0,0 +1,230 @@
+package controller
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/giuliobosco/todoAPI/config"
+	"github.com/giuliobosco/todoAPI/model"
+	"github.com/giuliobosco/todoAPI/policy"
+
+	"github.com/gin-gonic/gin"
+)
+
+const sShare string = config.SShare
+
+// ShareRequest is the body of a share of a resource
+type ShareRequest struct {
+	Email string `json:"email"` // email of the user to share the resource with
+	Role  string `json:"role"`  // viewer (default) or editor
+}
+
+// ShareTask is the function for share a task with another user, sharing again
+// the task with the same user changes its role
+func ShareTask(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	todo, ok := userTask(c, user, policy.Share, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	shareResource(c, user, model.ShareTask, todo.ID)
+}
+
+// FetchTaskShares is the function for fetch the users a task is shared with
+func FetchTaskShares(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	todo, ok := userTask(c, user, policy.Share, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	listShares(c, model.ShareTask, todo.ID)
+}
+
+// RevokeTaskShare is the function for revoke the share of a task, by the
+// owner of the task or by the user the task is shared with
+func RevokeTaskShare(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var todo model.Task
+	owner := policy.Find(config.GetDB(), user, policy.Share, &todo, c.Param("id"))
+
+	revokeShare(c, user, model.ShareTask, c.Param("id"), owner)
+}
+
+// ShareProject is the function for share a project, with all its tasks, with
+// another user, sharing again the project with the same user changes its role
+func ShareProject(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	project, ok := userProject(c, user, policy.Share, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	shareResource(c, user, model.ShareProject, project.ID)
+}
+
+// FetchProjectShares is the function for fetch the users a project is shared
+// with
+func FetchProjectShares(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	project, ok := userProject(c, user, policy.Share, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	listShares(c, model.ShareProject, project.ID)
+}
+
+// RevokeProjectShare is the function for revoke the share of a project, by
+// the owner of the project or by the user the project is shared with
+func RevokeProjectShare(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var project model.Project
+	owner := policy.Find(config.GetDB(), user, policy.Share, &project, c.Param("id"))
+
+	revokeShare(c, user, model.ShareProject, c.Param("id"), owner)
+}
+
+// FetchSharedWithMe is the function for fetch the tasks and the projects
+// shared with the user, with the role of the user
+func FetchSharedWithMe(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	tasks := []model.SharedTask{}
+	err := config.GetDB().Table("tasks").
+		Select("tasks.*, shares.role").
+		Joins("JOIN shares ON shares.resource_id = tasks.id AND shares.resource_type = ?", model.ShareTask).
+		Where("shares.user_id = ? AND tasks.deleted_at IS NULL", user.ID).
+		Order("tasks.created_at desc").
+		Scan(&tasks).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{sError: err.Error()})
+		return
+	}
+
+	projects := []model.SharedProject{}
+	err = config.GetDB().Table("projects").
+		Select("projects.*, shares.role").
+		Joins("JOIN shares ON shares.resource_id = projects.id AND shares.resource_type = ?", model.ShareProject).
+		Where("shares.user_id = ? AND projects.deleted_at IS NULL", user.ID).
+		Order("projects.sort_order asc, projects.name asc").
+		Scan(&projects).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{sError: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"tasks": tasks, "projects": projects})
+}
+
+// shareResource shares the resource with the user of the email in the body
+func shareResource(c *gin.Context, user model.User, resourceType string, resourceID uint) {
+	var req ShareRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{sError: err.Error()})
+		return
+	}
+	if len(strings.TrimSpace(req.Email)) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{sError: "Missing: email"})
+		return
+	}
+	if len(req.Role) == 0 {
+		req.Role = model.ShareViewer
+	}
+	if req.Role != model.ShareViewer && req.Role != model.ShareEditor {
+		c.JSON(http.StatusBadRequest, gin.H{sError: config.SShareInvalidRole})
+		return
+	}
+
+	// an unknown email and the own email get the same response, to not reveal
+	// the registered emails
+	var grantee model.User
+	config.GetDB().Where("email = ? AND active = ?", strings.TrimSpace(req.Email), true).First(&grantee)
+	if grantee.ID <= 0 || grantee.ID == user.ID {
+		c.JSON(http.StatusBadRequest, gin.H{sError: config.SShareInvalidEmail})
+		return
+	}
+
+	share := model.Share{ResourceType: resourceType, ResourceID: resourceID, UserID: grantee.ID}
+	status := http.StatusCreated
+	config.GetDB().Where(share).First(&share)
+	if share.ID > 0 {
+		status = http.StatusOK
+	}
+
+	share.SharedBy = user.ID
+	share.Role = req.Role
+	if err := config.GetDB().Save(&share).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{sError: err.Error()})
+		return
+	}
+	share.Email = grantee.Email
+
+	c.JSON(status, gin.H{sMessage: config.SShared, sShare: share})
+}
+
+// listShares responds with the shares of the resource, with the emails of
+// the users
+func listShares(c *gin.Context, resourceType string, resourceID uint) {
+	shares := []model.Share{}
+	err := config.GetDB().Table("shares").
+		Select("shares.*, users.email").
+		Joins("JOIN users ON users.id = shares.user_id").
+		Where("shares.resource_type = ? AND shares.resource_id = ?", resourceType, resourceID).
+		Order("shares.created_at asc").
+		Scan(&shares).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{sError: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{sData: shares})
+}
+
+// revokeShare deletes the share (share parameter) of the resource, owner is
+// true if the user can share the resource, otherwise the user can only revoke
+// its own share
+func revokeShare(c *gin.Context, user model.User, resourceType string, resourceID string, owner bool) {
+	var share model.Share
+
+	db := config.GetDB().Where("resource_type = ? AND resource_id = ? AND id = ?", resourceType, resourceID, c.Param("share"))
+	if !owner {
+		db = db.Where("user_id = ?", user.ID)
+	}
+	if err := db.First(&share).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{sMessage: config.SShareNotFound})
+		return
+	}
+
+	config.GetDB().Unscoped().Delete(&share)
+
+	c.JSON(http.StatusOK, gin.H{sMessage: config.SShareRevoked, sShare: share})
+}
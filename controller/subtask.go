@@ -0,0 +1,142 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/giuliobosco/todoAPI/config"
+	"github.com/giuliobosco/todoAPI/model"
+	"github.com/giuliobosco/todoAPI/policy"
+	"github.com/giuliobosco/todoAPI/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+)
+
+// FetchTaskChildren is the function for fetch the direct subtasks of a task
+func FetchTaskChildren(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	todo, ok := userTask(c, user, policy.Read, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	todos := []model.Task{}
+	config.GetDB().Preload("Tags").Where("parent_id = ?", todo.ID).Order("created_at asc").Find(&todos)
+	if err := utils.CountTaskComments(config.GetDB(), todos); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{sError: err.Error()})
+		return
+	}
+	if err := utils.LoadTaskAssignees(config.GetDB(), todos); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{sError: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{sData: todos})
+}
+
+// FetchTaskTree is the function for fetch a task with all its subtasks
+func FetchTaskTree(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	todo, ok := userTask(c, user, policy.Read, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	descendants := utils.TaskDescendants(config.GetDB().Preload("Tags"), todo.ID)
+
+	c.JSON(http.StatusOK, utils.BuildTaskTree(todo, descendants))
+}
+
+// validTaskParent checks the parent of the task with checkTaskParent,
+// responds with the error and returns false if it is not valid
+func validTaskParent(c *gin.Context, user model.User, workspaceID *uint, todoID uint, parentID *uint) bool {
+	if err := checkTaskParent(config.GetDB(), user, workspaceID, todoID, parentID); err != nil {
+		respondError(c, err)
+		return false
+	}
+
+	return true
+}
+
+// checkTaskParent checks that the user can add subtasks to the parent of the
+// task, if any, that the parent is in the workspace of the task, that it is
+// not the task itself or one of its subtasks and that the hierarchy does not
+// exceed config.MaxTaskDepth levels. todoID is 0 for a new task.
+func checkTaskParent(db *gorm.DB, user model.User, workspaceID *uint, todoID uint, parentID *uint) error {
+	if parentID == nil {
+		return nil
+	}
+
+	var parent model.Task
+	if !policy.Find(db, user, policy.Update, &parent, *parentID) || !sameWorkspace(parent.WorkspaceID, workspaceID) {
+		return newStatusError(http.StatusBadRequest, config.STaskInvalidParent)
+	}
+
+	ancestors := utils.TaskAncestorIDs(db, parent.ID)
+	for _, id := range ancestors {
+		if id == todoID {
+			return newStatusError(http.StatusBadRequest, config.STaskParentCycle)
+		}
+	}
+
+	height := 0
+	if todoID != 0 {
+		tree := utils.BuildTaskTree(model.Task{Base: model.Base{ID: todoID}}, utils.TaskDescendants(db, todoID))
+		height = utils.TaskHeight(tree)
+	}
+
+	// the depth of the task is the number of its ancestors
+	if len(ancestors)+height >= config.MaxTaskDepth {
+		return newStatusError(http.StatusBadRequest, config.STaskTooDeep)
+	}
+
+	return nil
+}
+
+// completeTaskChildren handles the incomplete subtasks of a task being
+// completed, following the children query parameter: block (default) responds
+// with conflict and returns false, complete completes them
+func completeTaskChildren(c *gin.Context, todo model.Task) bool {
+	mode := c.DefaultQuery("children", config.ChildrenBlock)
+	if mode != config.ChildrenBlock && mode != config.ChildrenComplete {
+		c.JSON(http.StatusBadRequest, gin.H{sError: config.STaskInvalidChildrenMode})
+		return false
+	}
+
+	if err := checkTaskChildren(config.GetDB(), todo, mode); err != nil {
+		respondError(c, err)
+		return false
+	}
+
+	return true
+}
+
+// checkTaskChildren handles the incomplete subtasks of a task being
+// completed: with the block mode returns a conflict error, with the complete
+// mode completes them
+func checkTaskChildren(db *gorm.DB, todo model.Task, mode string) error {
+	var incomplete []uint
+	for _, t := range utils.TaskDescendants(db, todo.ID) {
+		if !t.Completed {
+			incomplete = append(incomplete, t.ID)
+		}
+	}
+
+	if len(incomplete) == 0 {
+		return nil
+	}
+
+	if mode == config.ChildrenBlock {
+		return newStatusError(http.StatusConflict, config.STaskIncompleteChildren)
+	}
+
+	return db.Model(&model.Task{}).Where("id IN (?)", incomplete).Update("completed", true).Error
+}
@@ -0,0 +1,228 @@
+package controller
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/giuliobosco/todoAPI/config"
+	"github.com/giuliobosco/todoAPI/model"
+	"github.com/giuliobosco/todoAPI/policy"
+	"github.com/giuliobosco/todoAPI/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+)
+
+const sTag string = config.STag
+
+// CreateTag is the function for create a tag
+func CreateTag(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var tag model.Tag
+	if err := c.ShouldBindJSON(&tag); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{sError: err.Error()})
+		return
+	}
+	tag.Name = strings.TrimSpace(tag.Name)
+	if ok, err := utils.TagValidator(tag); !ok {
+		c.JSON(http.StatusBadRequest, gin.H{sError: err.Error()})
+		return
+	}
+
+	var tagCheck model.Tag
+	policy.Scope(config.GetDB(), user, policy.Read).Where("name = ?", tag.Name).First(&tagCheck)
+
+	if tagCheck.ID > 0 {
+		c.JSON(http.StatusConflict, gin.H{sMessage: config.STagExists})
+		return
+	}
+
+	tag.ID = 0
+	tag.UserID = user.ID
+	config.GetDB().Save(&tag)
+
+	c.JSON(http.StatusCreated, gin.H{sMessage: config.STagCreated, sTag: tag})
+}
+
+// FetchAllTags is the function for fetch all the tags of the user, with the
+// number of tasks of each tag
+func FetchAllTags(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	tags := []model.TagCount{}
+	config.GetDB().Table("tags").
+		Select("tags.*, COUNT(tasks.id) AS task_count").
+		Joins("LEFT JOIN task_tags ON task_tags.tag_id = tags.id").
+		Joins("LEFT JOIN tasks ON tasks.id = task_tags.task_id AND tasks.deleted_at IS NULL").
+		Where("tags.user_id = ? AND tags.deleted_at IS NULL", user.ID).
+		Group("tags.id").
+		Order("tags.name asc").
+		Scan(&tags)
+
+	c.JSON(http.StatusOK, gin.H{sData: tags})
+}
+
+// UpdateTag is the function for update a tag by id
+func UpdateTag(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	tag, ok := userTag(c, user, policy.Update, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	var newTag model.Tag
+	if err := c.ShouldBindJSON(&newTag); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{sError: err.Error()})
+		return
+	}
+	newTag.Name = strings.TrimSpace(newTag.Name)
+	if ok, err := utils.TagValidator(newTag); !ok {
+		c.JSON(http.StatusBadRequest, gin.H{sError: err.Error()})
+		return
+	}
+
+	if newTag.Name != tag.Name {
+		var tagCheck model.Tag
+		policy.Scope(config.GetDB(), user, policy.Read).Where("name = ?", newTag.Name).First(&tagCheck)
+
+		if tagCheck.ID > 0 {
+			c.JSON(http.StatusConflict, gin.H{sMessage: config.STagExists})
+			return
+		}
+	}
+
+	config.GetDB().Model(&tag).Updates(map[string]interface{}{"name": newTag.Name, "color": newTag.Color})
+
+	c.JSON(http.StatusOK, gin.H{sMessage: config.STagUpdated, sTag: tag})
+}
+
+// DeleteTag is the function for delete a tag by id, the tag is removed from
+// all its tasks
+func DeleteTag(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	tag, ok := userTag(c, user, policy.Delete, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	// tags are deleted permanently so the name can be used again
+	config.GetDB().Exec("DELETE FROM task_tags WHERE tag_id = ?", tag.ID)
+	config.GetDB().Unscoped().Delete(&tag)
+
+	c.JSON(http.StatusOK, gin.H{sMessage: config.STagDeleted, sTag: tag})
+}
+
+// AttachTag is the function for add a tag to a task
+func AttachTag(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	todo, tag, ok := userTaskTag(c, user)
+	if !ok {
+		return
+	}
+
+	before := todo
+	err := config.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&todo).Association("Tags").Append(&tag).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&todo).Omit("Tags").Update("version", gorm.Expr("version + 1")).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&todo).Association("Tags").Find(&todo.Tags).Error; err != nil {
+			return err
+		}
+
+		return utils.RecordTaskRevision(tx, user.ID, model.ActionUpdate, &before, todo)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{sError: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{sMessage: config.STagAttached, sTask: todo})
+}
+
+// DetachTag is the function for remove a tag from a task
+func DetachTag(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	todo, tag, ok := userTaskTag(c, user)
+	if !ok {
+		return
+	}
+
+	before := todo
+	err := config.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&todo).Association("Tags").Delete(&tag).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&todo).Omit("Tags").Update("version", gorm.Expr("version + 1")).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&todo).Association("Tags").Find(&todo.Tags).Error; err != nil {
+			return err
+		}
+
+		return utils.RecordTaskRevision(tx, user.ID, model.ActionUpdate, &before, todo)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{sError: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{sMessage: config.STagDetached, sTask: todo})
+}
+
+// userTag loads the tag by id if the user can perform the action on it,
+// otherwise responds with not found and returns false
+func userTag(c *gin.Context, user model.User, action policy.Action, tagID string) (model.Tag, bool) {
+	var tag model.Tag
+
+	if len(tagID) <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{sError: config.STagInvalid})
+		return tag, false
+	}
+
+	if !policy.Find(config.GetDB(), user, action, &tag, tagID) {
+		c.JSON(http.StatusNotFound, gin.H{sMessage: config.STagNotFound})
+		return tag, false
+	}
+
+	return tag, true
+}
+
+// userTaskTag loads the task (id parameter) the user can update and the tag
+// (tag parameter) the user can read, if one of them is not found responds
+// with not found and returns false
+func userTaskTag(c *gin.Context, user model.User) (model.Task, model.Tag, bool) {
+	todo, ok := userTask(c, user, policy.Update, c.Param("id"))
+	if !ok {
+		return todo, model.Tag{}, false
+	}
+
+	tag, ok := userTag(c, user, policy.Read, c.Param("tag"))
+
+	return todo, tag, ok
+}
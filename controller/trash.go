@@ -0,0 +1,100 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/giuliobosco/todoAPI/config"
+	"github.com/giuliobosco/todoAPI/model"
+	"github.com/giuliobosco/todoAPI/policy"
+	"github.com/giuliobosco/todoAPI/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+)
+
+// FetchTrash is the function for fetch the deleted tasks of the user, the
+// last deleted first
+func FetchTrash(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	workspaceID, ok := activeWorkspace(c, user, policy.Read)
+	if !ok {
+		return
+	}
+
+	todos := []model.Task{}
+	workspaceTasks(trash(), user, workspaceID).Order("deleted_at desc").Find(&todos)
+
+	c.JSON(http.StatusOK, gin.H{sData: todos})
+}
+
+// RestoreTask is the function for restore a deleted task by id
+func RestoreTask(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	todo, ok := trashTask(c, user, policy.Update)
+	if !ok {
+		return
+	}
+
+	err := config.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Model(&todo).Omit("Tags").Update("deleted_at", nil).Error; err != nil {
+			return err
+		}
+		if err := tx.Preload("Tags").First(&todo, todo.ID).Error; err != nil {
+			return err
+		}
+
+		return utils.RecordTaskRevision(tx, user.ID, model.ActionRestore, &todo, todo)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{sError: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{sMessage: config.STaskRestored, sTask: todo})
+}
+
+// PurgeTask is the function for permanently delete a deleted task by id
+func PurgeTask(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	todo, ok := trashTask(c, user, policy.Delete)
+	if !ok {
+		return
+	}
+
+	if err := utils.PurgeTask(config.GetDB(), todo); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{sError: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{sMessage: config.STaskPurged, sTask: todo})
+}
+
+// trash returns the database restricted to the deleted tasks
+func trash() *gorm.DB {
+	return config.GetDB().Unscoped().Where("deleted_at IS NOT NULL")
+}
+
+// trashTask loads the deleted task (id parameter) if the user can perform the
+// action on it, otherwise responds with not found and returns false
+func trashTask(c *gin.Context, user model.User, action policy.Action) (model.Task, bool) {
+	var todo model.Task
+
+	if !policy.Find(trash(), user, action, &todo, c.Param("id")) {
+		c.JSON(http.StatusNotFound, gin.H{sMessage: config.STaskNotFound})
+		return todo, false
+	}
+
+	return todo, true
+}
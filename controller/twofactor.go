@@ -0,0 +1,171 @@
+package controller
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/giuliobosco/todoAPI/config"
+	"github.com/giuliobosco/todoAPI/model"
+	"github.com/giuliobosco/todoAPI/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+)
+
+// TwoFactorCode is the body of the enabling of the two-factor authentication
+type TwoFactorCode struct {
+	Code string `json:"code"` // TOTP code of the authenticator app
+}
+
+// TwoFactorConfirmation is the body of the changes of the two-factor
+// authentication, confirmed by the password and a TOTP or recovery code
+type TwoFactorConfirmation struct {
+	Password string `json:"password"` // password of the user
+	Code     string `json:"code"`     // TOTP or recovery code
+}
+
+// SetupTwoFactor is the function for generate the TOTP secret of the user,
+// with its otpauth URI for the QR code of the authenticator apps. The
+// two-factor authentication is enabled by EnableTwoFactor.
+func SetupTwoFactor(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	if user.TOTPEnabled {
+		c.JSON(http.StatusConflict, gin.H{sError: config.STwoFactorAlreadyEnabled})
+		return
+	}
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{sError: err.Error()})
+		return
+	}
+	if err := config.GetDB().Model(&user).Updates(map[string]interface{}{"totp_secret": secret, "totp_last_step": 0}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{sError: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"secret": secret, "uri": utils.TOTPURI(secret, user.Email)})
+}
+
+// EnableTwoFactor is the function for enable the two-factor authentication
+// with the first code of the authenticator app, responds with the recovery
+// codes
+func EnableTwoFactor(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	if user.TOTPEnabled {
+		c.JSON(http.StatusConflict, gin.H{sError: config.STwoFactorAlreadyEnabled})
+		return
+	}
+	if len(user.TOTPSecret) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{sError: config.STwoFactorNotSetUp})
+		return
+	}
+
+	var req TwoFactorCode
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{sError: err.Error()})
+		return
+	}
+	step, ok := utils.ValidateTOTP(user.TOTPSecret, req.Code, time.Now(), user.TOTPLastStep)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{sError: config.SInvalidTwoFactorCode})
+		return
+	}
+
+	var codes []string
+	err := config.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&user).Updates(map[string]interface{}{"totp_enabled": true, "totp_last_step": step}).Error; err != nil {
+			return err
+		}
+
+		var err error
+		codes, err = utils.NewRecoveryCodes(tx, user.ID)
+		return err
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{sError: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{sMessage: config.STwoFactorEnabled, config.SRecoveryCodes: codes})
+}
+
+// DisableTwoFactor is the function for disable the two-factor authentication,
+// confirmed by the password and a TOTP or recovery code
+func DisableTwoFactor(c *gin.Context) {
+	user, ok := twoFactorUser(c)
+	if !ok {
+		return
+	}
+
+	err := config.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&user).Updates(map[string]interface{}{"totp_enabled": false, "totp_secret": "", "totp_last_step": 0}).Error; err != nil {
+			return err
+		}
+
+		return tx.Where("user_id = ?", user.ID).Delete(&model.RecoveryCode{}).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{sError: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{sMessage: config.STwoFactorDisabled})
+}
+
+// RegenerateRecoveryCodes is the function for replace the recovery codes of
+// the user, confirmed by the password and a TOTP or recovery code
+func RegenerateRecoveryCodes(c *gin.Context) {
+	user, ok := twoFactorUser(c)
+	if !ok {
+		return
+	}
+
+	codes, err := utils.NewRecoveryCodes(config.GetDB(), user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{sError: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{sMessage: config.SRecoveryCodesCreated, config.SRecoveryCodes: codes})
+}
+
+// twoFactorUser loads the user with the two-factor authentication enabled and
+// authenticates it again by the password and a TOTP or recovery code,
+// otherwise responds with an error and returns false
+func twoFactorUser(c *gin.Context) (model.User, bool) {
+	user, ok := currentUser(c)
+	if !ok {
+		return user, false
+	}
+	if !user.TOTPEnabled {
+		c.JSON(http.StatusConflict, gin.H{sError: config.STwoFactorNotEnabled})
+		return user, false
+	}
+
+	var req TwoFactorConfirmation
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{sError: err.Error()})
+		return user, false
+	}
+	if len(req.Password) == 0 || len(req.Code) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{sError: config.SMissingPasswordCode})
+		return user, false
+	}
+	if !utils.ComparePasswordHash(user.Password, req.Password) {
+		c.JSON(http.StatusBadRequest, gin.H{sError: config.SWrongPassword})
+		return user, false
+	}
+	if !utils.VerifySecondFactor(config.GetDB(), user, req.Code) {
+		c.JSON(http.StatusBadRequest, gin.H{sError: config.SInvalidTwoFactorCode})
+		return user, false
+	}
+
+	return user, true
+}
@@ -0,0 +1,552 @@
+package controller
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/giuliobosco/todoAPI/config"
+	"github.com/giuliobosco/todoAPI/model"
+	"github.com/giuliobosco/todoAPI/policy"
+	"github.com/giuliobosco/todoAPI/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+)
+
+const sWorkspace string = config.SWorkspace
+
+// MemberRequest is the body of an invitation or of a role change of a member
+type MemberRequest struct {
+	Email string `json:"email"` // email of the invited user, only for the invitations
+	Role  string `json:"role"`  // admin, member (default) or guest
+}
+
+// CreateWorkspace is the function for create a workspace, the user becomes
+// its owner
+func CreateWorkspace(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var workspace model.Workspace
+	if err := c.ShouldBindJSON(&workspace); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{sError: err.Error()})
+		return
+	}
+	workspace.Name = strings.TrimSpace(workspace.Name)
+	if ok, err := utils.WorkspaceValidator(workspace); !ok {
+		c.JSON(http.StatusBadRequest, gin.H{sError: err.Error()})
+		return
+	}
+
+	workspace.Base = model.Base{}
+	workspace.UserID = user.ID
+	err := config.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&workspace).Error; err != nil {
+			return err
+		}
+
+		return tx.Save(&model.WorkspaceMember{WorkspaceID: workspace.ID, UserID: user.ID, Role: model.RoleOwner}).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{sError: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{sMessage: config.SWorkspaceCreated, sWorkspace: model.MemberWorkspace{Workspace: workspace, Role: model.RoleOwner}})
+}
+
+// FetchAllWorkspaces is the function for fetch the workspaces of the user,
+// with its role
+func FetchAllWorkspaces(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	workspaces := []model.MemberWorkspace{}
+	config.GetDB().Table("workspaces").
+		Select("workspaces.*, workspace_members.role").
+		Joins("JOIN workspace_members ON workspace_members.workspace_id = workspaces.id").
+		Where("workspace_members.user_id = ? AND workspaces.deleted_at IS NULL", user.ID).
+		Order("workspaces.name asc").
+		Scan(&workspaces)
+
+	c.JSON(http.StatusOK, gin.H{sData: workspaces})
+}
+
+// FetchSingleWorkspace is the function for fetch a workspace of the user by
+// id, with its role
+func FetchSingleWorkspace(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	workspace, member, ok := memberWorkspace(c, user)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, model.MemberWorkspace{Workspace: workspace, Role: member.Role})
+}
+
+// UpdateWorkspace is the function for rename a workspace, by its owner or
+// admins
+func UpdateWorkspace(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var newWorkspace model.Workspace
+	if err := c.ShouldBindJSON(&newWorkspace); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{sError: err.Error()})
+		return
+	}
+
+	workspace, _, ok := memberWorkspace(c, user, model.RoleOwner, model.RoleAdmin)
+	if !ok {
+		return
+	}
+
+	newWorkspace.Name = strings.TrimSpace(newWorkspace.Name)
+	if ok, err := utils.WorkspaceValidator(newWorkspace); !ok {
+		c.JSON(http.StatusBadRequest, gin.H{sError: err.Error()})
+		return
+	}
+
+	if err := config.GetDB().Model(&workspace).Update("name", newWorkspace.Name).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{sError: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{sMessage: config.SWorkspaceUpdated, sWorkspace: workspace})
+}
+
+// DeleteWorkspace is the function for delete a workspace, by its owner. The
+// tasks and the projects of the workspace are deleted, its members and its
+// invitations removed.
+func DeleteWorkspace(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	workspace, _, ok := memberWorkspace(c, user, model.RoleOwner)
+	if !ok {
+		return
+	}
+
+	err := config.GetDB().Transaction(func(tx *gorm.DB) error {
+		var todos []model.Task
+		if err := tx.Preload("Tags").Where("workspace_id = ?", workspace.ID).Find(&todos).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("workspace_id = ?", workspace.ID).Delete(&model.Task{}).Error; err != nil {
+			return err
+		}
+		for _, todo := range todos {
+			if err := utils.RecordTaskRevision(tx, user.ID, model.ActionDelete, &todo, todo); err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Exec("DELETE FROM shares WHERE resource_type = ? AND resource_id IN (SELECT id FROM projects WHERE workspace_id = ?)", model.ShareProject, workspace.ID).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("workspace_id = ?", workspace.ID).Delete(&model.Project{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("workspace_id = ?", workspace.ID).Delete(&model.WorkspaceMember{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("workspace_id = ?", workspace.ID).Delete(&model.WorkspaceInvitation{}).Error; err != nil {
+			return err
+		}
+
+		return tx.Delete(&workspace).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{sError: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{sMessage: config.SWorkspaceDeleted, sWorkspace: workspace})
+}
+
+// FetchWorkspaceMembers is the function for fetch the members of a workspace,
+// with their emails
+func FetchWorkspaceMembers(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	workspace, _, ok := memberWorkspace(c, user)
+	if !ok {
+		return
+	}
+
+	members := []model.WorkspaceMember{}
+	config.GetDB().Table("workspace_members").
+		Select("workspace_members.*, users.email").
+		Joins("JOIN users ON users.id = workspace_members.user_id").
+		Where("workspace_members.workspace_id = ?", workspace.ID).
+		Order("workspace_members.created_at asc").
+		Scan(&members)
+
+	c.JSON(http.StatusOK, gin.H{sData: members})
+}
+
+// UpdateWorkspaceMember is the function for change the role of a member of a
+// workspace (member parameter), by the owner or the admins. Only the owner
+// can manage the admins, the role of the owner can not be changed.
+func UpdateWorkspaceMember(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var req MemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{sError: err.Error()})
+		return
+	}
+
+	workspace, actor, ok := memberWorkspace(c, user, model.RoleOwner, model.RoleAdmin)
+	if !ok {
+		return
+	}
+	if !validMemberRole(c, req.Role) {
+		return
+	}
+
+	member, ok := workspaceMember(c, workspace)
+	if !ok {
+		return
+	}
+	if member.Role == model.RoleOwner {
+		c.JSON(http.StatusBadRequest, gin.H{sError: config.SWorkspaceOwnerRole})
+		return
+	}
+	if actor.Role != model.RoleOwner && (member.Role == model.RoleAdmin || req.Role == model.RoleAdmin) {
+		c.JSON(http.StatusForbidden, gin.H{sError: config.SWorkspaceForbidden})
+		return
+	}
+
+	if err := config.GetDB().Model(&member).Update("role", req.Role).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{sError: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{sMessage: config.SMemberUpdated, config.SMember: member})
+}
+
+// RemoveWorkspaceMember is the function for remove a member from a workspace
+// (member parameter), by the owner or the admins, or by the member itself to
+// leave the workspace. Only the owner can remove the admins, the owner can
+// not leave its workspace.
+func RemoveWorkspaceMember(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	workspace, actor, ok := memberWorkspace(c, user)
+	if !ok {
+		return
+	}
+
+	member, ok := workspaceMember(c, workspace)
+	if !ok {
+		return
+	}
+	if member.Role == model.RoleOwner {
+		c.JSON(http.StatusBadRequest, gin.H{sError: config.SWorkspaceOwnerRole})
+		return
+	}
+	if member.UserID != user.ID && actor.Role != model.RoleOwner && (actor.Role != model.RoleAdmin || member.Role == model.RoleAdmin) {
+		c.JSON(http.StatusForbidden, gin.H{sError: config.SWorkspaceForbidden})
+		return
+	}
+
+	if err := config.GetDB().Unscoped().Delete(&member).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{sError: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{sMessage: config.SMemberRemoved, config.SMember: member})
+}
+
+// InviteWorkspaceMember is the function for invite by email a user in a
+// workspace, by the owner or the admins. Only the owner can invite admins,
+// inviting again the same email replaces the invitation.
+func InviteWorkspaceMember(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var req MemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{sError: err.Error()})
+		return
+	}
+
+	workspace, actor, ok := memberWorkspace(c, user, model.RoleOwner, model.RoleAdmin)
+	if !ok {
+		return
+	}
+
+	req.Email = strings.TrimSpace(req.Email)
+	if len(req.Email) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{sError: "Missing: email"})
+		return
+	}
+	if len(req.Role) == 0 {
+		req.Role = model.RoleMember
+	}
+	if !validMemberRole(c, req.Role) {
+		return
+	}
+	if actor.Role != model.RoleOwner && req.Role == model.RoleAdmin {
+		c.JSON(http.StatusForbidden, gin.H{sError: config.SWorkspaceForbidden})
+		return
+	}
+
+	var members int
+	config.GetDB().Model(&model.WorkspaceMember{}).
+		Joins("JOIN users ON users.id = workspace_members.user_id").
+		Where("workspace_members.workspace_id = ? AND users.email = ?", workspace.ID, req.Email).
+		Count(&members)
+	if members > 0 {
+		c.JSON(http.StatusConflict, gin.H{sError: config.SWorkspaceAlreadyMember})
+		return
+	}
+
+	invitation := model.WorkspaceInvitation{WorkspaceID: workspace.ID, Email: req.Email}
+	status := http.StatusCreated
+	config.GetDB().Where(invitation).First(&invitation)
+	if invitation.ID > 0 {
+		status = http.StatusOK
+	}
+
+	token, err := utils.GenerateRandomStringURLSafe(config.TokenLength)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{sError: err.Error()})
+		return
+	}
+	invitation.Role = req.Role
+	invitation.Token = token
+	invitation.InvitedBy = user.ID
+	invitation.ExpiresAt = time.Now().Add(config.InvitationValidity)
+	if err := config.GetDB().Save(&invitation).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{sError: err.Error()})
+		return
+	}
+
+	utils.WorkspaceInvitationSendMail(invitation, workspace, user)
+
+	c.JSON(status, gin.H{sMessage: config.SInvitationSent, config.SInvitation: invitation})
+}
+
+// FetchWorkspaceInvitations is the function for fetch the pending invitations
+// of a workspace, by the owner or the admins
+func FetchWorkspaceInvitations(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	workspace, _, ok := memberWorkspace(c, user, model.RoleOwner, model.RoleAdmin)
+	if !ok {
+		return
+	}
+
+	invitations := []model.WorkspaceInvitation{}
+	config.GetDB().Where("workspace_id = ?", workspace.ID).Order("created_at asc").Find(&invitations)
+
+	c.JSON(http.StatusOK, gin.H{sData: invitations})
+}
+
+// DeleteWorkspaceInvitation is the function for cancel an invitation
+// (invitation parameter) in a workspace, by the owner or the admins
+func DeleteWorkspaceInvitation(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	workspace, _, ok := memberWorkspace(c, user, model.RoleOwner, model.RoleAdmin)
+	if !ok {
+		return
+	}
+
+	var invitation model.WorkspaceInvitation
+	if err := config.GetDB().Where("workspace_id = ? AND id = ?", workspace.ID, c.Param("invitation")).First(&invitation).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{sMessage: config.SInvitationNotFound})
+		return
+	}
+
+	config.GetDB().Unscoped().Delete(&invitation)
+
+	c.JSON(http.StatusOK, gin.H{sMessage: config.SInvitationDeleted, config.SInvitation: invitation})
+}
+
+// JoinWorkspace is the function for accept the invitation of the token query
+// parameter, the invitation must be sent to the email of the user and not be
+// expired
+func JoinWorkspace(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	token := c.Query("token")
+	if len(token) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{sError: "Missing: token"})
+		return
+	}
+
+	var invitation model.WorkspaceInvitation
+	err := config.GetDB().Where("token = ? AND expires_at > ?", token, time.Now()).First(&invitation).Error
+	if err != nil || !strings.EqualFold(invitation.Email, user.Email) {
+		c.JSON(http.StatusNotFound, gin.H{sMessage: config.SInvitationNotFound})
+		return
+	}
+
+	var workspace model.Workspace
+	if err := config.GetDB().Where("id = ?", invitation.WorkspaceID).First(&workspace).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{sMessage: config.SWorkspaceNotFound})
+		return
+	}
+
+	var members int
+	config.GetDB().Model(&model.WorkspaceMember{}).Where("workspace_id = ? AND user_id = ?", workspace.ID, user.ID).Count(&members)
+	if members > 0 {
+		c.JSON(http.StatusConflict, gin.H{sError: config.SWorkspaceAlreadyMember})
+		return
+	}
+
+	err = config.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&model.WorkspaceMember{WorkspaceID: workspace.ID, UserID: user.ID, Role: invitation.Role}).Error; err != nil {
+			return err
+		}
+
+		return tx.Unscoped().Delete(&invitation).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{sError: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{sMessage: config.SWorkspaceJoined, sWorkspace: model.MemberWorkspace{Workspace: workspace, Role: invitation.Role}})
+}
+
+// memberWorkspace loads the workspace (id parameter) of the user and its
+// membership, responds with not found if the user is not a member and with
+// forbidden if its role is not one of the roles, when given
+func memberWorkspace(c *gin.Context, user model.User, roles ...string) (model.Workspace, model.WorkspaceMember, bool) {
+	var workspace model.Workspace
+	var member model.WorkspaceMember
+
+	if !policy.Find(config.GetDB(), user, policy.Read, &workspace, c.Param("id")) {
+		c.JSON(http.StatusNotFound, gin.H{sMessage: config.SWorkspaceNotFound})
+		return workspace, member, false
+	}
+
+	config.GetDB().Where("workspace_id = ? AND user_id = ?", workspace.ID, user.ID).First(&member)
+	if len(roles) == 0 {
+		return workspace, member, true
+	}
+	for _, role := range roles {
+		if member.Role == role {
+			return workspace, member, true
+		}
+	}
+
+	c.JSON(http.StatusForbidden, gin.H{sError: config.SWorkspaceForbidden})
+	return workspace, member, false
+}
+
+// workspaceMember loads the member (member parameter) of the workspace,
+// otherwise responds with not found and returns false
+func workspaceMember(c *gin.Context, workspace model.Workspace) (model.WorkspaceMember, bool) {
+	var member model.WorkspaceMember
+
+	if err := config.GetDB().Where("workspace_id = ? AND id = ?", workspace.ID, c.Param("member")).First(&member).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{sMessage: config.SMemberNotFound})
+		return member, false
+	}
+
+	return member, true
+}
+
+// validMemberRole checks that the role can be given to a member, the owner
+// role is not transferable, otherwise responds with bad request and returns
+// false
+func validMemberRole(c *gin.Context, role string) bool {
+	if role != model.RoleAdmin && role != model.RoleMember && role != model.RoleGuest {
+		c.JSON(http.StatusBadRequest, gin.H{sError: config.SWorkspaceInvalidRole})
+		return false
+	}
+
+	return true
+}
+
+// activeWorkspace returns the id of the workspace of the X-Workspace-ID
+// header, nil without the header for the personal tasks and projects. Responds
+// with not found and returns false if the user is not a member of the
+// workspace, with forbidden if its role does not allow the action.
+func activeWorkspace(c *gin.Context, user model.User, action policy.Action) (*uint, bool) {
+	id := c.GetHeader(config.WorkspaceHeader)
+	if len(id) == 0 {
+		return nil, true
+	}
+
+	var workspace model.Workspace
+	if !policy.Find(config.GetDB(), user, policy.Read, &workspace, id) {
+		c.JSON(http.StatusNotFound, gin.H{sMessage: config.SWorkspaceNotFound})
+		return nil, false
+	}
+	var allowed model.Workspace
+	if action != policy.Read && !policy.Find(config.GetDB(), user, action, &allowed, id) {
+		c.JSON(http.StatusForbidden, gin.H{sError: config.SWorkspaceForbidden})
+		return nil, false
+	}
+
+	return &workspace.ID, true
+}
+
+// workspaceTasks restricts db to the tasks of the workspace the user can
+// read, or to the personal tasks of the user if workspaceID is nil
+func workspaceTasks(db *gorm.DB, user model.User, workspaceID *uint) *gorm.DB {
+	if workspaceID == nil {
+		return policy.Scope(db, user, policy.Read).Where("workspace_id IS NULL")
+	}
+
+	return policy.ScopeTasks(db, user, policy.Read).Where("workspace_id = ?", *workspaceID)
+}
+
+// workspaceProjects restricts db to the projects of the workspace the user can
+// read, or to the personal projects of the user if workspaceID is nil
+func workspaceProjects(db *gorm.DB, user model.User, workspaceID *uint) *gorm.DB {
+	if workspaceID == nil {
+		return policy.Scope(db, user, policy.Read).Where("workspace_id IS NULL")
+	}
+
+	return policy.ScopeProjects(db, user, policy.Read).Where("workspace_id = ?", *workspaceID)
+}
+
+// sameWorkspace returns true if the two workspace ids are equal, nil for the
+// personal tasks and projects
+func sameWorkspace(a *uint, b *uint) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+
+	return *a == *b
+}
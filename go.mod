@@ -1,14 +1,28 @@
 module github.com/giuliobosco/todoAPI
 
-go 1.12
+go 1.19
 
 require (
-	github.com/Selvatico/go-mocket v1.0.7 // indirect
-	github.com/appleboy/gin-jwt/v2 v2.6.3
 	github.com/badoux/checkmail v0.0.0-20181210160741-9661bd69e9ad
 	github.com/gin-gonic/gin v1.4.0
+	github.com/golang-jwt/jwt/v4 v4.5.2
 	github.com/jinzhu/gorm v1.9.12
 	github.com/selvatico/go-mocket v1.0.7
 	github.com/stretchr/testify v1.4.0
 	golang.org/x/crypto v0.0.0-20191205180655-e7c4368fe9dd
+	gopkg.in/yaml.v2 v2.2.7
+)
+
+require (
+	github.com/Selvatico/go-mocket v1.0.7 // indirect
+	github.com/davecgh/go-spew v1.1.0 // indirect
+	github.com/gin-contrib/sse v0.0.0-20190301062529-5545eab6dad3 // indirect
+	github.com/golang/protobuf v1.3.1 // indirect
+	github.com/jinzhu/inflection v1.0.0 // indirect
+	github.com/lib/pq v1.1.1 // indirect
+	github.com/mattn/go-isatty v0.0.7 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	github.com/ugorji/go v1.1.4 // indirect
+	golang.org/x/sys v0.0.0-20190412213103-97732733099d // indirect
+	gopkg.in/go-playground/validator.v8 v8.18.2 // indirect
 )
@@ -3,19 +3,31 @@ package main
 
 import (
 	"log"
-	"os"
 
+	"github.com/giuliobosco/todoAPI/auth"
 	"github.com/giuliobosco/todoAPI/config"
 	"github.com/giuliobosco/todoAPI/migration"
 	"github.com/giuliobosco/todoAPI/route"
+	"github.com/giuliobosco/todoAPI/utils"
 
 	"github.com/gin-gonic/gin"
 )
 
 // Init initialize the application
 func init() {
+	settings, err := config.Load()
+	if err != nil {
+		log.Fatalf("error: %s", err)
+	}
+	log.Printf("configuration: %s", settings)
+
 	db := config.Init()
 	migration.Migrate(db)
+	config.InitStore()
+	utils.StartTrashPurger(db, settings.Trash.Retention, settings.Trash.PurgeInterval)
+	if _, err := auth.StartKeyRotation(db, config.KeyRotationCheckInterval); err != nil {
+		log.Fatalf("error: %s", err)
+	}
 }
 
 // main starts the app.
@@ -24,11 +36,7 @@ func main() {
 
 	router := route.SetupRoutes()
 
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
-	}
-	if err := router.Run(":" + port); err != nil {
+	if err := router.Run(":" + config.Get().Port); err != nil {
 		log.Panicf("error: %s", err)
 	}
 }
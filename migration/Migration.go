@@ -2,6 +2,7 @@ package migration
 
 import (
 	"github.com/giuliobosco/todoAPI/model"
+	"github.com/giuliobosco/todoAPI/utils"
 
 	"github.com/jinzhu/gorm"
 )
@@ -10,4 +11,26 @@ import (
 func Migrate(db *gorm.DB) {
 	db.AutoMigrate(&model.Task{})
 	db.AutoMigrate(&model.User{})
+	db.AutoMigrate(&model.Tag{})
+	db.AutoMigrate(&model.Project{})
+	db.AutoMigrate(&model.TaskRevision{})
+	db.AutoMigrate(&model.Comment{})
+	db.AutoMigrate(&model.Attachment{})
+	db.AutoMigrate(&model.Share{})
+	db.AutoMigrate(&model.Assignee{})
+	db.AutoMigrate(&model.TaskDependency{})
+	db.AutoMigrate(&model.Workspace{})
+	db.AutoMigrate(&model.WorkspaceMember{})
+	db.AutoMigrate(&model.WorkspaceInvitation{})
+	db.AutoMigrate(&model.Session{})
+	db.AutoMigrate(&model.RefreshToken{})
+	db.AutoMigrate(&model.SigningKey{})
+	db.AutoMigrate(&model.AccessToken{})
+	db.AutoMigrate(&model.RecoveryCode{})
+	db.AutoMigrate(&model.LoginChallenge{})
+
+	// the full-text search index is only available on PostgreSQL
+	if db.Dialect().GetName() == "postgres" {
+		db.Exec(utils.TaskSearchIndex)
+	}
 }
@@ -2,34 +2,389 @@
 package model
 
 import (
+	"encoding/json"
 	"time"
 )
 
 // User is the rapresentation of the user
 type User struct {
-	Base               // use base object as parent
-	Email       string `json:"email"`     // username of the user
-	Password    string `json:"password"`  // password of the user
-	Firstname   string `json:"firstname"` // firstname of the user
-	Lastname    string `json:"lastname"`  // lastname of the user
-	VerifyToken string // verifyToken of the user
-	Active      bool   `json:"active"` // active flag of the user
-	Todos       []Task `json:"todos"`  // list of the todos of the user
+	Base                       // use base object as parent
+	Email           string     `json:"email"`     // username of the user
+	Password        string     `json:"password"`  // password of the user
+	Firstname       string     `json:"firstname"` // firstname of the user
+	Lastname        string     `json:"lastname"`  // lastname of the user
+	VerifyToken     string     // verifyToken of the user
+	Active          bool       `json:"active"`       // active flag of the user
+	Todos           []Task     `json:"todos"`        // list of the todos of the user
+	TOTPSecret      string     `json:"-"`            // base32 TOTP secret of the two-factor authentication
+	TOTPEnabled     bool       `json:"totp_enabled"` // two-factor authentication enabled flag of the user
+	TOTPLastStep    int64      `json:"-"`            // time step of the last used TOTP code, refused again
+	TOTPFailures    int        `json:"-"`            // number of wrong codes since the last lockout or login
+	TOTPLockedUntil *time.Time `json:"-"`            // end of the lockout of the second step of the login, nil if not locked
+}
+
+// RecoveryCode is a single use code replacing a TOTP code, only its hash is
+// stored
+type RecoveryCode struct {
+	ID        uint       `gorm:"primary_key" json:"id"` // id of the code
+	CreatedAt time.Time  `json:"created_at"`            // time of the code creation
+	UserID    uint       `gorm:"index" json:"userid"`   // id of the user of the code
+	CodeHash  string     `json:"-"`                     // SHA-256 hash of the code
+	UsedAt    *time.Time `json:"used_at"`               // time of the use, nil if not used
 }
 
+// LoginChallenge is the second step of the login of a user with the
+// two-factor authentication, completed with a TOTP or recovery code
+type LoginChallenge struct {
+	ID        uint      `gorm:"primary_key" json:"id"` // id of the challenge
+	CreatedAt time.Time `json:"created_at"`            // time of the password verification
+	UserID    uint      `gorm:"index" json:"userid"`   // id of the user logging in
+	TokenHash string    `gorm:"unique_index" json:"-"` // SHA-256 hash of the challenge token
+	ExpiresAt time.Time `json:"expires_at"`            // expiration of the challenge
+	Attempts  int       `json:"attempts"`              // number of codes tried
+}
+
+// Session is a login of a user on a device, renewed with refresh tokens
+// until it expires or it is revoked
+type Session struct {
+	Base
+	UserID     uint       `gorm:"index" json:"userid"` // id of the logged user
+	UserAgent  string     `json:"user_agent"`          // user agent of the login
+	IP         string     `json:"ip"`                  // ip address of the last use
+	LastUsedAt time.Time  `json:"last_used_at"`        // time of the last refresh
+	ExpiresAt  time.Time  `json:"expires_at"`          // expiration of the session without refreshes
+	RevokedAt  *time.Time `json:"revoked_at"`          // revocation time, nil for an active session
+	Current    bool       `gorm:"-" json:"current"`    // true for the session of the request, only in the listings
+}
+
+// RefreshToken is a single use token renewing a session, only its hash is
+// stored
+type RefreshToken struct {
+	ID        uint       `gorm:"primary_key" json:"id"`   // id of the token
+	CreatedAt time.Time  `json:"created_at"`              // time of the token creation
+	SessionID uint       `gorm:"index" json:"session_id"` // id of the renewed session
+	TokenHash string     `gorm:"unique_index" json:"-"`   // SHA-256 hash of the token
+	UsedAt    *time.Time `json:"used_at"`                 // time of the use, nil for the current token of the session
+}
+
+// Scopes of the personal access tokens, the write scopes include the read
+// scopes
+const (
+	ScopeTasksRead    = "tasks:read"    // read the tasks, projects, tags and workspaces
+	ScopeTasksWrite   = "tasks:write"   // create, update and delete the tasks, projects, tags and workspaces
+	ScopeProfileRead  = "profile:read"  // read the profile of the user
+	ScopeProfileWrite = "profile:write" // update the profile of the user
+)
+
+// AccessToken is a personal access token of a user for the scripts and the
+// integrations, only its hash is stored. Deleting it revokes it.
+type AccessToken struct {
+	Base
+	UserID     uint       `gorm:"index" json:"userid"`   // id of the owner of the token
+	Name       string     `json:"name"`                  // name of the token
+	Prefix     string     `json:"prefix"`                // first characters of the token, to recognize it
+	TokenHash  string     `gorm:"unique_index" json:"-"` // SHA-256 hash of the token
+	Scopes     string     `json:"scopes"`                // space separated scopes of the token, see Scope* constants
+	ExpiresAt  *time.Time `json:"expires_at"`            // expiration of the token, nil if it does not expire
+	LastUsedAt *time.Time `json:"last_used_at"`          // time of the last use, nil if never used
+}
+
+// SigningKey is an RS256 or EdDSA key signing the access tokens, identified
+// in the tokens by its kid
+type SigningKey struct {
+	ID         uint       `gorm:"primary_key" json:"id"`   // id of the key
+	CreatedAt  time.Time  `json:"created_at"`              // time of the key creation
+	KID        string     `gorm:"unique_index" json:"kid"` // key id of the tokens header
+	Algorithm  string     `json:"algorithm"`               // signing algorithm of the key
+	PrivateKey []byte     `json:"-"`                       // PKCS #1 RSA or Ed25519 private key, encrypted with AES-GCM
+	RotatedAt  *time.Time `json:"rotated_at"`              // time of the replacement by a new key, nil for the signing key
+}
+
+// Priorities of the tasks, from the lowest to the highest
+const (
+	PriorityNone   = 0 // task without priority
+	PriorityLow    = 1 // low priority task
+	PriorityMedium = 2 // medium priority task
+	PriorityHigh   = 3 // high priority task
+)
+
 // Task is the rappresentation of a task
 type Task struct {
-	Base               // user base object as parent
-	Title       string `json:"title"`       // title of the task
-	Description string `json:"description"` // description of the task
-	UserID      uint   `json:"userid"`      // id of the user owner of the task
-	Completed   bool   `json:"completed"`   // completed task if true
+	Base                    // user base object as parent
+	Title        string     `json:"title"`                                                                                     // title of the task
+	Description  string     `json:"description"`                                                                               // description of the task
+	UserID       uint       `gorm:"index:idx_task_user_due" json:"userid"`                                                     // id of the user owner of the task
+	Completed    bool       `json:"completed"`                                                                                 // completed task if true
+	Priority     int        `json:"priority"`                                                                                  // priority of the task, see Priority* constants
+	StartAt      *time.Time `json:"start_at"`                                                                                  // start time of the task (timestamp with time zone)
+	DueAt        *time.Time `gorm:"index:idx_task_user_due" json:"due_at"`                                                     // due time of the task (timestamp with time zone)
+	Recurrence   string     `json:"recurrence"`                                                                                // RFC 5545 recurrence rule of the task, empty if not recurrent
+	Occurrence   int        `json:"occurrence"`                                                                                // position of the task in its recurrence, starting from 1
+	NextID       *uint      `json:"next_id"`                                                                                   // id of the next occurrence, created when the task was completed
+	ParentID     *uint      `gorm:"index" json:"parent_id"`                                                                    // id of the parent task, nil for a top level task
+	ProjectID    *uint      `gorm:"index" json:"project_id"`                                                                   // id of the project of the task, nil for the inbox
+	WorkspaceID  *uint      `gorm:"index" json:"workspace_id"`                                                                 // id of the workspace of the task, nil for the personal tasks
+	Tags         []Tag      `gorm:"many2many:task_tags;association_autoupdate:false;association_autocreate:false" json:"tags"` // tags of the task
+	CommentCount int        `gorm:"-" json:"comment_count"`                                                                    // number of comments of the task, only in the listings
+	Assignees    []Assignee `gorm:"-" json:"assignees"`                                                                        // users responsible for the task
+}
+
+// Tag is the rappresentation of a label of the tasks
+type Tag struct {
+	Base          // use base object as parent
+	Name   string `gorm:"unique_index:idx_tag_user_name" json:"name"`   // name of the tag, unique for the user
+	Color  string `json:"color"`                                        // color of the tag
+	UserID uint   `gorm:"unique_index:idx_tag_user_name" json:"userid"` // id of the user owner of the tag
+}
+
+// TaskNode is a task with its subtasks
+type TaskNode struct {
+	Task                // task
+	Children []TaskNode `json:"children"` // subtasks of the task
+}
+
+// SearchResult is a task matching a search
+type SearchResult struct {
+	Task            // task
+	Rank    float64 `json:"rank"`    // relevance of the task for the search
+	Snippet string  `json:"snippet"` // text of the task around the matches, highlighted
+}
+
+// Project is the rappresentation of a list grouping tasks
+type Project struct {
+	Base               // use base object as parent
+	Name        string `json:"name"`                      // name of the project
+	Color       string `json:"color"`                     // color of the project
+	Archived    bool   `json:"archived"`                  // archived project if true
+	SortOrder   int    `json:"sort_order"`                // position of the project in the list of the user
+	UserID      uint   `gorm:"index" json:"userid"`       // id of the user owner of the project
+	WorkspaceID *uint  `gorm:"index" json:"workspace_id"` // id of the workspace of the project, nil for the personal projects
+}
+
+// Comment is the rappresentation of a comment on a task
+type Comment struct {
+	Base                // use base object as parent
+	TaskID   uint       `gorm:"index" json:"task_id"`  // id of the commented task
+	UserID   uint       `gorm:"index" json:"userid"`   // id of the user author of the comment
+	Body     string     `gorm:"type:text" json:"body"` // text of the comment
+	EditedAt *time.Time `json:"edited_at"`             // last edit of the body, null if never edited
+}
+
+// Attachment is the rappresentation of a file attached to a task, the content
+// is in the store of the attachments
+type Attachment struct {
+	Base               // use base object as parent
+	TaskID      uint   `gorm:"index" json:"task_id"`  // id of the task
+	UserID      uint   `gorm:"index" json:"userid"`   // id of the user that uploaded the file, charged in its quota
+	Filename    string `json:"filename"`              // name of the uploaded file
+	ContentType string `json:"content_type"`          // detected content type of the file
+	Size        int64  `json:"size"`                  // size in bytes of the file
+	StorageKey  string `gorm:"unique_index" json:"-"` // key of the content in the store
+}
+
+// Assignee is a user responsible for a task
+type Assignee struct {
+	Base
+	TaskID     uint   `gorm:"unique_index:idx_assignee_task_user" json:"task_id"` // id of the task
+	UserID     uint   `gorm:"unique_index:idx_assignee_task_user" json:"userid"`  // id of the assigned user
+	Email      string `gorm:"-" json:"email,omitempty"`                           // email of the assigned user
+	AssignedBy uint   `json:"assigned_by"`                                        // id of the user that assigned the task
+}
+
+// TaskDependency is a task blocked by another task, the blocked task can not
+// be completed until the blocker is completed
+type TaskDependency struct {
+	Base
+	TaskID    uint `gorm:"unique_index:idx_dependency_task_blocker" json:"task_id"`          // id of the blocked task
+	BlockerID uint `gorm:"unique_index:idx_dependency_task_blocker;index" json:"blocker_id"` // id of the blocking task
+}
+
+// TaskGraph is the dependency graph of the open tasks
+type TaskGraph struct {
+	Tasks     []Task           `json:"tasks"`     // open tasks
+	Edges     []TaskDependency `json:"edges"`     // dependencies of the open tasks on open blockers
+	Order     []uint           `json:"order"`     // ids of the tasks, each after its blockers
+	Available []Task           `json:"available"` // tasks without open blockers, in order
+}
+
+// Roles of the members of a workspace
+const (
+	RoleOwner  = "owner"  // can do everything, including deleting the workspace
+	RoleAdmin  = "admin"  // can manage the members and all the tasks and projects
+	RoleMember = "member" // can read and update all the tasks and projects
+	RoleGuest  = "guest"  // can only read the tasks and projects
+)
+
+// Workspace is the rappresentation of a team sharing its projects and tasks
+type Workspace struct {
+	Base
+	Name   string `json:"name"`                // name of the workspace
+	UserID uint   `gorm:"index" json:"userid"` // id of the user that created the workspace
+}
+
+// WorkspaceMember is the membership of a user in a workspace
+type WorkspaceMember struct {
+	Base
+	WorkspaceID uint   `gorm:"unique_index:idx_member_workspace_user" json:"workspace_id"` // id of the workspace
+	UserID      uint   `gorm:"unique_index:idx_member_workspace_user" json:"userid"`       // id of the member
+	Email       string `gorm:"-" json:"email,omitempty"`                                   // email of the member, only in the listings
+	Role        string `json:"role"`                                                       // role of the member, see Role* constants
+}
+
+// WorkspaceInvitation is the invitation of an email address in a workspace
+type WorkspaceInvitation struct {
+	Base
+	WorkspaceID uint      `gorm:"index" json:"workspace_id"` // id of the workspace
+	Email       string    `json:"email"`                     // invited email address
+	Role        string    `json:"role"`                      // role of the member once joined
+	Token       string    `gorm:"unique_index" json:"-"`     // secret token of the invitation, sent by email
+	InvitedBy   uint      `json:"invited_by"`                // id of the user that sent the invitation
+	ExpiresAt   time.Time `json:"expires_at"`                // expiration of the invitation
+}
+
+// MemberWorkspace is a workspace with the role of a member
+type MemberWorkspace struct {
+	Workspace        // workspace
+	Role      string `json:"role"` // role of the member
+}
+
+// Types of the shared resources
+const (
+	ShareTask    = "task"
+	ShareProject = "project"
+)
+
+// Roles of the users a resource is shared with
+const (
+	ShareViewer = "viewer" // can read the resource
+	ShareEditor = "editor" // can read and update the resource
+)
+
+// Share is the access of a user to a task or a project of another user, the
+// tasks of a shared project are shared too
+type Share struct {
+	Base
+	ResourceType string `gorm:"unique_index:idx_share_resource_user" json:"resource_type"` // type of the shared resource, see Share* constants
+	ResourceID   uint   `gorm:"unique_index:idx_share_resource_user" json:"resource_id"`   // id of the shared resource
+	UserID       uint   `gorm:"unique_index:idx_share_resource_user" json:"userid"`        // id of the user the resource is shared with
+	Email        string `gorm:"-" json:"email,omitempty"`                                  // email of the user the resource is shared with, only in the listings
+	SharedBy     uint   `json:"shared_by"`                                                 // id of the user that shared the resource
+	Role         string `json:"role"`                                                      // role of the user, viewer or editor
+}
+
+// SharedTask is a task shared with a user, with the role of the user
+type SharedTask struct {
+	Task        // shared task
+	Role string `json:"role"` // role of the user
+}
+
+// SharedProject is a project shared with a user, with the role of the user
+type SharedProject struct {
+	Project        // shared project
+	Role    string `json:"role"` // role of the user
+}
+
+// TagCount is a tag with the number of its tasks
+type TagCount struct {
+	Tag           // tag
+	TaskCount int `json:"task_count"` // number of tasks with the tag
+}
+
+// Actions of the task revisions
+const (
+	ActionCreate   = "create"
+	ActionUpdate   = "update"
+	ActionComplete = "complete"
+	ActionDelete   = "delete"
+	ActionRestore  = "restore"
+	ActionRevert   = "revert"
+)
+
+// FieldChange is the change of a field of an object
+type FieldChange struct {
+	Old interface{} `json:"old"` // value before the change
+	New interface{} `json:"new"` // value after the change
+}
+
+// TaskRevision is an immutable entry of the history of a task, with the
+// changed fields and the state of the task after the change
+type TaskRevision struct {
+	ID           uint                   `gorm:"primary_key" json:"id"`                                   // id of the revision
+	CreatedAt    time.Time              `json:"created_at"`                                              // time of the change
+	TaskID       uint                   `gorm:"not null;unique_index:idx_revision_task" json:"task_id"`  // id of the changed task
+	Revision     uint                   `gorm:"not null;unique_index:idx_revision_task" json:"revision"` // number of the revision, from 1 for each task
+	UserID       uint                   `json:"user_id"`                                                 // id of the user that changed the task
+	Action       string                 `json:"action"`                                                  // create, update, complete, delete, restore or revert
+	Changes      map[string]FieldChange `gorm:"-" json:"changes"`                                        // changed fields, by name
+	Task         Task                   `gorm:"-" json:"task"`                                           // state of the task after the change
+	ChangesJSON  string                 `gorm:"column:changes;type:text" json:"-"`                       // stored changes
+	SnapshotJSON string                 `gorm:"column:snapshot;type:text" json:"-"`                      // stored state of the task
+}
+
+// BeforeSave stores the changes and the state of the task as JSON
+func (r *TaskRevision) BeforeSave() error {
+	changes, err := json.Marshal(r.Changes)
+	if err != nil {
+		return err
+	}
+	snapshot, err := json.Marshal(r.Task)
+	if err != nil {
+		return err
+	}
+
+	r.ChangesJSON, r.SnapshotJSON = string(changes), string(snapshot)
+	return nil
+}
+
+// AfterFind loads the changes and the state of the task from JSON
+func (r *TaskRevision) AfterFind() error {
+	if len(r.ChangesJSON) > 0 {
+		if err := json.Unmarshal([]byte(r.ChangesJSON), &r.Changes); err != nil {
+			return err
+		}
+	}
+	if len(r.SnapshotJSON) > 0 {
+		return json.Unmarshal([]byte(r.SnapshotJSON), &r.Task)
+	}
+
+	return nil
 }
 
 // Base is the basic object with basic components
 type Base struct {
-	ID        uint       `gorm:"primary_key" json:"id"` // id of the object
-	CreatedAt time.Time  `json:"created_at"`            // object creation time
-	UpdatedAt time.Time  `json:"updated_at"`            // object updating time
-	DeletedAt *time.Time `json:"deleted_at"`            // object deleting time
+	ID        uint       `gorm:"primary_key" json:"id"`             // id of the object
+	CreatedAt time.Time  `json:"created_at"`                        // object creation time
+	UpdatedAt time.Time  `json:"updated_at"`                        // object updating time
+	DeletedAt *time.Time `json:"deleted_at"`                        // object deleting time
+	Version   uint       `gorm:"not null;default:1" json:"version"` // object version, incremented by each update
+}
+
+// OwnerID returns the id of the user owner of the task
+func (t Task) OwnerID() uint {
+	return t.UserID
+}
+
+// OwnerID returns the id of the user owner of the tag
+func (t Tag) OwnerID() uint {
+	return t.UserID
+}
+
+// OwnerID returns the id of the user owner of the project
+func (p Project) OwnerID() uint {
+	return p.UserID
+}
+
+// OwnerID returns the id of the user author of the comment
+func (c Comment) OwnerID() uint {
+	return c.UserID
+}
+
+// OwnerID returns the id of the user that uploaded the attachment
+func (a Attachment) OwnerID() uint {
+	return a.UserID
+}
+
+// OwnerID returns the id of the user that created the workspace
+func (w Workspace) OwnerID() uint {
+	return w.UserID
 }
@@ -0,0 +1,224 @@
+// Package policy handle the authorization of the users on the resources of
+// the API Engine.
+package policy
+
+import (
+	"github.com/giuliobosco/todoAPI/model"
+
+	"github.com/jinzhu/gorm"
+)
+
+// Action is an operation of a user on a resource
+type Action int
+
+const (
+	// Read is the action of fetching a resource
+	Read Action = iota
+	// Update is the action of modifying a resource
+	Update
+	// Delete is the action of deleting a resource
+	Delete
+	// Share is the action of sharing a resource with other users
+	Share
+)
+
+// sharedIDs selects the ids of the resources of a type shared with a user
+// with one of the roles
+const sharedIDs = "SELECT resource_id FROM shares WHERE resource_type = ? AND user_id = ? AND role IN (?)"
+
+// memberWorkspaceIDs selects the ids of the workspaces of a member with one of
+// the roles
+const memberWorkspaceIDs = "SELECT workspace_id FROM workspace_members WHERE user_id = ? AND role IN (?)"
+
+// Owned is a resource belonging to a user
+type Owned interface {
+	OwnerID() uint // id of the user owner of the resource
+}
+
+// Can returns true if the user can perform the action on the resource as its
+// owner. The tasks and projects of a workspace have no owner, the access to
+// them depends only on the current role of the user in the workspace.
+func Can(user model.User, action Action, resource Owned) bool {
+	return user.ID != 0 && resource.OwnerID() == user.ID && workspaceOf(resource) == nil
+}
+
+// workspaceOf returns the id of the workspace of the task or the project, nil
+// for the personal resources
+func workspaceOf(resource Owned) *uint {
+	switch r := resource.(type) {
+	case model.Task:
+		return r.WorkspaceID
+	case *model.Task:
+		return r.WorkspaceID
+	case model.Project:
+		return r.WorkspaceID
+	case *model.Project:
+		return r.WorkspaceID
+	}
+
+	return nil
+}
+
+// SharedRoles returns the roles of the users a resource is shared with
+// allowed to perform the action, only the owner can delete or share a
+// resource
+func SharedRoles(action Action) []string {
+	switch action {
+	case Read:
+		return []string{model.ShareViewer, model.ShareEditor}
+	case Update:
+		return []string{model.ShareEditor}
+	}
+
+	return nil
+}
+
+// WorkspaceRoles returns the roles of the members of a workspace allowed to
+// perform the action on the workspace and on its tasks and projects, the
+// Share action is the management of the members
+func WorkspaceRoles(action Action) []string {
+	switch action {
+	case Read:
+		return []string{model.RoleOwner, model.RoleAdmin, model.RoleMember, model.RoleGuest}
+	case Update:
+		return []string{model.RoleOwner, model.RoleAdmin, model.RoleMember}
+	case Share:
+		return []string{model.RoleOwner, model.RoleAdmin}
+	}
+
+	return []string{model.RoleOwner, model.RoleAdmin}
+}
+
+// Scope restricts db to the resources the user can perform the action on as
+// their owner
+func Scope(db *gorm.DB, user model.User, action Action) *gorm.DB {
+	return db.Where("user_id = ?", user.ID)
+}
+
+// ScopeTasks restricts db to the tasks the user can perform the action on:
+// the personal tasks of the user, the tasks in the personal projects of the
+// user, the tasks of the workspaces where the user has a role allowed to
+// perform the action and the tasks shared with the user, directly or by their
+// project, with a role allowed to perform the action
+func ScopeTasks(db *gorm.DB, user model.User, action Action) *gorm.DB {
+	query := "(user_id = ? AND workspace_id IS NULL)" +
+		" OR project_id IN (SELECT id FROM projects WHERE user_id = ? AND workspace_id IS NULL AND deleted_at IS NULL)" +
+		" OR workspace_id IN (" + memberWorkspaceIDs + ")"
+	args := []interface{}{user.ID, user.ID, user.ID, WorkspaceRoles(action)}
+
+	if roles := SharedRoles(action); len(roles) > 0 {
+		query += " OR id IN (" + sharedIDs + ") OR project_id IN (" + sharedIDs + ")"
+		args = append(args, model.ShareTask, user.ID, roles, model.ShareProject, user.ID, roles)
+	}
+
+	return db.Where(query, args...)
+}
+
+// ScopeProjects restricts db to the projects the user can perform the action
+// on: the personal projects of the user, the projects of the workspaces where
+// the user has a role allowed to perform the action and the projects shared
+// with the user with a role allowed to perform the action
+func ScopeProjects(db *gorm.DB, user model.User, action Action) *gorm.DB {
+	query := "(user_id = ? AND workspace_id IS NULL) OR workspace_id IN (" + memberWorkspaceIDs + ")"
+	args := []interface{}{user.ID, user.ID, WorkspaceRoles(action)}
+
+	if roles := SharedRoles(action); len(roles) > 0 {
+		query += " OR id IN (" + sharedIDs + ")"
+		args = append(args, model.ShareProject, user.ID, roles)
+	}
+
+	return db.Where(query, args...)
+}
+
+// ScopeWorkspaces restricts db to the workspaces where the user has a role
+// allowed to perform the action
+func ScopeWorkspaces(db *gorm.DB, user model.User, action Action) *gorm.DB {
+	return db.Where("id IN ("+memberWorkspaceIDs+")", user.ID, WorkspaceRoles(action))
+}
+
+// Find loads in out the resource by id, scoped to the resources the user can
+// perform the action on, and checks again the access of the user to the
+// loaded resource. Returns false if the resource does not exist or the user
+// is not allowed, the two cases are not distinguished so that the existence
+// of the resources of other users is not disclosed.
+func Find(db *gorm.DB, user model.User, action Action, out Owned, id interface{}) bool {
+	var scoped *gorm.DB
+	switch out.(type) {
+	case *model.Task:
+		scoped = ScopeTasks(db, user, action)
+	case *model.Project:
+		scoped = ScopeProjects(db, user, action)
+	case *model.Workspace:
+		scoped = ScopeWorkspaces(db, user, action)
+	default:
+		scoped = Scope(db, user, action)
+	}
+
+	if err := scoped.Where("id = ?", id).First(out).Error; err != nil {
+		return false
+	}
+
+	if _, ok := out.(*model.Workspace); ok {
+		return isMember(db.New(), user, action, out.(*model.Workspace).ID)
+	}
+
+	return Can(user, action, out) || canShared(db.New(), user, action, out)
+}
+
+// isMember returns true if the user is a member of the workspace with a role
+// allowed to perform the action
+func isMember(db *gorm.DB, user model.User, action Action, workspaceID uint) bool {
+	var members int
+	db.Model(&model.WorkspaceMember{}).Where("workspace_id = ? AND user_id = ? AND role IN (?)", workspaceID, user.ID, WorkspaceRoles(action)).Count(&members)
+
+	return user.ID != 0 && members > 0
+}
+
+// canShared returns true if the user can perform the action on the task or
+// the project shared with the user or of one of its workspaces, or on the
+// task in a project of the user
+func canShared(db *gorm.DB, user model.User, action Action, resource Owned) bool {
+	if user.ID == 0 {
+		return false
+	}
+
+	workspaceID := workspaceOf(resource)
+	if workspaceID != nil && isMember(db, user, action, *workspaceID) {
+		return true
+	}
+
+	var projectID *uint
+	query := db.Table("shares").Where("user_id = ? AND role IN (?)", user.ID, SharedRoles(action))
+
+	switch r := resource.(type) {
+	case *model.Task:
+		projectID = r.ProjectID
+		if projectID != nil {
+			query = query.Where("(resource_type = ? AND resource_id = ?) OR (resource_type = ? AND resource_id = ?)", model.ShareTask, r.ID, model.ShareProject, *projectID)
+		} else {
+			query = query.Where("resource_type = ? AND resource_id = ?", model.ShareTask, r.ID)
+		}
+	case *model.Project:
+		query = query.Where("resource_type = ? AND resource_id = ?", model.ShareProject, r.ID)
+	default:
+		return false
+	}
+
+	// the project of a workspace task is in the workspace too
+	if projectID != nil && workspaceID == nil {
+		var owned int
+		db.Model(&model.Project{}).Where("id = ? AND user_id = ?", *projectID, user.ID).Count(&owned)
+		if owned > 0 {
+			return true
+		}
+	}
+
+	if len(SharedRoles(action)) == 0 {
+		return false
+	}
+
+	var shared int
+	query.Count(&shared)
+
+	return shared > 0
+}
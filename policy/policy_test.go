@@ -0,0 +1,45 @@
+package policy
+
+import (
+	"testing"
+
+	"github.com/giuliobosco/todoAPI/model"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCan(t *testing.T) {
+	owner := model.User{Base: model.Base{ID: 1}}
+	other := model.User{Base: model.Base{ID: 2}}
+	task := model.Task{UserID: 1}
+
+	for _, a := range []Action{Read, Update, Delete} {
+		assert.True(t, Can(owner, a, task))
+		assert.False(t, Can(other, a, task))
+		assert.False(t, Can(model.User{}, a, model.Task{}))
+	}
+
+	assert.True(t, Can(owner, Read, model.Tag{UserID: 1}))
+	assert.False(t, Can(other, Update, model.Project{UserID: 1}))
+
+	// the creator of a workspace task or project is not its owner
+	workspace := uint(9)
+	for _, a := range []Action{Read, Update, Delete, Share} {
+		assert.False(t, Can(owner, a, model.Task{UserID: 1, WorkspaceID: &workspace}))
+		assert.False(t, Can(owner, a, &model.Project{UserID: 1, WorkspaceID: &workspace}))
+	}
+}
+
+func TestSharedRoles(t *testing.T) {
+	assert.Equal(t, []string{model.ShareViewer, model.ShareEditor}, SharedRoles(Read))
+	assert.Equal(t, []string{model.ShareEditor}, SharedRoles(Update))
+	assert.Empty(t, SharedRoles(Delete))
+	assert.Empty(t, SharedRoles(Share))
+}
+
+func TestWorkspaceRoles(t *testing.T) {
+	assert.Equal(t, []string{model.RoleOwner, model.RoleAdmin, model.RoleMember, model.RoleGuest}, WorkspaceRoles(Read))
+	assert.Equal(t, []string{model.RoleOwner, model.RoleAdmin, model.RoleMember}, WorkspaceRoles(Update))
+	assert.Equal(t, []string{model.RoleOwner, model.RoleAdmin}, WorkspaceRoles(Delete))
+	assert.Equal(t, []string{model.RoleOwner, model.RoleAdmin}, WorkspaceRoles(Share))
+}
@@ -27,10 +27,30 @@ func SetupRoutes() *gin.Engine {
 		c.String(http.StatusOK, config.SWelcome)
 	})
 
+	router.GET("/.well-known/jwks.json", authMiddleware.JWKSHandler)
+
 	v1 := router.Group("/v1")
 	{
 		v1.POST("/login", authMiddleware.LoginHandler)
-		v1.POST("/logout", authMiddleware.LogoutHandler)
+		v1.POST("/login/2fa", auth.TwoFactorLoginHandler(authMiddleware))
+		v1.POST("/refresh", auth.RefreshHandler(authMiddleware))
+		v1.POST("/logout", authMiddleware.MiddlewareFunc(), controller.Logout)
+
+		v1.GET("/sessions", authMiddleware.MiddlewareFunc(), controller.FetchSessions)
+		v1.DELETE("/sessions", authMiddleware.MiddlewareFunc(), controller.RevokeAllSessions)
+		v1.DELETE("/sessions/:id", authMiddleware.MiddlewareFunc(), controller.RevokeSession)
+
+		v1.GET("/tokens", authMiddleware.MiddlewareFunc(), controller.FetchAccessTokens)
+		v1.POST("/tokens", authMiddleware.MiddlewareFunc(), controller.CreateAccessToken)
+		v1.DELETE("/tokens/:id", authMiddleware.MiddlewareFunc(), controller.RevokeAccessToken)
+
+		twoFactor := v1.Group("2fa")
+		{
+			twoFactor.POST("/setup", authMiddleware.MiddlewareFunc(), controller.SetupTwoFactor)
+			twoFactor.POST("/enable", authMiddleware.MiddlewareFunc(), controller.EnableTwoFactor)
+			twoFactor.POST("/disable", authMiddleware.MiddlewareFunc(), controller.DisableTwoFactor)
+			twoFactor.POST("/recoveryCodes", authMiddleware.MiddlewareFunc(), controller.RegenerateRecoveryCodes)
+		}
 
 		v1.POST("/register", controller.RegisterEndPoint)
 
@@ -48,18 +68,94 @@ func SetupRoutes() *gin.Engine {
 
 		v1.DELETE("/deleteUser", authMiddleware.MiddlewareFunc(), controller.DeleteUser)
 
+		v1.GET("/shared", authMiddleware.MiddlewareFunc(), controller.FetchSharedWithMe)
+
+		v1.POST("/joinWorkspace", authMiddleware.MiddlewareFunc(), controller.JoinWorkspace)
+
 		todo := v1.Group("todo")
 		{
 			todo.POST("/create", authMiddleware.MiddlewareFunc(), controller.CreateTask)
+			todo.POST("/batch", authMiddleware.MiddlewareFunc(), controller.BatchTasks)
 			todo.GET("/all", authMiddleware.MiddlewareFunc(), controller.FetchAllTask)
+			todo.GET("/overdue", authMiddleware.MiddlewareFunc(), controller.FetchOverdueTasks)
+			todo.GET("/assigned", authMiddleware.MiddlewareFunc(), controller.FetchAssignedTasks)
+			todo.GET("/graph", authMiddleware.MiddlewareFunc(), controller.FetchTaskGraph)
+			todo.GET("/today", authMiddleware.MiddlewareFunc(), controller.FetchTasksDueToday)
+			todo.GET("/week", authMiddleware.MiddlewareFunc(), controller.FetchTasksDueThisWeek)
+			todo.GET("/search", authMiddleware.MiddlewareFunc(), controller.SearchTasks)
+			todo.GET("/range", authMiddleware.MiddlewareFunc(), controller.FetchTasksDueRange)
 			todo.GET("/get/:id", authMiddleware.MiddlewareFunc(), controller.FetchSingleTask)
+			todo.GET("/children/:id", authMiddleware.MiddlewareFunc(), controller.FetchTaskChildren)
+			todo.GET("/tree/:id", authMiddleware.MiddlewareFunc(), controller.FetchTaskTree)
+			todo.GET("/occurrences/:id", authMiddleware.MiddlewareFunc(), controller.FetchTaskOccurrences)
+			todo.GET("/comments/:id", authMiddleware.MiddlewareFunc(), controller.FetchTaskComments)
+			todo.POST("/comments/:id", authMiddleware.MiddlewareFunc(), controller.CreateTaskComment)
+			todo.PUT("/comments/:id/:comment", authMiddleware.MiddlewareFunc(), controller.UpdateTaskComment)
+			todo.DELETE("/comments/:id/:comment", authMiddleware.MiddlewareFunc(), controller.DeleteTaskComment)
+			todo.GET("/attachments/:id", authMiddleware.MiddlewareFunc(), controller.FetchTaskAttachments)
+			todo.POST("/attachments/:id", authMiddleware.MiddlewareFunc(), controller.UploadTaskAttachment)
+			todo.GET("/attachments/:id/:attachment", authMiddleware.MiddlewareFunc(), controller.DownloadTaskAttachment)
+			todo.DELETE("/attachments/:id/:attachment", authMiddleware.MiddlewareFunc(), controller.DeleteTaskAttachment)
+			todo.GET("/history/:id", authMiddleware.MiddlewareFunc(), controller.FetchTaskHistory)
+			todo.POST("/revert/:id/:revision", authMiddleware.MiddlewareFunc(), controller.RevertTask)
 			todo.PUT("/update/:id", authMiddleware.MiddlewareFunc(), controller.UpdateTask)
+			todo.PATCH("/update/:id", authMiddleware.MiddlewareFunc(), controller.PatchTask)
 			todo.DELETE("/delete/:id", authMiddleware.MiddlewareFunc(), controller.DeleteTask)
+			todo.GET("/trash", authMiddleware.MiddlewareFunc(), controller.FetchTrash)
+			todo.DELETE("/trash/:id", authMiddleware.MiddlewareFunc(), controller.PurgeTask)
+			todo.POST("/restore/:id", authMiddleware.MiddlewareFunc(), controller.RestoreTask)
+			todo.GET("/blockers/:id", authMiddleware.MiddlewareFunc(), controller.FetchTaskBlockers)
+			todo.POST("/blockers/:id/:blocker", authMiddleware.MiddlewareFunc(), controller.AddTaskBlocker)
+			todo.DELETE("/blockers/:id/:blocker", authMiddleware.MiddlewareFunc(), controller.RemoveTaskBlocker)
+			todo.POST("/assign/:id", authMiddleware.MiddlewareFunc(), controller.AssignTask)
+			todo.DELETE("/assign/:id/:user", authMiddleware.MiddlewareFunc(), controller.UnassignTask)
+			todo.POST("/tag/:id/:tag", authMiddleware.MiddlewareFunc(), controller.AttachTag)
+			todo.DELETE("/tag/:id/:tag", authMiddleware.MiddlewareFunc(), controller.DetachTag)
 		}
-	}
 
-	authorization := router.Group("/auth")
-	authorization.GET("/refresh_token", authMiddleware.RefreshHandler)
+		projects := v1.Group("projects")
+		{
+			projects.GET("", authMiddleware.MiddlewareFunc(), controller.FetchAllProjects)
+			projects.POST("", authMiddleware.MiddlewareFunc(), controller.CreateProject)
+			projects.GET("/:id", authMiddleware.MiddlewareFunc(), controller.FetchSingleProject)
+			projects.GET("/:id/tasks", authMiddleware.MiddlewareFunc(), controller.FetchProjectTasks)
+			projects.PUT("/:id", authMiddleware.MiddlewareFunc(), controller.UpdateProject)
+			projects.DELETE("/:id", authMiddleware.MiddlewareFunc(), controller.DeleteProject)
+		}
+
+		shares := v1.Group("shares")
+		{
+			shares.GET("/todo/:id", authMiddleware.MiddlewareFunc(), controller.FetchTaskShares)
+			shares.POST("/todo/:id", authMiddleware.MiddlewareFunc(), controller.ShareTask)
+			shares.DELETE("/todo/:id/:share", authMiddleware.MiddlewareFunc(), controller.RevokeTaskShare)
+			shares.GET("/projects/:id", authMiddleware.MiddlewareFunc(), controller.FetchProjectShares)
+			shares.POST("/projects/:id", authMiddleware.MiddlewareFunc(), controller.ShareProject)
+			shares.DELETE("/projects/:id/:share", authMiddleware.MiddlewareFunc(), controller.RevokeProjectShare)
+		}
+
+		workspaces := v1.Group("workspaces")
+		{
+			workspaces.GET("", authMiddleware.MiddlewareFunc(), controller.FetchAllWorkspaces)
+			workspaces.POST("", authMiddleware.MiddlewareFunc(), controller.CreateWorkspace)
+			workspaces.GET("/:id", authMiddleware.MiddlewareFunc(), controller.FetchSingleWorkspace)
+			workspaces.PUT("/:id", authMiddleware.MiddlewareFunc(), controller.UpdateWorkspace)
+			workspaces.DELETE("/:id", authMiddleware.MiddlewareFunc(), controller.DeleteWorkspace)
+			workspaces.GET("/:id/members", authMiddleware.MiddlewareFunc(), controller.FetchWorkspaceMembers)
+			workspaces.PUT("/:id/members/:member", authMiddleware.MiddlewareFunc(), controller.UpdateWorkspaceMember)
+			workspaces.DELETE("/:id/members/:member", authMiddleware.MiddlewareFunc(), controller.RemoveWorkspaceMember)
+			workspaces.GET("/:id/invitations", authMiddleware.MiddlewareFunc(), controller.FetchWorkspaceInvitations)
+			workspaces.POST("/:id/invitations", authMiddleware.MiddlewareFunc(), controller.InviteWorkspaceMember)
+			workspaces.DELETE("/:id/invitations/:invitation", authMiddleware.MiddlewareFunc(), controller.DeleteWorkspaceInvitation)
+		}
+
+		tags := v1.Group("tags")
+		{
+			tags.GET("", authMiddleware.MiddlewareFunc(), controller.FetchAllTags)
+			tags.POST("", authMiddleware.MiddlewareFunc(), controller.CreateTag)
+			tags.PUT("/:id", authMiddleware.MiddlewareFunc(), controller.UpdateTag)
+			tags.DELETE("/:id", authMiddleware.MiddlewareFunc(), controller.DeleteTag)
+		}
+	}
 
 	return router
 }
@@ -2,13 +2,25 @@ package route
 
 import (
 	"bytes"
+	"database/sql/driver"
 	"encoding/json"
+	"fmt"
+	"io/ioutil"
 	"log"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/giuliobosco/todoAPI/auth"
 	"github.com/giuliobosco/todoAPI/config"
+	"github.com/giuliobosco/todoAPI/model"
+	"github.com/giuliobosco/todoAPI/policy"
+	"github.com/giuliobosco/todoAPI/storage"
+	"github.com/giuliobosco/todoAPI/utils"
 
 	"github.com/gin-gonic/gin"
 	mocket "github.com/selvatico/go-mocket"
@@ -27,6 +39,20 @@ func TestBaseRoute(t *testing.T) {
 	assert.Equal(t, config.SWelcome, w.Body.String())
 }
 
+func TestJWKSRoute(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	config.TestInit()
+	router := SetupRoutes()
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/.well-known/jwks.json", nil)
+	router.ServeHTTP(w, req)
+
+	// the shared HS256 key is not published
+	assert.Equal(t, 200, w.Code)
+	assert.JSONEq(t, `{"keys":[]}`, w.Body.String())
+}
+
 func testV1AuthsRoute(dbD []map[string]interface{}, httpD map[string]string, path string) *httptest.ResponseRecorder {
 	gin.SetMode(gin.TestMode)
 	mocket.Catcher.Logging = true
@@ -149,3 +175,793 @@ func TestV1RegisterRoute201(t *testing.T) {
 
 	assert.Equal(t, 201, w.Code)
 }
+
+func testV1TaskRoute(taskReply map[string]interface{}, method string, path string, headers ...string) *httptest.ResponseRecorder {
+	return testV1TaskRouteBody(taskReply, method, path, `{"title":"T_Title"}`, headers...)
+}
+
+func testV1TaskRouteBody(taskReply map[string]interface{}, method string, path string, body string, headers ...string) *httptest.ResponseRecorder {
+	return testV1TaskRouteMocks(taskReply, nil, method, path, body, headers...)
+}
+
+// testV1TaskRouteMocks is testV1TaskRouteBody with the additional database
+// mocks registered by mocks
+func testV1TaskRouteMocks(taskReply map[string]interface{}, mocks func(), method string, path string, body string, headers ...string) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	mocket.Catcher.Logging = true
+	config.TestInit()
+	router := SetupRoutes()
+
+	// token of the session 1 of the user 1
+	authMiddleware, err := auth.SetupAuth()
+	if err != nil {
+		log.Fatal(err)
+	}
+	token, _, err := authMiddleware.TokenGenerator(&model.Session{Base: model.Base{ID: 1}, UserID: 1})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// setup database
+	mocket.Catcher.Reset()
+	mocket.Catcher.NewMock().WithQuery(`SELECT count(*) FROM "sessions"`).WithReply([]map[string]interface{}{{"count": 1}})
+	mocket.Catcher.NewMock().WithQuery(`FROM "users"`).WithReply([]map[string]interface{}{{"id": 1, "active": true}})
+	mocket.Catcher.NewMock().WithQuery(`FROM "tasks"`).WithReply([]map[string]interface{}{taskReply})
+	if mocks != nil {
+		mocks()
+	}
+
+	// setup request
+	w := httptest.NewRecorder()
+	req, err := http.NewRequest(method, path, bytes.NewBufferString(body))
+	if err != nil {
+		log.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+
+	// serve request
+	router.ServeHTTP(w, req)
+
+	return w
+}
+
+func TestV1TaskRoutes404OtherUser(t *testing.T) {
+	routes := [][]string{
+		{"GET", "/v1/todo/get/5"},
+		{"PUT", "/v1/todo/update/5"},
+		{"DELETE", "/v1/todo/delete/5"},
+		{"GET", "/v1/todo/history/5"},
+		{"POST", "/v1/todo/revert/5/1"},
+		{"GET", "/v1/todo/comments/5"},
+		{"POST", "/v1/todo/comments/5"},
+	}
+
+	for _, r := range routes {
+		w := testV1TaskRoute(map[string]interface{}{"id": 5, "user_id": 2, "title": "T_Other"}, r[0], r[1])
+
+		assert.Equal(t, 404, w.Code, r[1])
+		assert.NotContains(t, w.Body.String(), "T_Other", r[1])
+	}
+}
+
+func TestV1TaskRoute200Owner(t *testing.T) {
+	w := testV1TaskRoute(map[string]interface{}{"id": 5, "user_id": 1, "title": "T_Mine"}, "GET", "/v1/todo/get/5")
+
+	assert.Equal(t, 200, w.Code)
+	assert.Contains(t, w.Body.String(), "T_Mine")
+}
+
+func TestV1TaskRouteScopedQuery(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	config.TestInit()
+
+	var todo model.Task
+	mocket.Catcher.Reset()
+	// the reply matches only a lookup scoped to the personal tasks and
+	// projects of the user, its workspaces and the tasks and projects shared
+	// with it
+	mocket.Catcher.NewMock().WithQuery(`"tasks"."deleted_at" IS NULL AND (((user_id = 1 AND workspace_id IS NULL) ` +
+		`OR project_id IN (SELECT id FROM projects WHERE user_id = 1 AND workspace_id IS NULL AND deleted_at IS NULL) ` +
+		`OR workspace_id IN (SELECT workspace_id FROM workspace_members WHERE user_id = 1 AND role IN (owner,admin,member,guest)) ` +
+		`OR id IN (SELECT resource_id FROM shares WHERE resource_type = task AND user_id = 1 AND role IN (viewer,editor)) ` +
+		`OR project_id IN (SELECT resource_id FROM shares WHERE resource_type = project AND user_id = 1 AND role IN (viewer,editor))) ` +
+		`AND (id = 5))`).WithReply([]map[string]interface{}{{"id": 5, "user_id": 1}})
+
+	assert.True(t, policy.Find(config.GetDB(), model.User{Base: model.Base{ID: 1}}, policy.Read, &todo, 5))
+}
+
+func TestV1TaskRouteSharedScopedQuery(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	config.TestInit()
+
+	var todo model.Task
+	mocket.Catcher.Reset()
+	// only the owner of a personal task, the owner of its project and the
+	// owners and admins of the workspace can delete a task
+	mocket.Catcher.NewMock().WithQuery(`"tasks"."deleted_at" IS NULL AND (((user_id = 1 AND workspace_id IS NULL) ` +
+		`OR project_id IN (SELECT id FROM projects WHERE user_id = 1 AND workspace_id IS NULL AND deleted_at IS NULL) ` +
+		`OR workspace_id IN (SELECT workspace_id FROM workspace_members WHERE user_id = 1 AND role IN (owner,admin))) AND (id = 5))`).WithReply([]map[string]interface{}{{"id": 5, "user_id": 1}})
+
+	assert.True(t, policy.Find(config.GetDB(), model.User{Base: model.Base{ID: 1}}, policy.Delete, &todo, 5))
+}
+
+func TestV1TaskRouteETag(t *testing.T) {
+	task := map[string]interface{}{"id": 5, "user_id": 1, "title": "T_Mine", "version": 3}
+
+	w := testV1TaskRoute(task, "GET", "/v1/todo/get/5")
+	assert.Equal(t, 200, w.Code)
+	assert.Equal(t, `"3"`, w.Header().Get("ETag"))
+
+	w = testV1TaskRoute(task, "GET", "/v1/todo/get/5", "If-None-Match", `"3"`)
+	assert.Equal(t, 304, w.Code)
+	assert.Empty(t, w.Body.String())
+
+	w = testV1TaskRoute(task, "PUT", "/v1/todo/update/5", "If-Match", `"2"`)
+	assert.Equal(t, 412, w.Code)
+
+	w = testV1TaskRoute(task, "DELETE", "/v1/todo/delete/5", "If-Match", `"2"`)
+	assert.Equal(t, 412, w.Code)
+}
+
+func TestV1TaskBatchRoute(t *testing.T) {
+	other := map[string]interface{}{"id": 5, "user_id": 2, "title": "T_Other"}
+
+	// atomic: the operation on the task of another user rolls back the batch
+	w := testV1TaskRouteBody(other, "POST", "/v1/todo/batch", `{"operations":[{"op":"create","task":{"title":"T_New"}},{"op":"delete","id":5}]}`)
+	assert.Equal(t, 404, w.Code)
+	assert.Contains(t, w.Body.String(), config.SBatchRolledBack)
+	// the create before is reported as rolled back, without its task
+	assert.Contains(t, w.Body.String(), `{"index":0,"status":424,"error":"`+config.SBatchOperationRolledBack+`"}`)
+	assert.NotContains(t, w.Body.String(), "T_New")
+
+	// best effort: a failed savepoint fails the batch, the mock driver does not
+	// execute the savepoints
+	w = testV1TaskRouteBody(other, "POST", "/v1/todo/batch", `{"mode":"best_effort","operations":[{"op":"complete","id":5},{"op":"delete","id":6}]}`)
+	assert.Equal(t, 500, w.Code)
+	assert.Contains(t, w.Body.String(), "SAVEPOINT")
+
+	// an unknown operation fails the batch before any lookup
+	w = testV1TaskRouteBody(other, "POST", "/v1/todo/batch", `{"mode":"best_effort","operations":[{"op":"complete","id":5},{"op":"explode","id":5}]}`)
+	assert.Equal(t, 400, w.Code)
+	assert.Contains(t, w.Body.String(), config.SBatchInvalidOperation+": explode")
+
+	w = testV1TaskRouteBody(other, "POST", "/v1/todo/batch", `{"mode":"sometimes","operations":[{"op":"delete","id":5}]}`)
+	assert.Equal(t, 400, w.Code)
+
+	// retag replaces the tags with the tags of the user
+	mine := map[string]interface{}{"id": 5, "user_id": 1, "title": "T_Mine"}
+	tags := func(reply []map[string]interface{}) func() {
+		return func() {
+			mocket.Catcher.NewMock().WithQuery(`FROM "tags"`).WithReply(reply)
+		}
+	}
+	w = testV1TaskRouteMocks(mine, tags([]map[string]interface{}{{"id": 3, "user_id": 1, "name": "G_Urgent"}}), "POST", "/v1/todo/batch", `{"operations":[{"op":"retag","id":5,"tag_ids":[3,3]}]}`)
+	assert.Equal(t, 200, w.Code)
+	assert.Contains(t, w.Body.String(), `"status":200`)
+	assert.Contains(t, w.Body.String(), "G_Urgent")
+
+	w = testV1TaskRouteMocks(mine, tags([]map[string]interface{}{}), "POST", "/v1/todo/batch", `{"operations":[{"op":"retag","id":5,"tag_ids":[3]}]}`)
+	assert.Equal(t, 404, w.Code)
+	assert.Contains(t, w.Body.String(), config.STagNotFound)
+}
+
+func TestV1TaskSearchRoute(t *testing.T) {
+	task := map[string]interface{}{"id": 5, "user_id": 1, "title": "Buy milk", "description": "and bread"}
+
+	w := testV1TaskRoute(task, "GET", "/v1/todo/search?q=Milk")
+	assert.Equal(t, 200, w.Code)
+	assert.Contains(t, w.Body.String(), `"snippet":"Buy \u003cmark\u003emilk\u003c/mark\u003e and bread"`)
+
+	w = testV1TaskRoute(task, "GET", "/v1/todo/search")
+	assert.Equal(t, 400, w.Code)
+}
+
+func TestV1TaskRecurrenceRoute(t *testing.T) {
+	due := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	open := map[string]interface{}{"id": 5, "user_id": 1, "title": "T_Daily", "recurrence": "FREQ=DAILY", "occurrence": 1, "due_at": due}
+	completed := map[string]interface{}{"id": 5, "user_id": 1, "title": "T_Daily", "recurrence": "FREQ=DAILY", "occurrence": 1, "due_at": due, "completed": true}
+
+	var inserts, links int
+	var inserted string
+	complete := func(before map[string]interface{}, after map[string]interface{}) func() {
+		return func() {
+			inserts, links = 0, 0
+			mocket.Catcher.Reset()
+			mocket.Catcher.NewMock().WithQuery(`FROM "tasks"`).WithReply([]map[string]interface{}{before}).OneTime()
+			mocket.Catcher.NewMock().WithQuery(`SELECT count(*) FROM "sessions"`).WithReply([]map[string]interface{}{{"count": 1}})
+			mocket.Catcher.NewMock().WithQuery(`FROM "users"`).WithReply([]map[string]interface{}{{"id": 1, "active": true}})
+			mocket.Catcher.NewMock().WithQuery(`role IN (owner,admin,member)))`).WithReply([]map[string]interface{}{{"count": 1}})
+			mocket.Catcher.NewMock().WithQuery(`INSERT INTO "tasks"`).WithID(7).WithCallback(func(q string, args []driver.NamedValue) {
+				inserts++
+				inserted = fmt.Sprint(q, args)
+			})
+			mocket.Catcher.NewMock().WithQuery(`SET "next_id"`).WithRowsNum(1).WithCallback(func(string, []driver.NamedValue) { links++ })
+			mocket.Catcher.NewMock().WithQuery(`FROM "tasks"`).WithReply([]map[string]interface{}{after})
+		}
+	}
+
+	// the first completion creates the next occurrence and records it
+	w := testV1TaskRouteMocks(open, complete(open, completed), "PATCH", "/v1/todo/update/5", `{"completed":true}`, "Content-Type", "application/merge-patch+json")
+	assert.Equal(t, 200, w.Code)
+	assert.Contains(t, w.Body.String(), `"next":`)
+	assert.Equal(t, 1, inserts)
+	assert.Equal(t, 1, links)
+
+	// completing it again after reopening it creates no other occurrence
+	open["next_id"], completed["next_id"] = 7, 7
+	w = testV1TaskRouteMocks(open, complete(open, completed), "PATCH", "/v1/todo/update/5", `{"completed":true}`, "Content-Type", "application/merge-patch+json")
+	assert.Equal(t, 200, w.Code)
+	assert.NotContains(t, w.Body.String(), `"next":`)
+	assert.Equal(t, 0, inserts)
+	assert.Equal(t, 0, links)
+
+	// the next occurrence of a workspace task is in the workspace
+	for _, task := range []map[string]interface{}{open, completed} {
+		delete(task, "next_id")
+		task["workspace_id"] = 9
+	}
+	w = testV1TaskRouteMocks(open, complete(open, completed), "PATCH", "/v1/todo/update/5", `{"completed":true}`, "Content-Type", "application/merge-patch+json")
+	assert.Equal(t, 200, w.Code)
+	assert.Regexp(t, `"next":\{[^}]*"workspace_id":9,`, w.Body.String())
+	assert.Equal(t, 1, inserts)
+	assert.Contains(t, inserted, `"workspace_id"`)
+}
+
+func TestV1PasswordRevokesSessions(t *testing.T) {
+	task := map[string]interface{}{"id": 5, "user_id": 1}
+	recovery := `{"email":"u1@example.com","token":"T_Token","new_password":"N3w_Password!"}`
+
+	w := testV1TaskRouteMocks(task, nil, "POST", "/v1/executePasswordRecovery", recovery)
+	assert.Equal(t, 200, w.Code)
+
+	// the password is not changed if the sessions are not revoked
+	failing := func() {
+		mocket.Catcher.NewMock().WithQuery(`UPDATE "sessions"`).WithExecException()
+	}
+	w = testV1TaskRouteMocks(task, failing, "POST", "/v1/executePasswordRecovery", recovery)
+	assert.Equal(t, 500, w.Code)
+
+	hash, err := utils.PasswordHash("Old_Password1!")
+	assert.Nil(t, err)
+	update := func(sessions func()) func() {
+		return func() {
+			mocket.Catcher.Reset()
+			mocket.Catcher.NewMock().WithQuery(`SELECT count(*) FROM "sessions"`).WithReply([]map[string]interface{}{{"count": 1}})
+			sessions()
+			mocket.Catcher.NewMock().WithQuery(`FROM "users"`).WithReply([]map[string]interface{}{{"id": 1, "active": true, "password": hash}})
+		}
+	}
+	body := `{"old_password":"Old_Password1!","new_password":"N3w_Password!"}`
+	w = testV1TaskRouteMocks(task, update(func() {}), "POST", "/v1/updatePassword", body)
+	assert.Equal(t, 200, w.Code)
+	w = testV1TaskRouteMocks(task, update(failing), "POST", "/v1/updatePassword", body)
+	assert.Equal(t, 500, w.Code)
+}
+
+func TestV1TaskHistoryRoute(t *testing.T) {
+	task := map[string]interface{}{"id": 5, "user_id": 1, "title": "T_Mine"}
+
+	w := testV1TaskRoute(task, "GET", "/v1/todo/history/5")
+	assert.Equal(t, 200, w.Code)
+	assert.Equal(t, `{"data":[]}`, w.Body.String())
+
+	w = testV1TaskRoute(task, "POST", "/v1/todo/revert/5/1")
+	assert.Equal(t, 404, w.Code)
+}
+
+func TestV1TaskCommentRoutes(t *testing.T) {
+	task := map[string]interface{}{"id": 5, "user_id": 1, "title": "T_Mine"}
+
+	w := testV1TaskRouteBody(task, "POST", "/v1/todo/comments/5", `{"body":"C_Body"}`)
+	assert.Equal(t, 201, w.Code)
+	assert.Contains(t, w.Body.String(), "C_Body")
+
+	w = testV1TaskRouteBody(task, "POST", "/v1/todo/comments/5", `{"body":" "}`)
+	assert.Equal(t, 400, w.Code)
+
+	w = testV1TaskRoute(task, "GET", "/v1/todo/comments/5")
+	assert.Equal(t, 200, w.Code)
+
+	w = testV1TaskRoute(task, "DELETE", "/v1/todo/comments/5/7")
+	assert.Equal(t, 404, w.Code)
+
+	w = testV1TaskRoute(task, "GET", "/v1/todo/all")
+	assert.Equal(t, 200, w.Code)
+	assert.Contains(t, w.Body.String(), `"comment_count":0`)
+
+	failed := func() {
+		mocket.Catcher.NewMock().WithQuery(`INSERT INTO "comments"`).WithExecException()
+	}
+	w = testV1TaskRouteMocks(task, failed, "POST", "/v1/todo/comments/5", `{"body":"C_Body"}`)
+	assert.Equal(t, 500, w.Code)
+
+	// the If-Match version is checked by the update itself
+	comment := func(updated int64) func() {
+		return func() {
+			mocket.Catcher.NewMock().WithQuery(`FROM "comments"`).WithReply([]map[string]interface{}{{"id": 7, "task_id": 5, "user_id": 1, "version": 2, "body": "C_Body"}})
+			mocket.Catcher.NewMock().WithQuery(`UPDATE "comments"`).WithRowsNum(updated)
+		}
+	}
+	w = testV1TaskRouteMocks(task, comment(1), "PUT", "/v1/todo/comments/5/7", `{"body":"C_Edited"}`, "If-Match", `"2"`)
+	assert.Equal(t, 200, w.Code)
+
+	w = testV1TaskRouteMocks(task, comment(0), "PUT", "/v1/todo/comments/5/7", `{"body":"C_Edited"}`, "If-Match", `"2"`)
+	assert.Equal(t, 412, w.Code)
+	assert.Contains(t, w.Body.String(), config.SPreconditionFailed)
+
+	w = testV1TaskRouteMocks(task, comment(1), "PUT", "/v1/todo/comments/5/7", `{"body":"C_Edited"}`, "If-Match", `"1"`)
+	assert.Equal(t, 412, w.Code)
+}
+
+// multipartFile returns a multipart body with the file field and its content
+// type
+func multipartFile(filename string, content string) (string, string) {
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	part, _ := w.CreateFormFile("file", filename)
+	part.Write([]byte(content))
+	w.Close()
+
+	return body.String(), w.FormDataContentType()
+}
+
+func TestV1TaskAttachmentRoutes(t *testing.T) {
+	root, err := ioutil.TempDir("", "attachments")
+	assert.Nil(t, err)
+	defer os.RemoveAll(root)
+	config.Store, _ = storage.NewLocalStore(root)
+
+	task := map[string]interface{}{"id": 5, "user_id": 1, "title": "T_Mine"}
+
+	body, contentType := multipartFile("receipt.txt", "Total: 12.50 CHF")
+	w := testV1TaskRouteBody(task, "POST", "/v1/todo/attachments/5", body, "Content-Type", contentType)
+	assert.Equal(t, 201, w.Code)
+	assert.Contains(t, w.Body.String(), `"filename":"receipt.txt"`)
+	assert.Contains(t, w.Body.String(), `"size":16`)
+	dirs, _ := ioutil.ReadDir(root + "/1")
+	assert.Len(t, dirs, 1)
+
+	body, contentType = multipartFile("page.html", "<html><script>alert(1)</script></html>")
+	w = testV1TaskRouteBody(task, "POST", "/v1/todo/attachments/5", body, "Content-Type", contentType)
+	assert.Equal(t, 415, w.Code)
+
+	w = testV1TaskRouteBody(task, "POST", "/v1/todo/attachments/5", `{"title":"T_Title"}`)
+	assert.Equal(t, 400, w.Code)
+
+	// larger than the maximum size
+	body, contentType = multipartFile("big.txt", strings.Repeat("a", 12<<20))
+	w = testV1TaskRouteBody(task, "POST", "/v1/todo/attachments/5", body, "Content-Type", contentType)
+	assert.Equal(t, 413, w.Code)
+	assert.Contains(t, w.Body.String(), config.SAttachmentTooLarge)
+
+	// the quota is filled by another upload before the attachment is saved
+	filled := func() {
+		mocket.Catcher.NewMock().WithQuery(`SUM(size)`).WithReply([]map[string]interface{}{{"size": 0}}).OneTime()
+		mocket.Catcher.NewMock().WithQuery(`SUM(size)`).WithReply([]map[string]interface{}{{"size": 100 << 20}})
+	}
+	body, contentType = multipartFile("receipt.txt", "Total: 12.50 CHF")
+	w = testV1TaskRouteMocks(task, filled, "POST", "/v1/todo/attachments/5", body, "Content-Type", contentType)
+	assert.Equal(t, 413, w.Code)
+	assert.Contains(t, w.Body.String(), config.SAttachmentQuota)
+	dirs, _ = ioutil.ReadDir(root + "/1")
+	assert.Len(t, dirs, 1)
+
+	w = testV1TaskRoute(map[string]interface{}{"id": 5, "user_id": 2}, "GET", "/v1/todo/attachments/5")
+	assert.Equal(t, 404, w.Code)
+
+	w = testV1TaskRoute(task, "GET", "/v1/todo/attachments/5/3")
+	assert.Equal(t, 404, w.Code)
+}
+
+func TestV1TaskSharedRoutes(t *testing.T) {
+	task := map[string]interface{}{"id": 5, "user_id": 2, "title": "T_Shared"}
+	shared := func() {
+		mocket.Catcher.NewMock().WithQuery(`FROM "shares"`).WithReply([]map[string]interface{}{{"count": 1}})
+	}
+
+	w := testV1TaskRouteMocks(task, shared, "GET", "/v1/todo/get/5", "")
+	assert.Equal(t, 200, w.Code)
+	assert.Contains(t, w.Body.String(), "T_Shared")
+
+	// only the owner can delete or share a task
+	w = testV1TaskRouteMocks(task, shared, "DELETE", "/v1/todo/delete/5", "")
+	assert.Equal(t, 404, w.Code)
+	w = testV1TaskRouteMocks(task, shared, "POST", "/v1/shares/todo/5", `{"email":"u3@example.com"}`)
+	assert.Equal(t, 404, w.Code)
+
+	w = testV1TaskRouteBody(map[string]interface{}{"id": 5, "user_id": 1}, "POST", "/v1/shares/todo/5", `{"email":"u3@example.com","role":"owner"}`)
+	assert.Equal(t, 400, w.Code)
+
+	// an unknown email and the own email get the same response
+	owned := map[string]interface{}{"id": 5, "user_id": 1}
+	unknown := func() {
+		mocket.Catcher.Reset()
+		mocket.Catcher.NewMock().WithQuery(`(email = `).WithReply([]map[string]interface{}{})
+		mocket.Catcher.NewMock().WithQuery(`SELECT count(*) FROM "sessions"`).WithReply([]map[string]interface{}{{"count": 1}})
+		mocket.Catcher.NewMock().WithQuery(`FROM "users"`).WithReply([]map[string]interface{}{{"id": 1, "active": true}})
+		mocket.Catcher.NewMock().WithQuery(`FROM "tasks"`).WithReply([]map[string]interface{}{owned})
+	}
+	w = testV1TaskRouteMocks(owned, unknown, "POST", "/v1/shares/todo/5", `{"email":"u3@example.com"}`)
+	assert.Equal(t, 400, w.Code)
+	body := w.Body.String()
+	w = testV1TaskRouteBody(owned, "POST", "/v1/shares/todo/5", `{"email":"u1@example.com"}`)
+	assert.Equal(t, 400, w.Code)
+	assert.Equal(t, body, w.Body.String())
+
+	w = testV1TaskRoute(task, "GET", "/v1/shared")
+	assert.Equal(t, 200, w.Code)
+
+	failing := func() {
+		mocket.Catcher.NewMock().WithQuery(`JOIN shares`).WithQueryException()
+	}
+	w = testV1TaskRouteMocks(task, failing, "GET", "/v1/shared", "")
+	assert.Equal(t, 500, w.Code)
+}
+
+func TestV1WorkspaceRoutes(t *testing.T) {
+	task := map[string]interface{}{"id": 5, "user_id": 1, "title": "T_Workspace"}
+	guest := func() {
+		mocket.Catcher.NewMock().WithQuery(`FROM "workspaces"`).WithReply([]map[string]interface{}{{"id": 9, "name": "W_Team"}})
+		mocket.Catcher.NewMock().WithQuery(`role IN (owner,admin,member,guest)))`).WithReply([]map[string]interface{}{{"count": 1}})
+	}
+
+	// not a member of the workspace
+	w := testV1TaskRouteMocks(task, nil, "GET", "/v1/todo/all", "", "X-Workspace-ID", "9")
+	assert.Equal(t, 404, w.Code)
+
+	w = testV1TaskRouteMocks(task, guest, "GET", "/v1/todo/all", "", "X-Workspace-ID", "9")
+	assert.Equal(t, 200, w.Code)
+	assert.Contains(t, w.Body.String(), "T_Workspace")
+
+	// a guest can not create tasks in the workspace
+	w = testV1TaskRouteMocks(task, guest, "POST", "/v1/todo/create", `{"title":"T_Guest"}`, "X-Workspace-ID", "9")
+	assert.Equal(t, 403, w.Code)
+
+	// the creator of a workspace task only keeps the access of its current role
+	created := map[string]interface{}{"id": 5, "user_id": 1, "workspace_id": 9, "title": "T_Created"}
+	w = testV1TaskRouteMocks(created, nil, "GET", "/v1/todo/get/5", "")
+	assert.Equal(t, 404, w.Code)
+	w = testV1TaskRouteMocks(created, guest, "GET", "/v1/todo/get/5", "")
+	assert.Equal(t, 200, w.Code)
+	w = testV1TaskRouteMocks(created, guest, "PATCH", "/v1/todo/update/5", `{"title":"T_Guest"}`, "Content-Type", "application/merge-patch+json")
+	assert.Equal(t, 404, w.Code)
+	w = testV1TaskRouteMocks(created, guest, "DELETE", "/v1/todo/delete/5", "")
+	assert.Equal(t, 404, w.Code)
+	w = testV1TaskRouteMocks(created, guest, "POST", "/v1/shares/todo/5", `{"email":"u3@example.com"}`)
+	assert.Equal(t, 404, w.Code)
+}
+
+func TestV1TaskAssignRoutes(t *testing.T) {
+	task := map[string]interface{}{"id": 5, "user_id": 1, "title": "T_Assigned"}
+
+	w := testV1TaskRoute(task, "GET", "/v1/todo/assigned")
+	assert.Equal(t, 200, w.Code)
+	assert.Contains(t, w.Body.String(), "T_Assigned")
+	assert.Contains(t, w.Body.String(), `"assignees":[]`)
+
+	w = testV1TaskRouteBody(task, "POST", "/v1/todo/assign/5", `{}`)
+	assert.Equal(t, 400, w.Code)
+
+	w = testV1TaskRouteBody(task, "POST", "/v1/todo/assign/5", `{"email":"u1@example.com"}`)
+	assert.Equal(t, 201, w.Code)
+	assert.Contains(t, w.Body.String(), config.STaskAssigned)
+
+	// an unknown email and a user without access get the same response
+	assignee := func(reply []map[string]interface{}) func() {
+		return func() {
+			mocket.Catcher.Reset()
+			mocket.Catcher.NewMock().WithQuery(`(email = `).WithReply(reply)
+			mocket.Catcher.NewMock().WithQuery(`SELECT count(*) FROM "sessions"`).WithReply([]map[string]interface{}{{"count": 1}})
+			mocket.Catcher.NewMock().WithQuery(`FROM "users"`).WithReply([]map[string]interface{}{{"id": 1, "active": true}})
+			mocket.Catcher.NewMock().WithQuery(`FROM "tasks"`).WithReply([]map[string]interface{}{task})
+		}
+	}
+	w = testV1TaskRouteMocks(task, assignee([]map[string]interface{}{}), "POST", "/v1/todo/assign/5", `{"email":"u3@example.com"}`)
+	assert.Equal(t, 400, w.Code)
+	assert.Contains(t, w.Body.String(), config.SAssigneeInvalidEmail)
+	body := w.Body.String()
+	w = testV1TaskRouteMocks(task, assignee([]map[string]interface{}{{"id": 2, "active": true}}), "POST", "/v1/todo/assign/5", `{"email":"u2@example.com"}`)
+	assert.Equal(t, 400, w.Code)
+	assert.Equal(t, body, w.Body.String())
+
+	w = testV1TaskRoute(task, "DELETE", "/v1/todo/assign/5/1")
+	assert.Equal(t, 404, w.Code)
+
+	// the tasks are not returned without their assignees
+	failing := func() {
+		mocket.Catcher.NewMock().WithQuery(`FROM "assignees" JOIN users`).WithQueryException()
+	}
+	for _, path := range []string{"/v1/todo/all", "/v1/todo/get/5", "/v1/todo/overdue", "/v1/todo/today", "/v1/todo/children/5"} {
+		w = testV1TaskRouteMocks(task, failing, "GET", path, "")
+		assert.Equal(t, 500, w.Code, path)
+	}
+}
+
+func TestV1TaskBlockerRoutes(t *testing.T) {
+	task := map[string]interface{}{"id": 5, "user_id": 1, "title": "T_Blocked"}
+	blocked := func() {
+		mocket.Catcher.NewMock().WithQuery(`FROM "task_dependencies"`).WithReply([]map[string]interface{}{{"blocker_id": 7}})
+	}
+
+	w := testV1TaskRouteMocks(task, blocked, "PUT", "/v1/todo/update/5", `{"title":"T_Blocked","completed":true}`)
+	assert.Equal(t, 409, w.Code)
+	assert.Contains(t, w.Body.String(), config.STaskBlocked)
+
+	w = testV1TaskRouteMocks(task, blocked, "PUT", "/v1/todo/update/5?force=true&children=complete", `{"title":"T_Blocked","completed":true}`)
+	assert.Equal(t, 200, w.Code)
+
+	// a task can not block itself
+	w = testV1TaskRoute(task, "POST", "/v1/todo/blockers/5/5")
+	assert.Equal(t, 409, w.Code)
+
+	w = testV1TaskRoute(task, "GET", "/v1/todo/graph")
+	assert.Equal(t, 200, w.Code)
+	assert.Contains(t, w.Body.String(), `"available":[{"id":5`)
+}
+
+func TestV1SessionRoutes(t *testing.T) {
+	sessions := func() {
+		mocket.Catcher.NewMock().WithQuery(`SELECT * FROM "sessions"`).WithReply([]map[string]interface{}{{"id": 1, "user_id": 1}, {"id": 2, "user_id": 1}})
+	}
+
+	w := testV1TaskRouteMocks(nil, sessions, "GET", "/v1/sessions", "")
+	assert.Equal(t, 200, w.Code)
+	assert.Contains(t, w.Body.String(), `"id":1,`)
+	assert.Equal(t, 1, strings.Count(w.Body.String(), `"current":true`))
+
+	w = testV1TaskRouteMocks(nil, sessions, "DELETE", "/v1/sessions/2", "")
+	assert.Equal(t, 200, w.Code)
+	assert.Contains(t, w.Body.String(), config.SSessionRevoked)
+
+	w = testV1TaskRoute(nil, "DELETE", "/v1/sessions/2")
+	assert.Equal(t, 404, w.Code)
+
+	w = testV1TaskRoute(nil, "POST", "/v1/logout")
+	assert.Equal(t, 200, w.Code)
+	assert.Contains(t, w.Body.String(), config.SLoggedOut)
+
+	// the access tokens of a revoked session are rejected
+	revoked := func() {
+		mocket.Catcher.Reset()
+		mocket.Catcher.NewMock().WithQuery(`SELECT count(*) FROM "sessions"`).WithReply([]map[string]interface{}{{"count": 0}})
+		mocket.Catcher.NewMock().WithQuery(`FROM "users"`).WithReply([]map[string]interface{}{{"id": 1, "active": true}})
+	}
+	w = testV1TaskRouteMocks(nil, revoked, "GET", "/v1/sessions", "")
+	assert.Equal(t, 403, w.Code)
+
+	w = testV1TaskRouteBody(nil, "POST", "/v1/refresh", `{}`)
+	assert.Equal(t, 401, w.Code)
+
+	w = testV1TaskRouteBody(nil, "POST", "/v1/refresh", `{"refresh_token":"T_Unknown"}`)
+	assert.Equal(t, 401, w.Code)
+	assert.Contains(t, w.Body.String(), config.SInvalidRefreshToken)
+}
+
+func TestV1AccessTokenRoutes(t *testing.T) {
+	w := testV1TaskRouteBody(nil, "POST", "/v1/tokens", `{"name":"backup","scopes":"tasks:read admin"}`)
+	assert.Equal(t, 400, w.Code)
+	assert.Contains(t, w.Body.String(), config.SAccessTokenInvalidScopes)
+
+	w = testV1TaskRouteBody(nil, "POST", "/v1/tokens", `{"name":"backup","scopes":"tasks:read"}`)
+	assert.Equal(t, 201, w.Code)
+	assert.Contains(t, w.Body.String(), `"token":"`+config.AccessTokenPrefix)
+	assert.NotContains(t, w.Body.String(), "token_hash")
+
+	w = testV1TaskRoute(nil, "DELETE", "/v1/tokens/3")
+	assert.Equal(t, 404, w.Code)
+
+	// requests authenticated by a personal access token of the user 1
+	task := map[string]interface{}{"id": 5, "user_id": 1, "title": "T_Mine"}
+	readOnly := func() {
+		mocket.Catcher.NewMock().WithQuery(`FROM "access_tokens"`).WithReply([]map[string]interface{}{{"id": 3, "user_id": 1, "scopes": "tasks:read"}})
+	}
+	pat := []string{"Authorization", "Bearer " + config.AccessTokenPrefix + "T_Token"}
+
+	w = testV1TaskRouteMocks(task, readOnly, "GET", "/v1/todo/get/5", "", pat...)
+	assert.Equal(t, 200, w.Code)
+	assert.Contains(t, w.Body.String(), "T_Mine")
+
+	w = testV1TaskRouteMocks(task, readOnly, "DELETE", "/v1/todo/delete/5", "", pat...)
+	assert.Equal(t, 403, w.Code)
+	assert.Contains(t, w.Body.String(), config.SInsufficientScope)
+
+	// the access tokens can not manage the sessions and the access tokens
+	w = testV1TaskRouteMocks(task, readOnly, "GET", "/v1/tokens", "", pat...)
+	assert.Equal(t, 403, w.Code)
+
+	w = testV1TaskRoute(task, "GET", "/v1/todo/get/5", pat...)
+	assert.Equal(t, 401, w.Code)
+
+	// the access tokens are not accepted from the query
+	w = testV1TaskRouteMocks(task, readOnly, "GET", "/v1/todo/get/5?token="+config.AccessTokenPrefix+"T_Token", "", "Authorization", "")
+	assert.Equal(t, 401, w.Code)
+	assert.Contains(t, w.Body.String(), config.SMissingToken)
+}
+
+func TestV1TwoFactorRoutes(t *testing.T) {
+	w := testV1TaskRoute(nil, "POST", "/v1/2fa/setup")
+	assert.Equal(t, 200, w.Code)
+	assert.Contains(t, w.Body.String(), `"uri":"otpauth://totp/`)
+
+	w = testV1TaskRouteBody(nil, "POST", "/v1/2fa/enable", `{"code":"123456"}`)
+	assert.Equal(t, 400, w.Code)
+	assert.Contains(t, w.Body.String(), config.STwoFactorNotSetUp)
+
+	w = testV1TaskRouteBody(nil, "POST", "/v1/2fa/disable", `{"password":"T_Password","code":"123456"}`)
+	assert.Equal(t, 409, w.Code)
+
+	// disabling requires the password and a code
+	enabled := func() {
+		mocket.Catcher.Reset()
+		mocket.Catcher.NewMock().WithQuery(`SELECT count(*) FROM "sessions"`).WithReply([]map[string]interface{}{{"count": 1}})
+		mocket.Catcher.NewMock().WithQuery(`FROM "users"`).WithReply([]map[string]interface{}{{"id": 1, "active": true, "totp_enabled": true, "totp_secret": "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"}})
+	}
+	w = testV1TaskRouteMocks(nil, enabled, "POST", "/v1/2fa/disable", `{"code":"123456"}`)
+	assert.Equal(t, 400, w.Code)
+	assert.Contains(t, w.Body.String(), config.SMissingPasswordCode)
+
+	w = testV1TaskRouteMocks(nil, enabled, "POST", "/v1/2fa/disable", `{"password":"T_Password","code":"123456"}`)
+	assert.Equal(t, 400, w.Code)
+	assert.Contains(t, w.Body.String(), config.SWrongPassword)
+
+	w = testV1TaskRouteBody(nil, "POST", "/v1/login/2fa", `{"challenge":"T_Unknown","code":"123456"}`)
+	assert.Equal(t, 401, w.Code)
+	assert.Contains(t, w.Body.String(), config.SInvalidChallenge)
+}
+
+func TestV1TwoFactorLockout(t *testing.T) {
+	var failures []string
+	challenge := func(user map[string]interface{}) func() {
+		return func() {
+			failures = nil
+			mocket.Catcher.Reset()
+			mocket.Catcher.NewMock().WithQuery(`FROM "login_challenges"`).WithReply([]map[string]interface{}{{"id": 3, "user_id": 1, "expires_at": time.Now().Add(time.Minute)}})
+			mocket.Catcher.NewMock().WithQuery(`UPDATE "login_challenges"`).WithRowsNum(1)
+			mocket.Catcher.NewMock().WithQuery(`FROM "users"`).WithReply([]map[string]interface{}{user})
+			mocket.Catcher.NewMock().WithQuery(`UPDATE "users" SET "totp_failures"`).WithCallback(func(q string, _ []driver.NamedValue) {
+				failures = append(failures, q)
+			})
+		}
+	}
+	user := map[string]interface{}{"id": 1, "active": true, "totp_enabled": true, "totp_secret": "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"}
+
+	// the wrong codes are counted on the user, not only on the challenge
+	w := testV1TaskRouteMocks(nil, challenge(user), "POST", "/v1/login/2fa", `{"challenge":"T_Challenge","code":"000000"}`)
+	assert.Equal(t, 401, w.Code)
+	assert.Contains(t, w.Body.String(), config.SInvalidTwoFactorCode)
+	assert.Len(t, failures, 2)
+	assert.Contains(t, failures[0], "totp_failures + 1")
+	assert.Contains(t, failures[1], "totp_locked_until")
+
+	// a locked user can not try codes with a new challenge
+	user["totp_locked_until"] = time.Now().Add(time.Minute)
+	w = testV1TaskRouteMocks(nil, challenge(user), "POST", "/v1/login/2fa", `{"challenge":"T_Challenge","code":"000000"}`)
+	assert.Equal(t, 429, w.Code)
+	assert.Contains(t, w.Body.String(), config.STwoFactorLocked)
+	assert.Empty(t, failures)
+}
+
+func TestV1ProjectRoutes(t *testing.T) {
+	project := func(userID int) func() {
+		return func() {
+			mocket.Catcher.NewMock().WithQuery(`FROM "projects"`).WithReply([]map[string]interface{}{{"id": 4, "user_id": userID, "name": "P_Work"}})
+		}
+	}
+
+	w := testV1TaskRouteBody(nil, "POST", "/v1/projects", `{"name":" P_Work "}`)
+	assert.Equal(t, 201, w.Code)
+	assert.Contains(t, w.Body.String(), `"name":"P_Work"`)
+
+	w = testV1TaskRouteBody(nil, "POST", "/v1/projects", `{"name":" "}`)
+	assert.Equal(t, 400, w.Code)
+
+	w = testV1TaskRouteMocks(nil, project(1), "GET", "/v1/projects", "")
+	assert.Equal(t, 200, w.Code)
+	assert.Contains(t, w.Body.String(), "P_Work")
+
+	w = testV1TaskRouteMocks(nil, project(1), "GET", "/v1/projects/4", "")
+	assert.Equal(t, 200, w.Code)
+	assert.Contains(t, w.Body.String(), "P_Work")
+
+	w = testV1TaskRouteMocks(map[string]interface{}{"id": 5, "user_id": 1, "title": "T_Project", "project_id": 4}, project(1), "GET", "/v1/projects/4/tasks", "")
+	assert.Equal(t, 200, w.Code)
+	assert.Contains(t, w.Body.String(), "T_Project")
+
+	w = testV1TaskRouteMocks(nil, project(1), "PUT", "/v1/projects/4", `{"name":"P_Renamed"}`)
+	assert.Equal(t, 200, w.Code)
+	assert.Contains(t, w.Body.String(), config.SProjectUpdated)
+
+	w = testV1TaskRouteMocks(nil, project(1), "PUT", "/v1/projects/4", `{"name":""}`)
+	assert.Equal(t, 400, w.Code)
+
+	// the projects of the other users are not found
+	for _, r := range [][]string{{"GET", "/v1/projects/4"}, {"GET", "/v1/projects/4/tasks"}, {"PUT", "/v1/projects/4"}, {"DELETE", "/v1/projects/4"}} {
+		w = testV1TaskRouteMocks(nil, project(2), r[0], r[1], `{"name":"P_Other"}`)
+		assert.Equal(t, 404, w.Code, r)
+		assert.NotContains(t, w.Body.String(), "P_Work", r)
+	}
+
+	w = testV1TaskRouteMocks(nil, project(1), "DELETE", "/v1/projects/4?tasks=sometimes", "")
+	assert.Equal(t, 400, w.Code)
+	assert.Contains(t, w.Body.String(), config.SProjectInvalidDeleteMode)
+}
+
+func TestV1ProjectDeleteModes(t *testing.T) {
+	for _, mode := range []string{"", config.ProjectDeleteInbox, config.ProjectDeleteCascade} {
+		var updates []string
+		mocks := func() {
+			mocket.Catcher.NewMock().WithQuery(`FROM "projects"`).WithReply([]map[string]interface{}{{"id": 4, "user_id": 1, "name": "P_Work"}})
+			mocket.Catcher.NewMock().WithQuery(`UPDATE "tasks"`).WithCallback(func(q string, _ []driver.NamedValue) {
+				updates = append(updates, q)
+			})
+		}
+
+		path := "/v1/projects/4"
+		if len(mode) > 0 {
+			path += "?tasks=" + mode
+		}
+
+		w := testV1TaskRouteMocks(map[string]interface{}{"id": 5, "user_id": 1, "project_id": 4}, mocks, "DELETE", path, "")
+		assert.Equal(t, 200, w.Code, mode)
+		assert.Contains(t, w.Body.String(), config.SProjectDeleted)
+
+		// the tasks are moved to the inbox or deleted with the project
+		if assert.Len(t, updates, 1, mode) {
+			if mode == config.ProjectDeleteCascade {
+				assert.Contains(t, updates[0], `SET "deleted_at"`)
+			} else {
+				assert.Contains(t, updates[0], `"project_id"`)
+				assert.NotContains(t, updates[0], `"deleted_at"=`)
+			}
+			assert.Contains(t, updates[0], "project_id = ")
+		}
+	}
+}
+
+func TestV1TrashRoutes(t *testing.T) {
+	deletedAt := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	deleted := map[string]interface{}{"id": 5, "user_id": 1, "title": "T_Deleted", "deleted_at": deletedAt}
+	var updates []string
+	record := func(q string, _ []driver.NamedValue) { updates = append(updates, q) }
+	restore := func() {
+		mocket.Catcher.NewMock().WithQuery(`UPDATE "tasks"`).WithCallback(record)
+	}
+	purge := func() {
+		// the deletion matches before the lookups of the tasks
+		mocket.Catcher.Reset()
+		mocket.Catcher.NewMock().WithQuery(`DELETE FROM "tasks"`).WithCallback(record)
+		mocket.Catcher.NewMock().WithQuery(`SELECT count(*) FROM "sessions"`).WithReply([]map[string]interface{}{{"count": 1}})
+		mocket.Catcher.NewMock().WithQuery(`FROM "users"`).WithReply([]map[string]interface{}{{"id": 1, "active": true}})
+		mocket.Catcher.NewMock().WithQuery(`FROM "tasks"`).WithReply([]map[string]interface{}{deleted})
+	}
+
+	w := testV1TaskRoute(deleted, "GET", "/v1/todo/trash")
+	assert.Equal(t, 200, w.Code)
+	assert.Contains(t, w.Body.String(), "T_Deleted")
+
+	w = testV1TaskRouteMocks(deleted, restore, "POST", "/v1/todo/restore/5", "")
+	assert.Equal(t, 200, w.Code)
+	assert.Contains(t, w.Body.String(), config.STaskRestored)
+	if assert.Len(t, updates, 1) {
+		assert.Contains(t, updates[0], `SET "deleted_at" = ?`)
+	}
+
+	updates = nil
+	w = testV1TaskRouteMocks(deleted, purge, "DELETE", "/v1/todo/trash/5", "")
+	assert.Equal(t, 200, w.Code)
+	assert.Contains(t, w.Body.String(), config.STaskPurged)
+	if assert.Len(t, updates, 1) {
+		assert.Contains(t, updates[0], `DELETE FROM "tasks"  WHERE "tasks"."id" = ?`)
+	}
+
+	// the deleted tasks of the other users are not found
+	other := map[string]interface{}{"id": 5, "user_id": 2, "title": "T_Other", "deleted_at": deletedAt}
+	for _, r := range [][]string{{"POST", "/v1/todo/restore/5"}, {"DELETE", "/v1/todo/trash/5"}} {
+		w = testV1TaskRoute(other, r[0], r[1])
+		assert.Equal(t, 404, w.Code, r)
+		assert.NotContains(t, w.Body.String(), "T_Other", r)
+	}
+}
@@ -0,0 +1,41 @@
+package storage
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLocalStore(t *testing.T) {
+	root, err := ioutil.TempDir("", "store")
+	assert.Nil(t, err)
+	defer os.RemoveAll(root)
+
+	s, err := NewLocalStore(root)
+	assert.Nil(t, err)
+	testStore(t, s)
+
+	assert.NotNil(t, s.Put("../escape", strings.NewReader("a"), 1, "text/plain"))
+	assert.NotNil(t, s.Put("1/./a", strings.NewReader("a"), 1, "text/plain"))
+	assert.NotNil(t, s.Put("1/a", strings.NewReader("a"), 2, "text/plain"))
+}
+
+// testStore checks the put, get and delete of a blob on the store
+func testStore(t *testing.T, s Store) {
+	assert.Nil(t, s.Put("1/receipt", strings.NewReader("receipt"), 7, "text/plain"))
+
+	r, err := s.Get("1/receipt")
+	assert.Nil(t, err)
+	b, _ := ioutil.ReadAll(r)
+	r.Close()
+	assert.Equal(t, "receipt", string(b))
+
+	assert.Nil(t, s.Delete("1/receipt"))
+	assert.Nil(t, s.Delete("1/receipt"))
+
+	_, err = s.Get("1/receipt")
+	assert.Equal(t, ErrNotFound, err)
+}
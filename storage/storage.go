@@ -0,0 +1,22 @@
+// Package storage handle the blobs of the API Engine, like the attachments of
+// the tasks, on a pluggable store.
+package storage
+
+import (
+	"errors"
+	"io"
+)
+
+// ErrNotFound is returned when a blob does not exist
+var ErrNotFound = errors.New("blob not found")
+
+// Store is a store of blobs identified by key
+type Store interface {
+	// Put stores the size bytes of r as the blob key
+	Put(key string, r io.Reader, size int64, contentType string) error
+	// Get returns the content of the blob key, ErrNotFound if it does not
+	// exist. The caller must close the content.
+	Get(key string) (io.ReadCloser, error)
+	// Delete removes the blob key, deleting a missing blob is not an error
+	Delete(key string) error
+}
@@ -0,0 +1,80 @@
+package utils
+
+import (
+	"strings"
+	"time"
+
+	"github.com/giuliobosco/todoAPI/config"
+	"github.com/giuliobosco/todoAPI/model"
+
+	"github.com/jinzhu/gorm"
+)
+
+// accessTokenScopes are the scopes of the personal access tokens, with the
+// read scope included by each write scope
+var accessTokenScopes = map[string]string{
+	model.ScopeTasksRead:    "",
+	model.ScopeTasksWrite:   model.ScopeTasksRead,
+	model.ScopeProfileRead:  "",
+	model.ScopeProfileWrite: model.ScopeProfileRead,
+}
+
+// accessTokenTouchInterval is the minimum interval between the updates of the
+// last use of a personal access token
+const accessTokenTouchInterval = time.Minute
+
+// NewAccessToken returns a new personal access token, with its prefix
+func NewAccessToken() (string, error) {
+	token, err := GenerateRandomStringURLSafe(32)
+	if err != nil {
+		return "", err
+	}
+
+	return config.AccessTokenPrefix + strings.TrimRight(token, "="), nil
+}
+
+// ValidScopes returns true if scopes is a non empty space separated list of
+// scopes of the personal access tokens
+func ValidScopes(scopes string) bool {
+	fields := strings.Fields(scopes)
+	for _, scope := range fields {
+		if _, ok := accessTokenScopes[scope]; !ok {
+			return false
+		}
+	}
+
+	return len(fields) > 0
+}
+
+// HasScope returns true if the scopes include the scope, directly or by its
+// write scope
+func HasScope(scopes string, scope string) bool {
+	for _, s := range strings.Fields(scopes) {
+		if s == scope || accessTokenScopes[s] == scope {
+			return true
+		}
+	}
+
+	return false
+}
+
+// FindAccessToken returns the personal access token, if it is not revoked
+// and not expired
+func FindAccessToken(db *gorm.DB, token string) (model.AccessToken, bool) {
+	var accessToken model.AccessToken
+	err := db.Where("token_hash = ? AND (expires_at IS NULL OR expires_at > ?)", HashToken(token), time.Now()).First(&accessToken).Error
+
+	return accessToken, err == nil && accessToken.ID > 0
+}
+
+// TouchAccessToken records the use of the personal access token, at most
+// every accessTokenTouchInterval
+func TouchAccessToken(db *gorm.DB, accessToken model.AccessToken) error {
+	now := time.Now()
+	if accessToken.LastUsedAt != nil && now.Sub(*accessToken.LastUsedAt) < accessTokenTouchInterval {
+		return nil
+	}
+
+	// the use is not a change of the token, its version is not incremented
+	return db.Model(&accessToken).UpdateColumn("last_used_at", now).Error
+}
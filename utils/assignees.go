@@ -0,0 +1,43 @@
+package utils
+
+import (
+	"github.com/giuliobosco/todoAPI/model"
+
+	"github.com/jinzhu/gorm"
+)
+
+// LoadTaskAssignees sets the assignees of each task, with their emails
+func LoadTaskAssignees(db *gorm.DB, tasks []model.Task) error {
+	if len(tasks) == 0 {
+		return nil
+	}
+
+	ids := make([]uint, len(tasks))
+	for i, t := range tasks {
+		ids[i] = t.ID
+	}
+
+	var assignees []model.Assignee
+	err := db.Table("assignees").
+		Select("assignees.*, users.email").
+		Joins("JOIN users ON users.id = assignees.user_id").
+		Where("assignees.task_id IN (?)", ids).
+		Order("assignees.created_at asc").
+		Scan(&assignees).Error
+	if err != nil {
+		return err
+	}
+
+	byTask := make(map[uint][]model.Assignee)
+	for _, a := range assignees {
+		byTask[a.TaskID] = append(byTask[a.TaskID], a)
+	}
+	for i := range tasks {
+		tasks[i].Assignees = byTask[tasks[i].ID]
+		if tasks[i].Assignees == nil {
+			tasks[i].Assignees = []model.Assignee{}
+		}
+	}
+
+	return nil
+}
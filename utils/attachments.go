@@ -0,0 +1,41 @@
+package utils
+
+import (
+	"mime"
+	"net/http"
+
+	"github.com/giuliobosco/todoAPI/config"
+	"github.com/giuliobosco/todoAPI/model"
+
+	"github.com/jinzhu/gorm"
+)
+
+// AttachmentContentType returns the content type detected from the first
+// bytes of a file (see http.DetectContentType) and true if it is an allowed
+// attachment type
+func AttachmentContentType(head []byte) (string, bool) {
+	detected := http.DetectContentType(head)
+	mediaType, _, err := mime.ParseMediaType(detected)
+	if err != nil {
+		return detected, false
+	}
+
+	for _, t := range config.AttachmentContentTypes {
+		if t == mediaType {
+			return detected, true
+		}
+	}
+
+	return detected, false
+}
+
+// AttachmentUsage returns the size in bytes of the attachments of the user
+func AttachmentUsage(db *gorm.DB, userID uint) (int64, error) {
+	var usage struct{ Size int64 }
+	err := db.Model(&model.Attachment{}).Select("COALESCE(SUM(size), 0) AS size").Where("user_id = ?", userID).Scan(&usage).Error
+	if gorm.IsRecordNotFoundError(err) {
+		err = nil
+	}
+
+	return usage.Size, err
+}
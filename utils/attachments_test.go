@@ -0,0 +1,26 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAttachmentContentType(t *testing.T) {
+	detected, ok := AttachmentContentType([]byte("\x89PNG\x0D\x0A\x1A\x0A\x00\x00\x00\x0DIHDR"))
+	assert.True(t, ok)
+	assert.Equal(t, "image/png", detected)
+
+	detected, ok = AttachmentContentType([]byte("%PDF-1.4"))
+	assert.True(t, ok)
+	assert.Equal(t, "application/pdf", detected)
+
+	detected, ok = AttachmentContentType([]byte("Total: 12.50 CHF"))
+	assert.True(t, ok)
+	assert.Equal(t, "text/plain; charset=utf-8", detected)
+
+	_, ok = AttachmentContentType([]byte("<html><script>alert(1)</script></html>"))
+	assert.False(t, ok)
+	_, ok = AttachmentContentType([]byte("MZ\x90\x00"))
+	assert.False(t, ok)
+}
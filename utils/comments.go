@@ -0,0 +1,42 @@
+package utils
+
+import (
+	"github.com/giuliobosco/todoAPI/model"
+
+	"github.com/jinzhu/gorm"
+)
+
+// CountTaskComments sets the number of comments of each task
+func CountTaskComments(db *gorm.DB, tasks []model.Task) error {
+	if len(tasks) == 0 {
+		return nil
+	}
+
+	ids := make([]uint, len(tasks))
+	for i, t := range tasks {
+		ids[i] = t.ID
+	}
+
+	var counts []struct {
+		TaskID uint
+		Count  int
+	}
+	err := db.Model(&model.Comment{}).
+		Select("task_id, COUNT(*) AS count").
+		Where("task_id IN (?)", ids).
+		Group("task_id").
+		Scan(&counts).Error
+	if err != nil {
+		return err
+	}
+
+	byTask := make(map[uint]int)
+	for _, c := range counts {
+		byTask[c.TaskID] = c.Count
+	}
+	for i := range tasks {
+		tasks[i].CommentCount = byTask[tasks[i].ID]
+	}
+
+	return nil
+}
@@ -0,0 +1,97 @@
+package utils
+
+import (
+	"errors"
+	"time"
+
+	"github.com/giuliobosco/todoAPI/config"
+)
+
+// dateLayout is the layout of the dates without time accepted by the API
+const dateLayout = "2006-01-02"
+
+// LoadLocation returns the location named by tz, UTC if tz is empty
+func LoadLocation(tz string) (*time.Location, error) {
+	if len(tz) == 0 {
+		return time.UTC, nil
+	}
+
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		return nil, errors.New(config.SInvalidTimezone)
+	}
+
+	return loc, nil
+}
+
+// ParseDate parses a RFC 3339 timestamp or a plain date (YYYY-MM-DD), plain
+// dates are interpreted as midnight in loc
+func ParseDate(s string, loc *time.Location) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+
+	return time.ParseInLocation(dateLayout, s, loc)
+}
+
+// ParseDateRange parses the from and to bounds of a date range, a plain date
+// as upper bound includes the whole day
+func ParseDateRange(from string, to string, loc *time.Location) (time.Time, time.Time, error) {
+	var missing []string
+
+	if len(from) == 0 {
+		missing = append(missing, "from")
+	}
+	if len(to) == 0 {
+		missing = append(missing, "to")
+	}
+
+	if len(missing) > 0 {
+		var errorString = "Missing: "
+
+		for i, m := range missing {
+			if i > 0 {
+				errorString += ","
+			}
+			errorString += " " + m
+		}
+
+		return time.Time{}, time.Time{}, errors.New(errorString)
+	}
+
+	f, err := ParseDate(from, loc)
+	if err != nil {
+		return time.Time{}, time.Time{}, errors.New(config.SInvalidDateRange)
+	}
+	t, err := ParseDate(to, loc)
+	if err != nil {
+		return time.Time{}, time.Time{}, errors.New(config.SInvalidDateRange)
+	}
+	if _, err := time.Parse(dateLayout, to); err == nil {
+		t = t.AddDate(0, 0, 1)
+	}
+
+	if !f.Before(t) {
+		return time.Time{}, time.Time{}, errors.New(config.SInvalidDateRange)
+	}
+
+	return f, t, nil
+}
+
+// DayBounds returns the start of the day of now and the start of the next day
+func DayBounds(now time.Time) (time.Time, time.Time) {
+	y, m, d := now.Date()
+	start := time.Date(y, m, d, 0, 0, 0, 0, now.Location())
+
+	return start, start.AddDate(0, 0, 1)
+}
+
+// WeekBounds returns the start of the week (monday) of now and the start of
+// the next week
+func WeekBounds(now time.Time) (time.Time, time.Time) {
+	start, _ := DayBounds(now)
+	offset := (int(start.Weekday()) + 6) % 7
+	start = start.AddDate(0, 0, -offset)
+
+	return start, start.AddDate(0, 0, 7)
+}
@@ -0,0 +1,36 @@
+package utils
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseDateRange(t *testing.T) {
+	from, to, err := ParseDateRange("2020-01-01", "2020-01-31", time.UTC)
+	assert.Nil(t, err)
+	assert.Equal(t, time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC), from)
+	assert.Equal(t, time.Date(2020, 2, 1, 0, 0, 0, 0, time.UTC), to)
+
+	from, to, err = ParseDateRange("2020-01-01T10:00:00+01:00", "2020-01-01T12:00:00+01:00", time.UTC)
+	assert.Nil(t, err)
+	assert.Equal(t, 2*time.Hour, to.Sub(from))
+
+	ranges := [][]string{{"", ""}, {"2020-01-01", ""}, {"a", "2020-01-01"}, {"2020-02-01", "2020-01-01"}}
+	for _, r := range ranges {
+		_, _, err = ParseDateRange(r[0], r[1], time.UTC)
+		assert.NotNil(t, err)
+	}
+}
+
+func TestWeekBounds(t *testing.T) {
+	// 2020-01-01 is a wednesday
+	from, to := WeekBounds(time.Date(2020, 1, 1, 15, 0, 0, 0, time.UTC))
+	assert.Equal(t, time.Date(2019, 12, 30, 0, 0, 0, 0, time.UTC), from)
+	assert.Equal(t, time.Date(2020, 1, 6, 0, 0, 0, 0, time.UTC), to)
+
+	// sunday belongs to the week started on monday
+	from, _ = WeekBounds(time.Date(2020, 1, 5, 23, 0, 0, 0, time.UTC))
+	assert.Equal(t, time.Date(2019, 12, 30, 0, 0, 0, 0, time.UTC), from)
+}
@@ -0,0 +1,134 @@
+package utils
+
+import (
+	"github.com/giuliobosco/todoAPI/model"
+
+	"github.com/jinzhu/gorm"
+)
+
+// openBlockers selects the dependencies on the blockers not completed and
+// not deleted
+const openBlockers = "blocker_id IN (SELECT id FROM tasks WHERE completed = ? AND deleted_at IS NULL)"
+
+// OpenBlockerIDs returns the ids of the open tasks blocking the task
+func OpenBlockerIDs(db *gorm.DB, taskID uint) []uint {
+	var ids []uint
+	db.Model(&model.TaskDependency{}).Where("task_id = ?", taskID).Where(openBlockers, false).Pluck("blocker_id", &ids)
+
+	return ids
+}
+
+// OpenDependencies returns the dependencies of the tasks on open blockers
+func OpenDependencies(db *gorm.DB, taskIDs []uint) []model.TaskDependency {
+	dependencies := []model.TaskDependency{}
+	if len(taskIDs) == 0 {
+		return dependencies
+	}
+
+	db.Where("task_id IN (?)", taskIDs).Where(openBlockers, false).Order("id asc").Find(&dependencies)
+
+	return dependencies
+}
+
+// LockTasks locks the rows of the tasks until the end of the transaction db.
+// SQLite does not support SELECT ... FOR UPDATE, its transactions already
+// serialize the writes.
+func LockTasks(db *gorm.DB, ids []uint) error {
+	if len(ids) == 0 || db.Dialect().GetName() == "sqlite3" {
+		return nil
+	}
+
+	var locked []model.Task
+	return db.Unscoped().Set("gorm:query_option", "FOR UPDATE").Select("id").Where("id IN (?)", ids).Order("id asc").Find(&locked).Error
+}
+
+// DependencyCycle returns true if blocking the task with the blocker creates
+// a cycle, that is if the task already blocks the blocker, directly or
+// through other tasks. In a transaction the task and the visited tasks are
+// locked before reading their blockers, so concurrent additions creating a
+// cycle together wait for each other and the last one sees the cycle.
+func DependencyCycle(db *gorm.DB, taskID uint, blockerID uint) (bool, error) {
+	if taskID == blockerID {
+		return true, nil
+	}
+	if err := LockTasks(db, []uint{taskID}); err != nil {
+		return false, err
+	}
+
+	visited := map[uint]bool{blockerID: true}
+	frontier := []uint{blockerID}
+	for len(frontier) > 0 {
+		if err := LockTasks(db, frontier); err != nil {
+			return false, err
+		}
+		var blockers []uint
+		if err := db.Model(&model.TaskDependency{}).Where("task_id IN (?)", frontier).Pluck("blocker_id", &blockers).Error; err != nil {
+			return false, err
+		}
+
+		frontier = nil
+		for _, id := range blockers {
+			if id == taskID {
+				return true, nil
+			}
+			if !visited[id] {
+				visited[id] = true
+				frontier = append(frontier, id)
+			}
+		}
+	}
+
+	return false, nil
+}
+
+// BuildTaskGraph builds the dependency graph of the open tasks: the tasks
+// are ordered after their blockers, keeping their order otherwise, and the
+// available tasks are the ones without open blockers. The blockers not in
+// tasks do not change the order but make their tasks not available.
+func BuildTaskGraph(tasks []model.Task, dependencies []model.TaskDependency) model.TaskGraph {
+	graph := model.TaskGraph{Tasks: tasks, Edges: dependencies, Order: []uint{}, Available: []model.Task{}}
+
+	byID := make(map[uint]model.Task)
+	for _, t := range tasks {
+		byID[t.ID] = t
+	}
+
+	blocked := make(map[uint]int)
+	pending := make(map[uint]int)
+	blocks := make(map[uint][]uint)
+	for _, d := range dependencies {
+		blocked[d.TaskID]++
+		if _, ok := byID[d.BlockerID]; ok {
+			pending[d.TaskID]++
+			blocks[d.BlockerID] = append(blocks[d.BlockerID], d.TaskID)
+		}
+	}
+
+	for _, t := range tasks {
+		if blocked[t.ID] == 0 {
+			graph.Available = append(graph.Available, t)
+		}
+	}
+
+	// Kahn's algorithm, the cycles are prevented when adding the dependencies
+	var queue []uint
+	for _, t := range tasks {
+		if pending[t.ID] == 0 {
+			queue = append(queue, t.ID)
+		}
+	}
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		graph.Order = append(graph.Order, id)
+
+		for _, next := range blocks[id] {
+			pending[next]--
+			if pending[next] == 0 {
+				queue = append(queue, next)
+			}
+		}
+	}
+
+	return graph
+}
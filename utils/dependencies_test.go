@@ -0,0 +1,83 @@
+package utils
+
+import (
+	"database/sql/driver"
+	"testing"
+
+	"github.com/giuliobosco/todoAPI/config"
+	"github.com/giuliobosco/todoAPI/model"
+
+	mocket "github.com/selvatico/go-mocket"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildTaskGraph(t *testing.T) {
+	task := func(id uint) model.Task { return model.Task{Base: model.Base{ID: id}} }
+	dependency := func(taskID uint, blockerID uint) model.TaskDependency {
+		return model.TaskDependency{TaskID: taskID, BlockerID: blockerID}
+	}
+
+	tasks := []model.Task{task(1), task(2), task(3), task(4)}
+	graph := BuildTaskGraph(tasks, []model.TaskDependency{
+		dependency(1, 3),
+		dependency(3, 2),
+		dependency(4, 9), // blocker not in the graph
+	})
+
+	assert.Equal(t, []uint{2, 4, 3, 1}, graph.Order)
+	assert.Len(t, graph.Available, 1)
+	assert.Equal(t, uint(2), graph.Available[0].ID)
+	assert.Len(t, graph.Edges, 3)
+
+	graph = BuildTaskGraph(tasks, nil)
+	assert.Equal(t, []uint{1, 2, 3, 4}, graph.Order)
+	assert.Len(t, graph.Available, 4)
+
+	graph = BuildTaskGraph(nil, nil)
+	assert.Empty(t, graph.Order)
+	assert.Empty(t, graph.Available)
+}
+
+func TestDependencyCycle(t *testing.T) {
+	db := config.TestInit()
+
+	// 2 is blocked by 3, 3 by 1
+	mocket.Catcher.Reset()
+	mocket.Catcher.NewMock().WithQuery(`FROM "task_dependencies"`).WithArgs(int64(2)).WithReply([]map[string]interface{}{{"blocker_id": 3}})
+	mocket.Catcher.NewMock().WithQuery(`FROM "task_dependencies"`).WithArgs(int64(3)).WithReply([]map[string]interface{}{{"blocker_id": 1}})
+
+	cycle, err := DependencyCycle(db, 1, 2)
+	assert.NoError(t, err)
+	assert.True(t, cycle)
+
+	cycle, err = DependencyCycle(db, 4, 2)
+	assert.NoError(t, err)
+	assert.False(t, cycle)
+
+	cycle, err = DependencyCycle(db, 2, 2)
+	assert.NoError(t, err)
+	assert.True(t, cycle)
+
+	// the task and the visited tasks are locked before reading their blockers
+	var queries []string
+	record := func(q string, _ []driver.NamedValue) { queries = append(queries, q) }
+	mocket.Catcher.Reset()
+	mocket.Catcher.NewMock().WithQuery(`FROM "tasks"`).WithCallback(record)
+	mocket.Catcher.NewMock().WithQuery(`FROM "task_dependencies"`).WithArgs(int64(2)).WithReply([]map[string]interface{}{{"blocker_id": 3}}).WithCallback(record)
+	mocket.Catcher.NewMock().WithQuery(`FROM "task_dependencies"`).WithCallback(record)
+	_, err = DependencyCycle(db, 1, 2)
+	assert.NoError(t, err)
+	assert.Len(t, queries, 5)
+	for i, table := range []string{`"tasks"`, `"tasks"`, `"task_dependencies"`, `"tasks"`, `"task_dependencies"`} {
+		assert.Contains(t, queries[i], table)
+		if table == `"tasks"` {
+			assert.Contains(t, queries[i], "FOR UPDATE")
+		}
+	}
+
+	// a failed query is not taken as no cycle
+	mocket.Catcher.Reset()
+	mocket.Catcher.NewMock().WithQuery(`FROM "task_dependencies"`).WithQueryException()
+	_, err = DependencyCycle(db, 1, 2)
+	assert.Error(t, err)
+}
@@ -0,0 +1,27 @@
+package utils
+
+import (
+	"strconv"
+	"strings"
+)
+
+// ETag returns the entity tag of an object version
+func ETag(version uint) string {
+	return `"` + strconv.FormatUint(uint64(version), 10) + `"`
+}
+
+// ETagMatches returns true if the If-Match or If-None-Match header value
+// matches the object version. The header is * or a comma separated list of
+// entity tags, weak tags (W/ prefix) are compared as strong ones.
+func ETagMatches(header string, version uint) bool {
+	etag := ETag(version)
+
+	for _, t := range strings.Split(header, ",") {
+		t = strings.TrimPrefix(strings.TrimSpace(t), "W/")
+		if t == "*" || t == etag {
+			return true
+		}
+	}
+
+	return false
+}
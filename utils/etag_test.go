@@ -0,0 +1,19 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestETagMatches(t *testing.T) {
+	assert.Equal(t, `"3"`, ETag(3))
+
+	assert.True(t, ETagMatches(`"3"`, 3))
+	assert.True(t, ETagMatches(`*`, 3))
+	assert.True(t, ETagMatches(`"1", W/"3"`, 3))
+
+	assert.False(t, ETagMatches(`"2"`, 3))
+	assert.False(t, ETagMatches(`3`, 3))
+	assert.False(t, ETagMatches(`"33"`, 3))
+}
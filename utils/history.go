@@ -0,0 +1,128 @@
+package utils
+
+import (
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/giuliobosco/todoAPI/model"
+
+	"github.com/jinzhu/gorm"
+)
+
+// TaskHistoryFields returns the fields of the task tracked by the history, by
+// name, with values comparable with reflect.DeepEqual
+func TaskHistoryFields(task model.Task) map[string]interface{} {
+	tags := []string{}
+	for _, t := range task.Tags {
+		tags = append(tags, t.Name)
+	}
+	sort.Strings(tags)
+
+	return map[string]interface{}{
+		"title":       task.Title,
+		"description": task.Description,
+		"completed":   task.Completed,
+		"priority":    task.Priority,
+		"start_at":    historyTime(task.StartAt),
+		"due_at":      historyTime(task.DueAt),
+		"recurrence":  task.Recurrence,
+		"project_id":  historyID(task.ProjectID),
+		"parent_id":   historyID(task.ParentID),
+		"tags":        tags,
+	}
+}
+
+// historyTime returns the time in UTC, nil for no time
+func historyTime(t *time.Time) interface{} {
+	if t == nil {
+		return nil
+	}
+
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+// historyID returns the id, nil for no id
+func historyID(id *uint) interface{} {
+	if id == nil {
+		return nil
+	}
+
+	return *id
+}
+
+// TaskChanges returns the fields changed from before to after, all the fields
+// of after if before is nil
+func TaskChanges(before *model.Task, after model.Task) map[string]model.FieldChange {
+	changes := make(map[string]model.FieldChange)
+
+	var old map[string]interface{}
+	if before != nil {
+		old = TaskHistoryFields(*before)
+	}
+
+	for name, value := range TaskHistoryFields(after) {
+		if old == nil {
+			changes[name] = model.FieldChange{New: value}
+		} else if !reflect.DeepEqual(old[name], value) {
+			changes[name] = model.FieldChange{Old: old[name], New: value}
+		}
+	}
+
+	return changes
+}
+
+// RecordTaskRevision appends to the history of the task the change of the
+// user from before (nil for a new task) to after. An update completing the
+// task is recorded as a completion, updates and reverts changing nothing are
+// not recorded. db must be a transaction, the row of the task stays locked
+// until its end so the concurrent changes get consecutive revisions.
+func RecordTaskRevision(db *gorm.DB, userID uint, action string, before *model.Task, after model.Task) error {
+	if action == model.ActionUpdate && before != nil && after.Completed && !before.Completed {
+		action = model.ActionComplete
+	}
+
+	changes := TaskChanges(before, after)
+	if (action == model.ActionUpdate || action == model.ActionRevert) && len(changes) == 0 {
+		return nil
+	}
+
+	var locked []model.Task
+	if err := db.Unscoped().Set("gorm:query_option", "FOR UPDATE").Select("id").Where("id = ?", after.ID).Find(&locked).Error; err != nil {
+		return err
+	}
+
+	var last struct{ Revision uint }
+	if err := db.Table("task_revisions").Select("COALESCE(MAX(revision), 0) AS revision").Where("task_id = ?", after.ID).Scan(&last).Error; err != nil && !gorm.IsRecordNotFoundError(err) {
+		return err
+	}
+
+	revision := model.TaskRevision{
+		TaskID:   after.ID,
+		Revision: last.Revision + 1,
+		UserID:   userID,
+		Action:   action,
+		Changes:  changes,
+		Task:     after,
+	}
+
+	return db.Create(&revision).Error
+}
+
+// TaskRevertUpdates returns the columns to update to revert the task to the
+// state of the revision
+func TaskRevertUpdates(revision model.TaskRevision) map[string]interface{} {
+	t := revision.Task
+
+	return map[string]interface{}{
+		"title":       t.Title,
+		"description": t.Description,
+		"completed":   t.Completed,
+		"priority":    t.Priority,
+		"start_at":    t.StartAt,
+		"due_at":      t.DueAt,
+		"recurrence":  t.Recurrence,
+		"project_id":  t.ProjectID,
+		"parent_id":   t.ParentID,
+	}
+}
@@ -0,0 +1,75 @@
+package utils
+
+import (
+	"database/sql/driver"
+	"testing"
+	"time"
+
+	"github.com/giuliobosco/todoAPI/config"
+	"github.com/giuliobosco/todoAPI/model"
+
+	mocket "github.com/selvatico/go-mocket"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTaskChanges(t *testing.T) {
+	due := time.Date(2020, 5, 1, 12, 0, 0, 0, time.FixedZone("CEST", 2*3600))
+	project := uint(3)
+	before := model.Task{Title: "Buy milk", Tags: []model.Tag{{Name: "errands"}}}
+	after := model.Task{Title: "Buy milk", Completed: true, DueAt: &due, ProjectID: &project, Tags: []model.Tag{{Name: "work"}, {Name: "errands"}}}
+
+	assert.Equal(t, map[string]model.FieldChange{
+		"completed":  {Old: false, New: true},
+		"due_at":     {Old: nil, New: "2020-05-01T10:00:00Z"},
+		"project_id": {Old: nil, New: uint(3)},
+		"tags":       {Old: []string{"errands"}, New: []string{"errands", "work"}},
+	}, TaskChanges(&before, after))
+
+	assert.Empty(t, TaskChanges(&after, after))
+
+	created := TaskChanges(nil, before)
+	assert.Len(t, created, 10)
+	assert.Equal(t, model.FieldChange{New: "Buy milk"}, created["title"])
+}
+
+func TestTaskRevertUpdates(t *testing.T) {
+	project := uint(3)
+	updates := TaskRevertUpdates(model.TaskRevision{Task: model.Task{Title: "Buy milk", Priority: model.PriorityHigh, ProjectID: &project}})
+
+	assert.Equal(t, "Buy milk", updates["title"])
+	assert.Equal(t, model.PriorityHigh, updates["priority"])
+	assert.Equal(t, &project, updates["project_id"])
+	assert.Nil(t, updates["parent_id"])
+	assert.Len(t, updates, 9)
+}
+
+func TestRecordTaskRevision(t *testing.T) {
+	db := config.TestInit()
+
+	var queries []string
+	var revision interface{}
+	record := func(q string, _ []driver.NamedValue) { queries = append(queries, q) }
+	mocket.Catcher.Reset()
+	mocket.Catcher.NewMock().WithQuery(`FROM "tasks"`).WithReply([]map[string]interface{}{{"id": 5}}).WithCallback(record)
+	mocket.Catcher.NewMock().WithQuery(`MAX(revision)`).WithReply([]map[string]interface{}{{"revision": 2}}).WithCallback(record)
+	mocket.Catcher.NewMock().WithQuery(`INSERT INTO "task_revisions"`).WithCallback(func(q string, args []driver.NamedValue) {
+		record(q, args)
+		revision = args[2].Value
+	})
+
+	before := model.Task{Base: model.Base{ID: 5}, Title: "Buy milk"}
+	after := before
+	after.Completed = true
+	assert.NoError(t, RecordTaskRevision(db, 1, model.ActionUpdate, &before, after))
+
+	// the task is locked before the last revision is read
+	assert.Len(t, queries, 3)
+	assert.Contains(t, queries[0], "FOR UPDATE")
+	assert.Contains(t, queries[1], "MAX(revision)")
+	assert.EqualValues(t, 3, revision)
+
+	// no changes, nothing recorded
+	queries = nil
+	assert.NoError(t, RecordTaskRevision(db, 1, model.ActionUpdate, &after, after))
+	assert.Empty(t, queries)
+}
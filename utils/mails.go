@@ -3,39 +3,61 @@ package utils
 import (
 	"log"
 	"net/smtp"
-	"os"
 
 	"github.com/giuliobosco/todoAPI/config"
 	"github.com/giuliobosco/todoAPI/model"
 )
 
-var (
-	smtpServer   = os.Getenv("SMTP_SERVER")
-	smtpPort     = os.Getenv("SMTP_PORT")
-	smtpUsername = os.Getenv("SMTP_USERNAME")
-	smtpPassword = os.Getenv("SMTP_PASSWORD")
-)
-
 func UserConfirmationSendMail(user model.User) {
-	auth := smtp.PlainAuth("", smtpUsername, smtpPassword, smtpServer)
+	smtpConfig := config.Get().SMTP
+	auth := smtp.PlainAuth("", smtpConfig.Username, string(smtpConfig.Password), smtpConfig.Server)
 
 	// Here we do it all: connect to our server, set up a message and send it
 	to := []string{user.Email}
-	msg := config.BuildConfirmEmail(user, smtpUsername)
-	err := smtp.SendMail(smtpServer+":"+smtpPort, auth, user.Email, to, msg)
+	msg := config.BuildConfirmEmail(user, smtpConfig.Username)
+	err := smtp.SendMail(smtpConfig.Server+":"+smtpConfig.Port, auth, user.Email, to, msg)
 	if err != nil {
 		log.Fatal(err)
 	}
 }
 
 func UserPasswordRecoverySendMail(user model.User) {
-	auth := smtp.PlainAuth("", smtpUsername, smtpPassword, smtpServer)
+	smtpConfig := config.Get().SMTP
+	auth := smtp.PlainAuth("", smtpConfig.Username, string(smtpConfig.Password), smtpConfig.Server)
 
 	// Here we do it all: connect to our server, set up a message and send it
 	to := []string{user.Email}
-	msg := config.BuildPasswordRecovery(user, smtpUsername)
-	err := smtp.SendMail(smtpServer+":"+smtpPort, auth, user.Email, to, msg)
+	msg := config.BuildPasswordRecovery(user, smtpConfig.Username)
+	err := smtp.SendMail(smtpConfig.Server+":"+smtpConfig.Port, auth, user.Email, to, msg)
 	if err != nil {
 		log.Fatal(err)
 	}
 }
+
+// WorkspaceInvitationSendMail sends the invitation in the workspace, the
+// errors are only logged since the invitation can be sent again
+func WorkspaceInvitationSendMail(invitation model.WorkspaceInvitation, workspace model.Workspace, inviter model.User) {
+	smtpConfig := config.Get().SMTP
+	auth := smtp.PlainAuth("", smtpConfig.Username, string(smtpConfig.Password), smtpConfig.Server)
+
+	to := []string{invitation.Email}
+	msg := config.BuildWorkspaceInvitation(invitation, workspace, inviter, smtpConfig.Username)
+	err := smtp.SendMail(smtpConfig.Server+":"+smtpConfig.Port, auth, smtpConfig.Username, to, msg)
+	if err != nil {
+		log.Printf("invitation mail error: %s", err)
+	}
+}
+
+// TaskAssignmentSendMail notifies the assignee of the task, the errors are
+// only logged since the task is already assigned
+func TaskAssignmentSendMail(task model.Task, assignee model.User, assigner model.User) {
+	smtpConfig := config.Get().SMTP
+	auth := smtp.PlainAuth("", smtpConfig.Username, string(smtpConfig.Password), smtpConfig.Server)
+
+	to := []string{assignee.Email}
+	msg := config.BuildTaskAssignment(task, assignee, assigner, smtpConfig.Username)
+	err := smtp.SendMail(smtpConfig.Server+":"+smtpConfig.Port, auth, smtpConfig.Username, to, msg)
+	if err != nil {
+		log.Printf("assignment mail error: %s", err)
+	}
+}
@@ -0,0 +1,235 @@
+package utils
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/giuliobosco/todoAPI/config"
+	"github.com/giuliobosco/todoAPI/model"
+
+	"github.com/jinzhu/gorm"
+)
+
+const (
+	// DefaultPageLimit is the number of items of a page without limit parameter
+	DefaultPageLimit = 50
+	// MaxPageLimit is the maximum number of items of a page
+	MaxPageLimit = 100
+)
+
+// Cursor is the position of an item in a listing ordered by created_at, id
+type Cursor struct {
+	CreatedAt time.Time // creation time of the item
+	ID        uint      // id of the item
+}
+
+// CursorOf returns the cursor of the object
+func CursorOf(b model.Base) Cursor {
+	return Cursor{CreatedAt: b.CreatedAt, ID: b.ID}
+}
+
+// Encode returns the opaque string rappresentation of the cursor
+func (c Cursor) Encode() string {
+	s := c.CreatedAt.UTC().Format(time.RFC3339Nano) + "|" + strconv.FormatUint(uint64(c.ID), 10)
+	return base64.RawURLEncoding.EncodeToString([]byte(s))
+}
+
+// DecodeCursor parses a cursor encoded by Cursor.Encode
+func DecodeCursor(s string) (*Cursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, errors.New(config.SInvalidCursor)
+	}
+
+	parts := strings.Split(string(b), "|")
+	if len(parts) != 2 {
+		return nil, errors.New(config.SInvalidCursor)
+	}
+
+	t, err := time.Parse(time.RFC3339Nano, parts[0])
+	if err != nil {
+		return nil, errors.New(config.SInvalidCursor)
+	}
+	id, err := strconv.ParseUint(parts[1], 10, 32)
+	if err != nil {
+		return nil, errors.New(config.SInvalidCursor)
+	}
+
+	return &Cursor{CreatedAt: t, ID: uint(id)}, nil
+}
+
+// Page is the rappresentation of a page request of a listing ordered by
+// created_at, id, or of a listing with a custom order paged by offset
+type Page struct {
+	Limit  int     // maximum number of items of the page
+	After  *Cursor // page of the items after the cursor (next page)
+	Before *Cursor // page of the items before the cursor (previous page)
+	Offset int     // number of items skipped, listings with a custom order
+	Desc   bool    // listing in descending order
+	Keyset bool    // listing ordered by created_at, id, cursors allowed
+}
+
+// Pagination is the pagination metadata of a page response
+type Pagination struct {
+	Limit      int    `json:"limit"`                 // maximum number of items of the page
+	HasMore    bool   `json:"has_more"`              // true if there is a next page
+	NextCursor string `json:"next_cursor,omitempty"` // cursor of the next page, if any
+	PrevCursor string `json:"prev_cursor,omitempty"` // cursor of the previous page, if any
+	NextOffset *int   `json:"next_offset,omitempty"` // offset of the next page, if any
+	PrevOffset *int   `json:"prev_offset,omitempty"` // offset of the previous page, if any
+}
+
+// ParsePage parses and validates the limit, after, before and offset query
+// parameters. If keyset is false the listing has a custom order and is paged
+// by offset instead of the cursors.
+func ParsePage(p url.Values, desc bool, keyset bool) (*Page, error) {
+	page := Page{Limit: DefaultPageLimit, Desc: desc, Keyset: keyset}
+
+	if v := p.Get("limit"); len(v) > 0 {
+		l, err := strconv.Atoi(v)
+		if err != nil || l <= 0 || l > MaxPageLimit {
+			return nil, errors.New(config.SInvalidLimit)
+		}
+		page.Limit = l
+	}
+
+	after := p.Get("after")
+	before := p.Get("before")
+	if len(after) > 0 && len(before) > 0 {
+		return nil, errors.New(config.SInvalidCursor)
+	}
+	if (len(after) > 0 || len(before) > 0) && !keyset {
+		return nil, errors.New(config.SCursorRequiresKeyset)
+	}
+
+	if v := p.Get("offset"); len(v) > 0 {
+		if keyset {
+			return nil, errors.New(config.SOffsetRequiresSort)
+		}
+		o, err := strconv.Atoi(v)
+		if err != nil || o < 0 {
+			return nil, errors.New(config.SInvalidOffset)
+		}
+		page.Offset = o
+	}
+
+	var err error
+	if len(after) > 0 {
+		if page.After, err = DecodeCursor(after); err != nil {
+			return nil, err
+		}
+	}
+	if len(before) > 0 {
+		if page.Before, err = DecodeCursor(before); err != nil {
+			return nil, err
+		}
+	}
+
+	return &page, nil
+}
+
+// Apply adds the cursor condition, the order and the limit of the page to db,
+// one item more than the limit is fetched to known if there are more items.
+// If the page is a previous page the items are fetched in reverse order, see
+// Page.Reversed.
+func (p *Page) Apply(db *gorm.DB) *gorm.DB {
+	if !p.Keyset {
+		// id makes the order of the items with the same sort values stable
+		// between the pages
+		return db.Order("id desc").Offset(p.Offset).Limit(p.Limit + 1)
+	}
+
+	forward := p.Before == nil
+	desc := p.Desc == forward
+
+	c := p.After
+	if !forward {
+		c = p.Before
+	}
+	if c != nil {
+		op := ">"
+		if desc {
+			op = "<"
+		}
+		db = db.Where("created_at "+op+" ? OR (created_at = ? AND id "+op+" ?)", c.CreatedAt, c.CreatedAt, c.ID)
+	}
+
+	direction := " asc"
+	if desc {
+		direction = " desc"
+	}
+
+	return db.Order("created_at" + direction).Order("id" + direction).Limit(p.Limit + 1)
+}
+
+// Reversed returns true if the items are fetched in reverse order and must be
+// reversed before the response
+func (p *Page) Reversed() bool {
+	return p.Before != nil
+}
+
+// Paginate trims the fetched items (pointer to a slice) to the page, puts them
+// back in the order of the page and builds the pagination metadata, base
+// returns the base object of the item at index i of the trimmed slice
+func (p *Page) Paginate(items interface{}, base func(i int) model.Base) Pagination {
+	v := reflect.ValueOf(items).Elem()
+	fetched := v.Len()
+	if fetched > p.Limit {
+		v.Set(v.Slice(0, p.Limit))
+	}
+
+	n := v.Len()
+	if p.Reversed() {
+		swap := reflect.Swapper(v.Interface())
+		for i, j := 0, n-1; i < j; i, j = i+1, j-1 {
+			swap(i, j)
+		}
+	}
+
+	if n == 0 {
+		return p.Pagination(fetched, nil, nil)
+	}
+	first, last := base(0), base(n-1)
+
+	return p.Pagination(fetched, &first, &last)
+}
+
+// Pagination builds the pagination metadata of the page, fetched is the number
+// of items fetched by the query, first and last are the first and the last
+// items of the page after the reversing (nil if the page is empty).
+func (p *Page) Pagination(fetched int, first *model.Base, last *model.Base) Pagination {
+	pagination := Pagination{Limit: p.Limit}
+	more := fetched > p.Limit
+
+	if !p.Keyset {
+		pagination.HasMore = more
+		if more {
+			next := p.Offset + p.Limit
+			pagination.NextOffset = &next
+		}
+		if p.Offset > 0 {
+			prev := p.Offset - p.Limit
+			if prev < 0 {
+				prev = 0
+			}
+			pagination.PrevOffset = &prev
+		}
+
+		return pagination
+	}
+
+	if last != nil && (more && p.Before == nil || p.Before != nil) {
+		pagination.NextCursor = CursorOf(*last).Encode()
+		pagination.HasMore = true
+	}
+	if first != nil && (more && p.Before != nil || p.After != nil) {
+		pagination.PrevCursor = CursorOf(*first).Encode()
+	}
+
+	return pagination
+}
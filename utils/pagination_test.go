@@ -0,0 +1,143 @@
+package utils
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/giuliobosco/todoAPI/model"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCursorEncodeDecode(t *testing.T) {
+	c := Cursor{CreatedAt: time.Date(2020, 1, 2, 3, 4, 5, 6000, time.UTC), ID: 42}
+
+	d, err := DecodeCursor(c.Encode())
+	assert.Nil(t, err)
+	assert.True(t, c.CreatedAt.Equal(d.CreatedAt))
+	assert.Equal(t, c.ID, d.ID)
+
+	for _, s := range []string{"", "!!", "YQ", Cursor{}.Encode() + "x"} {
+		_, err = DecodeCursor(s)
+		assert.NotNil(t, err, s)
+	}
+}
+
+func TestParsePage(t *testing.T) {
+	p, err := ParsePage(url.Values{}, true, true)
+	assert.Nil(t, err)
+	assert.Equal(t, DefaultPageLimit, p.Limit)
+
+	c := Cursor{ID: 1}.Encode()
+	p, err = ParsePage(url.Values{"limit": {"10"}, "after": {c}}, true, true)
+	assert.Nil(t, err)
+	assert.Equal(t, 10, p.Limit)
+	assert.Equal(t, uint(1), p.After.ID)
+
+	invalid := []url.Values{
+		{"limit": {"0"}},
+		{"limit": {"1000"}},
+		{"limit": {"ten"}},
+		{"after": {c}, "before": {c}},
+		{"before": {"nope"}},
+	}
+	for _, v := range invalid {
+		_, err = ParsePage(v, true, true)
+		assert.NotNil(t, err, v)
+	}
+
+	// custom order, paged by offset
+	p, err = ParsePage(url.Values{"limit": {"10"}, "offset": {"100"}}, false, false)
+	assert.Nil(t, err)
+	assert.Equal(t, 100, p.Offset)
+
+	invalid = []url.Values{
+		{"offset": {"-1"}},
+		{"offset": {"ten"}},
+		{"after": {c}},
+	}
+	for _, v := range invalid {
+		_, err = ParsePage(v, false, false)
+		assert.NotNil(t, err, v)
+	}
+	_, err = ParsePage(url.Values{"offset": {"10"}}, true, true)
+	assert.NotNil(t, err)
+}
+
+func TestPagePagination(t *testing.T) {
+	first := &model.Base{ID: 3}
+	last := &model.Base{ID: 2}
+
+	// first page with more items
+	p := &Page{Limit: 2, Keyset: true}
+	pg := p.Pagination(3, first, last)
+	assert.Equal(t, CursorOf(*last).Encode(), pg.NextCursor)
+	assert.Empty(t, pg.PrevCursor)
+	assert.True(t, pg.HasMore)
+
+	// last page reached from a cursor
+	p = &Page{Limit: 2, Keyset: true, After: &Cursor{ID: 4}}
+	pg = p.Pagination(2, first, last)
+	assert.Empty(t, pg.NextCursor)
+	assert.False(t, pg.HasMore)
+	assert.Equal(t, CursorOf(*first).Encode(), pg.PrevCursor)
+
+	// empty page
+	pg = p.Pagination(0, nil, nil)
+	assert.Empty(t, pg.NextCursor)
+	assert.Empty(t, pg.PrevCursor)
+}
+
+func TestPageOffsetPagination(t *testing.T) {
+	// first page with more items
+	p := &Page{Limit: 100}
+	pg := p.Pagination(101, nil, nil)
+	assert.True(t, pg.HasMore)
+	assert.Equal(t, 100, *pg.NextOffset)
+	assert.Nil(t, pg.PrevOffset)
+	assert.Empty(t, pg.NextCursor)
+
+	// last page
+	p = &Page{Limit: 100, Offset: 150}
+	pg = p.Pagination(20, nil, nil)
+	assert.False(t, pg.HasMore)
+	assert.Nil(t, pg.NextOffset)
+	assert.Equal(t, 50, *pg.PrevOffset)
+}
+
+func TestPagePaginate(t *testing.T) {
+	items := func(ids ...uint) []model.Comment {
+		comments := []model.Comment{}
+		for _, id := range ids {
+			comments = append(comments, model.Comment{Base: model.Base{ID: id}})
+		}
+		return comments
+	}
+	ids := func(comments []model.Comment) []uint {
+		result := []uint{}
+		for _, c := range comments {
+			result = append(result, c.ID)
+		}
+		return result
+	}
+
+	// the extra item is trimmed
+	comments := items(1, 2, 3)
+	p := &Page{Limit: 2, Keyset: true}
+	pg := p.Paginate(&comments, func(i int) model.Base { return comments[i].Base })
+	assert.Equal(t, []uint{1, 2}, ids(comments))
+	assert.Equal(t, CursorOf(comments[1].Base).Encode(), pg.NextCursor)
+
+	// a previous page is fetched in reverse order
+	comments = items(5, 4, 3)
+	p = &Page{Limit: 2, Keyset: true, Before: &Cursor{ID: 6}}
+	pg = p.Paginate(&comments, func(i int) model.Base { return comments[i].Base })
+	assert.Equal(t, []uint{4, 5}, ids(comments))
+	assert.Equal(t, CursorOf(comments[0].Base).Encode(), pg.PrevCursor)
+
+	comments = items()
+	pg = p.Paginate(&comments, func(i int) model.Base { return comments[i].Base })
+	assert.Empty(t, comments)
+	assert.Empty(t, pg.NextCursor)
+}
@@ -0,0 +1,180 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+
+	"github.com/giuliobosco/todoAPI/config"
+	"github.com/giuliobosco/todoAPI/model"
+)
+
+// taskPatchColumns is the whitelist of the task fields modifiable by a patch,
+// mapped to their database column
+var taskPatchColumns = map[string]string{
+	"title":       "title",
+	"description": "description",
+	"completed":   "completed",
+	"priority":    "priority",
+	"start_at":    "start_at",
+	"due_at":      "due_at",
+	"recurrence":  "recurrence",
+	"parent_id":   "parent_id",
+	"project_id":  "project_id",
+}
+
+// jsonPatchOperation is an operation of a RFC 6902 JSON Patch document
+type jsonPatchOperation struct {
+	Op    string          `json:"op"`    // operation: add, remove, replace or test
+	Path  string          `json:"path"`  // JSON pointer of the target field
+	Value json.RawMessage `json:"value"` // value of the operation
+}
+
+// ParseMergePatch parses a RFC 7396 JSON Merge Patch document of a task, the
+// result maps the patched fields to their new value (null to reset a field)
+func ParseMergePatch(body []byte) (map[string]json.RawMessage, error) {
+	var patch map[string]json.RawMessage
+	if err := json.Unmarshal(body, &patch); err != nil || patch == nil {
+		return nil, errors.New(config.SInvalidPatch)
+	}
+
+	for field := range patch {
+		if _, ok := taskPatchColumns[field]; !ok {
+			return nil, errors.New(config.SInvalidPatchField + ": " + field)
+		}
+	}
+
+	return patch, nil
+}
+
+// ParseJSONPatch parses a RFC 6902 JSON Patch document of the task, the
+// result maps the patched fields to their new value like ParseMergePatch.
+// Supported operations: add and replace (set a field), remove (reset a field)
+// and test (compare a field with the current task, the whole patch fails if
+// they are different).
+func ParseJSONPatch(body []byte, task model.Task) (map[string]json.RawMessage, error) {
+	var operations []jsonPatchOperation
+	if err := json.Unmarshal(body, &operations); err != nil {
+		return nil, errors.New(config.SInvalidPatch)
+	}
+
+	current, err := taskFields(task)
+	if err != nil {
+		return nil, err
+	}
+
+	patch := make(map[string]json.RawMessage)
+	for _, o := range operations {
+		field := strings.TrimPrefix(o.Path, "/")
+		if _, ok := taskPatchColumns[field]; !ok || !strings.HasPrefix(o.Path, "/") {
+			return nil, errors.New(config.SInvalidPatchField + ": " + o.Path)
+		}
+
+		// following operations see the result of the previous ones
+		value, ok := patch[field]
+		if !ok {
+			value = current[field]
+		}
+
+		switch o.Op {
+		case "add", "replace":
+			if o.Value == nil {
+				return nil, errors.New("Missing: value")
+			}
+			patch[field] = o.Value
+		case "remove":
+			patch[field] = json.RawMessage("null")
+		case "test":
+			if !jsonEqual(value, o.Value) {
+				return nil, errors.New(config.SPatchTestFailed + ": " + o.Path)
+			}
+		default:
+			return nil, errors.New(config.SInvalidPatchOperation + ": " + o.Op)
+		}
+	}
+
+	return patch, nil
+}
+
+// ApplyTaskPatch applies the patched fields to a copy of the task, returns the
+// patched task and the database columns to update
+func ApplyTaskPatch(task model.Task, patch map[string]json.RawMessage) (model.Task, map[string]interface{}, error) {
+	fields, err := taskFields(task)
+	if err != nil {
+		return task, nil, err
+	}
+
+	for field, value := range patch {
+		if string(value) == "null" {
+			// missing fields are unmarshaled to their zero value
+			delete(fields, field)
+		} else {
+			fields[field] = value
+		}
+	}
+
+	b, err := json.Marshal(fields)
+	if err != nil {
+		return task, nil, err
+	}
+
+	var patched model.Task
+	if err := json.Unmarshal(b, &patched); err != nil {
+		return task, nil, errors.New(config.SInvalidPatch + ": " + err.Error())
+	}
+	patched.Base = task.Base
+	patched.UserID = task.UserID
+	patched.Occurrence = task.Occurrence
+	patched.NextID = task.NextID
+	patched.Tags = task.Tags
+
+	values := map[string]interface{}{
+		"title":       patched.Title,
+		"description": patched.Description,
+		"completed":   patched.Completed,
+		"priority":    patched.Priority,
+		"start_at":    patched.StartAt,
+		"due_at":      patched.DueAt,
+		"recurrence":  patched.Recurrence,
+		"parent_id":   patched.ParentID,
+		"project_id":  patched.ProjectID,
+	}
+
+	updates := make(map[string]interface{})
+	for field := range patch {
+		updates[taskPatchColumns[field]] = values[field]
+	}
+
+	return patched, updates, nil
+}
+
+// taskFields returns the JSON fields of the task
+func taskFields(task model.Task) (map[string]json.RawMessage, error) {
+	b, err := json.Marshal(task)
+	if err != nil {
+		return nil, err
+	}
+
+	var fields map[string]json.RawMessage
+	err = json.Unmarshal(b, &fields)
+
+	return fields, err
+}
+
+// jsonEqual returns true if a and b are the same JSON value
+func jsonEqual(a json.RawMessage, b json.RawMessage) bool {
+	var va, vb interface{}
+
+	if a == nil {
+		a = json.RawMessage("null")
+	}
+	if b == nil {
+		b = json.RawMessage("null")
+	}
+	if json.Unmarshal(a, &va) != nil || json.Unmarshal(b, &vb) != nil {
+		return false
+	}
+
+	return reflect.DeepEqual(va, vb)
+}
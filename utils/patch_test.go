@@ -0,0 +1,65 @@
+package utils
+
+import (
+	"testing"
+	"time"
+
+	"github.com/giuliobosco/todoAPI/model"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApplyTaskMergePatch(t *testing.T) {
+	due := time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC)
+	task := model.Task{Base: model.Base{ID: 1}, UserID: 2, Title: "T_Title", Description: "T_Description", DueAt: &due}
+
+	patch, err := ParseMergePatch([]byte(`{"completed": true, "due_at": null, "description": null}`))
+	assert.Nil(t, err)
+
+	patched, updates, err := ApplyTaskPatch(task, patch)
+	assert.Nil(t, err)
+	assert.Equal(t, "T_Title", patched.Title)
+	assert.Equal(t, uint(2), patched.UserID)
+	assert.True(t, patched.Completed)
+	assert.Nil(t, patched.DueAt)
+	assert.Equal(t, map[string]interface{}{"completed": true, "due_at": (*time.Time)(nil), "description": ""}, updates)
+
+	for _, body := range []string{`[]`, `null`, `{"userid": 3}`, `{"id": 3}`, `{"title": 3}`} {
+		patch, err = ParseMergePatch([]byte(body))
+		if err == nil {
+			_, _, err = ApplyTaskPatch(task, patch)
+		}
+		assert.NotNil(t, err, body)
+	}
+}
+
+func TestApplyTaskJSONPatch(t *testing.T) {
+	task := model.Task{Base: model.Base{ID: 1}, Title: "T_Title", Priority: 1}
+
+	patch, err := ParseJSONPatch([]byte(`[
+		{"op": "test", "path": "/priority", "value": 1},
+		{"op": "replace", "path": "/priority", "value": 3},
+		{"op": "test", "path": "/priority", "value": 3},
+		{"op": "remove", "path": "/title"}
+	]`), task)
+	assert.Nil(t, err)
+
+	patched, updates, err := ApplyTaskPatch(task, patch)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, patched.Priority)
+	assert.Equal(t, "", patched.Title)
+	assert.Len(t, updates, 2)
+
+	invalid := []string{
+		`{}`,
+		`[{"op": "test", "path": "/title", "value": "other"}]`,
+		`[{"op": "move", "from": "/title", "path": "/description"}]`,
+		`[{"op": "replace", "path": "/userid", "value": 3}]`,
+		`[{"op": "replace", "path": "title", "value": "a"}]`,
+		`[{"op": "add", "path": "/title"}]`,
+	}
+	for _, body := range invalid {
+		_, err = ParseJSONPatch([]byte(body), task)
+		assert.NotNil(t, err, body)
+	}
+}
@@ -0,0 +1,188 @@
+package utils
+
+import (
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/giuliobosco/todoAPI/config"
+
+	"github.com/jinzhu/gorm"
+)
+
+// taskSortColumns is the whitelist of the sortable task fields, mapped to
+// their database column
+var taskSortColumns = map[string]string{
+	"id":          "id",
+	"title":       "title",
+	"completed":   "completed",
+	"priority":    "priority",
+	"start_at":    "start_at",
+	"due_at":      "due_at",
+	"created_at":  "created_at",
+	"updated_at":  "updated_at",
+	"description": "description",
+}
+
+// defaultTaskSort is the sort applied when the sort parameter is missing
+const defaultTaskSort = "-created_at"
+
+// TaskQuery is the rappresentation of the filters and sorting of a task
+// listing
+type TaskQuery struct {
+	Completed  *bool      // filter on the completed flag
+	Priorities []int      // filter on the priority, any of the list
+	DueFrom    *time.Time // filter on the due date, included
+	DueTo      *time.Time // filter on the due date, excluded
+	Text       string     // filter on title and description
+	TagIDs     []uint     // filter on the tags, any of the list
+	Order      []string   // order clauses, already validated
+	Page       *Page      // page of the listing
+}
+
+// ParseTaskQuery parses and validates the task listing query parameters:
+// completed, priority (comma separated), due_from, due_to, tz, q, tag (comma
+// separated tag ids), sort
+// (comma separated fields, prefixed by - for descending order) and the page
+// parameters (see ParsePage)
+func ParseTaskQuery(p url.Values) (*TaskQuery, error) {
+	var q TaskQuery
+
+	if v := p.Get("completed"); len(v) > 0 {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, errors.New(config.SInvalidFilter + ": completed")
+		}
+		q.Completed = &b
+	}
+
+	if v := p.Get("priority"); len(v) > 0 {
+		for _, s := range strings.Split(v, ",") {
+			i, err := strconv.Atoi(strings.TrimSpace(s))
+			if err != nil {
+				return nil, errors.New(config.SInvalidFilter + ": priority")
+			}
+			q.Priorities = append(q.Priorities, i)
+		}
+	}
+
+	loc, err := LoadLocation(p.Get("tz"))
+	if err != nil {
+		return nil, err
+	}
+	if v := p.Get("due_from"); len(v) > 0 {
+		t, err := ParseDate(v, loc)
+		if err != nil {
+			return nil, errors.New(config.SInvalidFilter + ": due_from")
+		}
+		q.DueFrom = &t
+	}
+	if v := p.Get("due_to"); len(v) > 0 {
+		t, err := ParseDate(v, loc)
+		if err != nil {
+			return nil, errors.New(config.SInvalidFilter + ": due_to")
+		}
+		q.DueTo = &t
+	}
+
+	q.Text = strings.TrimSpace(p.Get("q"))
+
+	if v := p.Get("tag"); len(v) > 0 {
+		for _, s := range strings.Split(v, ",") {
+			id, err := strconv.ParseUint(strings.TrimSpace(s), 10, 32)
+			if err != nil {
+				return nil, errors.New(config.SInvalidFilter + ": tag")
+			}
+			q.TagIDs = append(q.TagIDs, uint(id))
+		}
+	}
+
+	sort := p.Get("sort")
+	if len(sort) == 0 {
+		sort = defaultTaskSort
+	}
+	q.Order, err = ParseSort(sort, taskSortColumns)
+	if err != nil {
+		return nil, err
+	}
+
+	// the cursors are over created_at, id, so they are only available when
+	// the listing is sorted by created_at alone
+	keyset := len(q.Order) == 1 && strings.HasPrefix(q.Order[0], "created_at ")
+	desc := keyset && strings.HasSuffix(q.Order[0], " desc")
+	if keyset {
+		q.Order = nil
+	}
+	q.Page, err = ParsePage(p, desc, keyset)
+	if err != nil {
+		return nil, err
+	}
+
+	return &q, nil
+}
+
+// ParseSort converts a comma separated list of fields, prefixed by - for
+// descending order, to order clauses. Only the fields of the whitelist are
+// accepted.
+func ParseSort(sort string, whitelist map[string]string) ([]string, error) {
+	var order []string
+
+	for _, f := range strings.Split(sort, ",") {
+		f = strings.TrimSpace(f)
+		direction := " asc"
+		if strings.HasPrefix(f, "-") {
+			direction = " desc"
+			f = f[1:]
+		}
+
+		column, ok := whitelist[f]
+		if !ok {
+			return nil, errors.New(config.SInvalidSort + ": " + f)
+		}
+		order = append(order, column+direction)
+	}
+
+	return order, nil
+}
+
+// Apply adds the filters, the order and the page of the query to db
+func (q *TaskQuery) Apply(db *gorm.DB) *gorm.DB {
+	if q.Completed != nil {
+		db = db.Where("completed = ?", *q.Completed)
+	}
+	if len(q.Priorities) > 0 {
+		db = db.Where("priority IN (?)", q.Priorities)
+	}
+	if q.DueFrom != nil {
+		db = db.Where("due_at >= ?", *q.DueFrom)
+	}
+	if q.DueTo != nil {
+		db = db.Where("due_at < ?", *q.DueTo)
+	}
+	if len(q.Text) > 0 {
+		like := "%" + EscapeLike(strings.ToLower(q.Text)) + "%"
+		db = db.Where(likeText, like, like)
+	}
+
+	if len(q.TagIDs) > 0 {
+		db = db.Where("id IN (SELECT task_id FROM task_tags WHERE tag_id IN (?))", q.TagIDs)
+	}
+
+	for _, o := range q.Order {
+		db = db.Order(o)
+	}
+
+	return q.Page.Apply(db)
+}
+
+// likeText is the condition matching the tasks with the escaped LIKE pattern
+// in the title or in the description, the escape character is set explicitly
+// because it is not a default of all the databases
+const likeText = `LOWER(title) LIKE ? ESCAPE '\' OR LOWER(description) LIKE ? ESCAPE '\'`
+
+// EscapeLike escapes the wildcards of a LIKE pattern
+func EscapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
@@ -0,0 +1,52 @@
+package utils
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTaskQuery(t *testing.T) {
+	p, _ := url.ParseQuery("completed=false&priority=2,3&due_from=2020-01-01&q=%20milk%20&tag=4,5&sort=-priority,due_at")
+	q, err := ParseTaskQuery(p)
+	assert.Nil(t, err)
+	assert.False(t, *q.Completed)
+	assert.Equal(t, []int{2, 3}, q.Priorities)
+	assert.NotNil(t, q.DueFrom)
+	assert.Nil(t, q.DueTo)
+	assert.Equal(t, "milk", q.Text)
+	assert.Equal(t, []uint{4, 5}, q.TagIDs)
+	assert.Equal(t, []string{"priority desc", "due_at asc"}, q.Order)
+	assert.False(t, q.Page.Keyset)
+
+	q, err = ParseTaskQuery(url.Values{})
+	assert.Nil(t, err)
+	assert.Nil(t, q.Completed)
+	assert.Nil(t, q.Order)
+	assert.True(t, q.Page.Keyset)
+	assert.True(t, q.Page.Desc)
+}
+
+func TestParseTaskQueryInvalid(t *testing.T) {
+	queries := []url.Values{
+		{"completed": {"maybe"}},
+		{"priority": {"high"}},
+		{"due_to": {"tomorrow"}},
+		{"tag": {"work"}},
+		{"tz": {"Mars/Olympus"}},
+		{"sort": {"password"}},
+		{"sort": {"-id;DROP TABLE tasks"}},
+		{"sort": {"due_at,"}},
+		{"sort": {"priority"}, "after": {Cursor{ID: 1}.Encode()}},
+	}
+
+	for _, p := range queries {
+		_, err := ParseTaskQuery(p)
+		assert.NotNil(t, err, p)
+	}
+}
+
+func TestEscapeLike(t *testing.T) {
+	assert.Equal(t, `100\% \_done\\`, EscapeLike(`100% _done\`))
+}
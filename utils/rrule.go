@@ -0,0 +1,217 @@
+package utils
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/giuliobosco/todoAPI/config"
+)
+
+// Frequencies of the recurrence rules
+const (
+	FreqDaily   = "DAILY"
+	FreqWeekly  = "WEEKLY"
+	FreqMonthly = "MONTHLY"
+	FreqYearly  = "YEARLY"
+)
+
+// maxRRuleSteps bounds the search of the next occurrence, enough to skip the
+// invalid dates of monthly and yearly rules (e.g. the 29th of february)
+const maxRRuleSteps = 1000
+
+// rruleWeekdays maps the RFC 5545 weekday names to time.Weekday
+var rruleWeekdays = map[string]time.Weekday{
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+	"SU": time.Sunday,
+}
+
+// RRule is the rappresentation of a RFC 5545 recurrence rule, supported
+// subset: FREQ (DAILY, WEEKLY, MONTHLY, YEARLY), INTERVAL, BYDAY (DAILY and
+// WEEKLY only, without ordinals), COUNT and UNTIL. Weeks start on monday.
+type RRule struct {
+	Freq     string         // frequency of the rule
+	Interval int            // interval between the periods of the rule
+	ByDay    []time.Weekday // days of the week of the occurrences
+	Count    int            // total number of occurrences, 0 for unlimited
+	Until    *time.Time     // last possible occurrence, nil for unlimited
+}
+
+// ParseRRule parses a recurrence rule, like FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,FR
+func ParseRRule(s string) (*RRule, error) {
+	r := RRule{Interval: 1}
+
+	s = strings.TrimPrefix(strings.TrimSpace(s), "RRULE:")
+	for _, part := range strings.Split(s, ";") {
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 {
+			return nil, errors.New(config.SInvalidRecurrence + ": " + part)
+		}
+		key, value := strings.ToUpper(kv[0]), strings.ToUpper(kv[1])
+
+		var err error
+		switch key {
+		case "FREQ":
+			if value != FreqDaily && value != FreqWeekly && value != FreqMonthly && value != FreqYearly {
+				return nil, errors.New(config.SInvalidRecurrence + ": FREQ")
+			}
+			r.Freq = value
+		case "INTERVAL":
+			if r.Interval, err = strconv.Atoi(value); err != nil || r.Interval <= 0 {
+				return nil, errors.New(config.SInvalidRecurrence + ": INTERVAL")
+			}
+		case "COUNT":
+			if r.Count, err = strconv.Atoi(value); err != nil || r.Count <= 0 {
+				return nil, errors.New(config.SInvalidRecurrence + ": COUNT")
+			}
+		case "UNTIL":
+			until, err := parseRRuleDate(value)
+			if err != nil {
+				return nil, errors.New(config.SInvalidRecurrence + ": UNTIL")
+			}
+			r.Until = &until
+		case "BYDAY":
+			for _, d := range strings.Split(value, ",") {
+				wd, ok := rruleWeekdays[d]
+				if !ok {
+					return nil, errors.New(config.SInvalidRecurrence + ": BYDAY")
+				}
+				r.ByDay = append(r.ByDay, wd)
+			}
+		default:
+			return nil, errors.New(config.SInvalidRecurrence + ": " + key)
+		}
+	}
+
+	if len(r.Freq) == 0 {
+		return nil, errors.New("Missing: FREQ")
+	}
+	if r.Count > 0 && r.Until != nil {
+		return nil, errors.New(config.SInvalidRecurrence + ": COUNT and UNTIL")
+	}
+	if len(r.ByDay) > 0 && r.Freq != FreqDaily && r.Freq != FreqWeekly {
+		return nil, errors.New(config.SInvalidRecurrence + ": BYDAY")
+	}
+
+	return &r, nil
+}
+
+// parseRRuleDate parses the UNTIL value, a date or a UTC date time
+func parseRRuleDate(s string) (time.Time, error) {
+	if t, err := time.Parse("20060102T150405Z", s); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse("20060102", s)
+
+	// a date includes the whole day
+	return t.Add(24*time.Hour - time.Nanosecond), err
+}
+
+// Next returns the occurrence following prev, index is the position of prev
+// in the recurrence (1 for the first occurrence). Returns false if the
+// recurrence is over.
+func (r *RRule) Next(prev time.Time, index int) (time.Time, bool) {
+	if r.Count > 0 && index >= r.Count {
+		return time.Time{}, false
+	}
+
+	next, ok := r.step(prev)
+	if !ok || r.Until != nil && next.After(*r.Until) {
+		return time.Time{}, false
+	}
+
+	return next, true
+}
+
+// Occurrences returns at most n occurrences following prev
+func (r *RRule) Occurrences(prev time.Time, index int, n int) []time.Time {
+	var occurrences []time.Time
+
+	for len(occurrences) < n {
+		next, ok := r.Next(prev, index)
+		if !ok {
+			break
+		}
+		occurrences = append(occurrences, next)
+		prev = next
+		index++
+	}
+
+	return occurrences
+}
+
+// step computes the occurrence following prev, ignoring COUNT and UNTIL
+func (r *RRule) step(prev time.Time) (time.Time, bool) {
+	y, m, d := prev.Date()
+	hh, mm, ss := prev.Clock()
+	at := func(y int, m time.Month, d int) time.Time {
+		return time.Date(y, m, d, hh, mm, ss, prev.Nanosecond(), prev.Location())
+	}
+
+	switch r.Freq {
+	case FreqDaily:
+		for i := 1; i <= maxRRuleSteps; i++ {
+			next := at(y, m, d+i*r.Interval)
+			if r.hasDay(next.Weekday()) {
+				return next, true
+			}
+		}
+	case FreqWeekly:
+		if len(r.ByDay) == 0 {
+			return at(y, m, d+7*r.Interval), true
+		}
+		// next day of the same week, then the first day of the next period
+		offset := (int(prev.Weekday()) + 6) % 7
+		for i := 1; offset+i < 7; i++ {
+			next := at(y, m, d+i)
+			if r.hasDay(next.Weekday()) {
+				return next, true
+			}
+		}
+		monday := d - offset + 7*r.Interval
+		for i := 0; i < 7; i++ {
+			next := at(y, m, monday+i)
+			if r.hasDay(next.Weekday()) {
+				return next, true
+			}
+		}
+	case FreqMonthly:
+		// months without the day are skipped
+		for i := 1; i <= maxRRuleSteps; i++ {
+			next := at(y, m+time.Month(i*r.Interval), d)
+			if next.Day() == d {
+				return next, true
+			}
+		}
+	case FreqYearly:
+		for i := 1; i <= maxRRuleSteps; i++ {
+			next := at(y+i*r.Interval, m, d)
+			if next.Day() == d {
+				return next, true
+			}
+		}
+	}
+
+	return time.Time{}, false
+}
+
+// hasDay returns true if the rule has no BYDAY or wd is one of its days
+func (r *RRule) hasDay(wd time.Weekday) bool {
+	if len(r.ByDay) == 0 {
+		return true
+	}
+
+	for _, d := range r.ByDay {
+		if d == wd {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,70 @@
+package utils
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRRule(t *testing.T) {
+	r, err := ParseRRule("RRULE:FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,FR;COUNT=4")
+	assert.Nil(t, err)
+	assert.Equal(t, FreqWeekly, r.Freq)
+	assert.Equal(t, 2, r.Interval)
+	assert.Equal(t, []time.Weekday{time.Monday, time.Friday}, r.ByDay)
+	assert.Equal(t, 4, r.Count)
+
+	r, err = ParseRRule("freq=daily;until=20200105")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, r.Interval)
+	assert.Equal(t, 5, r.Until.Day())
+
+	invalid := []string{
+		"",
+		"INTERVAL=2",
+		"FREQ=HOURLY",
+		"FREQ=DAILY;INTERVAL=0",
+		"FREQ=DAILY;COUNT=2;UNTIL=20200101",
+		"FREQ=MONTHLY;BYDAY=MO",
+		"FREQ=WEEKLY;BYDAY=1MO",
+		"FREQ=WEEKLY;BYMONTH=1",
+	}
+	for _, s := range invalid {
+		_, err = ParseRRule(s)
+		assert.NotNil(t, err, s)
+	}
+}
+
+func TestRRuleOccurrences(t *testing.T) {
+	date := func(y int, m time.Month, d int) time.Time {
+		return time.Date(y, m, d, 9, 0, 0, 0, time.UTC)
+	}
+	tests := []struct {
+		rule     string
+		prev     time.Time
+		expected []time.Time
+	}{
+		// 2020-01-06 is a monday
+		{"FREQ=DAILY;INTERVAL=2", date(2020, 1, 6), []time.Time{date(2020, 1, 8), date(2020, 1, 10)}},
+		{"FREQ=DAILY;BYDAY=SA,SU", date(2020, 1, 6), []time.Time{date(2020, 1, 11), date(2020, 1, 12), date(2020, 1, 18)}},
+		{"FREQ=WEEKLY", date(2020, 1, 6), []time.Time{date(2020, 1, 13), date(2020, 1, 20)}},
+		{"FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,FR", date(2020, 1, 6), []time.Time{date(2020, 1, 10), date(2020, 1, 20), date(2020, 1, 24)}},
+		{"FREQ=MONTHLY", date(2020, 1, 31), []time.Time{date(2020, 3, 31), date(2020, 5, 31)}},
+		{"FREQ=YEARLY", date(2020, 2, 29), []time.Time{date(2024, 2, 29)}},
+		{"FREQ=DAILY;COUNT=3", date(2020, 1, 6), []time.Time{date(2020, 1, 7), date(2020, 1, 8)}},
+		{"FREQ=DAILY;UNTIL=20200108", date(2020, 1, 6), []time.Time{date(2020, 1, 7), date(2020, 1, 8)}},
+	}
+
+	for _, test := range tests {
+		r, err := ParseRRule(test.rule)
+		assert.Nil(t, err, test.rule)
+		assert.Equal(t, test.expected, r.Occurrences(test.prev, 1, len(test.expected)), test.rule)
+	}
+
+	// the recurrence is over after COUNT occurrences or after UNTIL
+	for _, rule := range []string{"FREQ=DAILY;COUNT=3", "FREQ=DAILY;UNTIL=20200108"} {
+		r, _ := ParseRRule(rule)
+		assert.Len(t, r.Occurrences(date(2020, 1, 6), 1, 10), 2, rule)
+	}
+}
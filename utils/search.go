@@ -0,0 +1,223 @@
+package utils
+
+import (
+	"html"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/giuliobosco/todoAPI/model"
+
+	"github.com/jinzhu/gorm"
+)
+
+const (
+	// searchDocument is the text of a task searched by the full-text search
+	searchDocument = "coalesce(title, '') || ' ' || coalesce(description, '')"
+	// searchVector is the PostgreSQL text search vector of a task
+	searchVector = "to_tsvector('simple', " + searchDocument + ")"
+	// snippetStart is the start marker of the matches in the snippets
+	snippetStart = "<mark>"
+	// snippetStop is the stop marker of the matches in the snippets
+	snippetStop = "</mark>"
+	// headlineStart is the start marker of the matches in the ts_headline
+	// snippets, replaced by snippetStart after escaping the snippets
+	headlineStart = "\uE000"
+	// headlineStop is the stop marker of the matches in the ts_headline
+	// snippets, replaced by snippetStop after escaping the snippets
+	headlineStop = "\uE001"
+	// snippetRadius is the number of characters around the first match in the
+	// snippets of the LIKE search
+	snippetRadius = 40
+)
+
+// TaskSearchIndex is the PostgreSQL statement creating the GIN index of the
+// full-text search of the tasks
+const TaskSearchIndex = "CREATE INDEX IF NOT EXISTS idx_task_search ON tasks USING GIN (" + searchVector + ")"
+
+// TaskSearcher searches the tasks by keywords
+type TaskSearcher interface {
+	// Search returns at most limit tasks of db matching the query, the best
+	// matches first
+	Search(db *gorm.DB, query string, limit int) ([]model.SearchResult, error)
+}
+
+// NewTaskSearcher returns the searcher of the database dialect: the
+// PostgreSQL full-text search or a LIKE based search for the other databases
+func NewTaskSearcher(db *gorm.DB) TaskSearcher {
+	if db.Dialect().GetName() == "postgres" {
+		return postgresTaskSearcher{}
+	}
+
+	return likeTaskSearcher{}
+}
+
+// postgresTaskSearcher searches the tasks with tsvector and tsquery
+type postgresTaskSearcher struct{}
+
+// Search ranks the tasks with ts_rank and highlights the snippets with
+// ts_headline, the snippets are HTML escaped
+func (postgresTaskSearcher) Search(db *gorm.DB, query string, limit int) ([]model.SearchResult, error) {
+	results := []model.SearchResult{}
+
+	err := db.Table("tasks").
+		Select("tasks.*, ts_rank("+searchVector+", query) AS rank, "+
+			"ts_headline('simple', "+searchDocument+", query, 'StartSel="+headlineStart+", StopSel="+headlineStop+", MaxFragments=2') AS snippet").
+		Joins(", plainto_tsquery('simple', ?) query", query).
+		Where("tasks.deleted_at IS NULL AND " + searchVector + " @@ query").
+		Order("rank desc").
+		Limit(limit).
+		Scan(&results).Error
+
+	// ts_headline does not escape the text of the tasks
+	marks := strings.NewReplacer(headlineStart, snippetStart, headlineStop, snippetStop)
+	for i := range results {
+		results[i].Snippet = marks.Replace(html.EscapeString(results[i].Snippet))
+	}
+
+	return results, err
+}
+
+// likeTaskSearcher searches the tasks with LIKE, ranking the matches with
+// the number of occurrences of the terms and highlighting them in memory
+type likeTaskSearcher struct{}
+
+// Search returns the tasks containing all the terms of the query, ranked by
+// the number of matches (matches in the title count double)
+func (likeTaskSearcher) Search(db *gorm.DB, query string, limit int) ([]model.SearchResult, error) {
+	terms := SearchTerms(query)
+	results := []model.SearchResult{}
+	if len(terms) == 0 {
+		return results, nil
+	}
+
+	var rank []string
+	var args []interface{}
+	for _, t := range terms {
+		like := "%" + EscapeLike(t) + "%"
+		db = db.Where(likeText, like, like)
+		rank = append(rank, "2 * "+countMatches("title"), countMatches("description"))
+		args = append(args, t, t, t, t)
+	}
+
+	err := db.Table("tasks").
+		Select("tasks.*, "+strings.Join(rank, " + ")+" AS rank", args...).
+		Where("tasks.deleted_at IS NULL").
+		Order("rank desc").
+		Limit(limit).
+		Scan(&results).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for i, r := range results {
+		results[i].Snippet = Highlight(r.Title+" "+r.Description, terms)
+	}
+
+	return results, nil
+}
+
+// countMatches returns the SQL expression of the number of occurrences of a
+// term in the lower case column, the term is the argument of the expression
+func countMatches(column string) string {
+	text := "LOWER(coalesce(" + column + ", ''))"
+	return "(LENGTH(" + text + ") - LENGTH(REPLACE(" + text + ", ?, ''))) / LENGTH(?)"
+}
+
+// SearchTerms returns the distinct lower case terms of the query
+func SearchTerms(query string) []string {
+	var terms []string
+	seen := make(map[string]bool)
+
+	for _, t := range strings.Fields(strings.ToLower(query)) {
+		if !seen[t] {
+			seen[t] = true
+			terms = append(terms, t)
+		}
+	}
+
+	return terms
+}
+
+// Highlight returns the part of the text around the first match of the terms,
+// HTML escaped with the matches enclosed in <mark> and </mark>
+func Highlight(text string, terms []string) string {
+	// byte ranges of the matches in the text, the lower case text can have a
+	// different length
+	var matches [][2]int
+	for _, t := range terms {
+		for i := 0; i < len(text) && len(t) > 0; {
+			if n := matchFold(text[i:], t); n > 0 {
+				matches = append(matches, [2]int{i, i + n})
+				i += n
+				continue
+			}
+			_, size := utf8.DecodeRuneInString(text[i:])
+			i += size
+		}
+	}
+	if len(matches) == 0 {
+		return ""
+	}
+	sort.Slice(matches, func(i, j int) bool { return matches[i][0] < matches[j][0] })
+
+	start := matches[0][0] - snippetRadius
+	if start < 0 {
+		start = 0
+	}
+	end := matches[0][1] + snippetRadius
+	if end > len(text) {
+		end = len(text)
+	}
+	// do not cut the runes
+	for start > 0 && !isRuneStart(text[start]) {
+		start--
+	}
+	for end < len(text) && !isRuneStart(text[end]) {
+		end++
+	}
+
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("...")
+	}
+	pos := start
+	for _, m := range matches {
+		if m[0] < pos || m[1] > end {
+			continue
+		}
+		b.WriteString(html.EscapeString(text[pos:m[0]]))
+		b.WriteString(snippetStart + html.EscapeString(text[m[0]:m[1]]) + snippetStop)
+		pos = m[1]
+	}
+	b.WriteString(html.EscapeString(text[pos:end]))
+	if end < len(text) {
+		b.WriteString("...")
+	}
+
+	return b.String()
+}
+
+// matchFold returns the length in bytes of the prefix of s matching the lower
+// case term ignoring the case, 0 if s does not start with the term
+func matchFold(s string, term string) int {
+	n := 0
+	for _, tr := range term {
+		if n >= len(s) {
+			return 0
+		}
+		r, size := utf8.DecodeRuneInString(s[n:])
+		if unicode.ToLower(r) != tr {
+			return 0
+		}
+		n += size
+	}
+
+	return n
+}
+
+// isRuneStart returns true if the byte is the first byte of an UTF-8 rune
+func isRuneStart(b byte) bool {
+	return b&0xC0 != 0x80
+}
@@ -0,0 +1,76 @@
+package utils
+
+import (
+	"database/sql/driver"
+	"strings"
+	"testing"
+
+	"github.com/giuliobosco/todoAPI/config"
+
+	mocket "github.com/selvatico/go-mocket"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSearchTerms(t *testing.T) {
+	assert.Equal(t, []string{"buy", "milk"}, SearchTerms("  Buy milk buy "))
+	assert.Empty(t, SearchTerms(" "))
+}
+
+func TestLikeTaskSearcher(t *testing.T) {
+	db := config.TestInit()
+
+	var query string
+	var args []driver.NamedValue
+	mocket.Catcher.Reset()
+	mocket.Catcher.NewMock().WithQuery(`FROM "tasks"`).WithReply([]map[string]interface{}{{"id": 5, "title": "Buy milk", "rank": 2}}).WithCallback(func(q string, a []driver.NamedValue) {
+		query, args = q, a
+	})
+
+	results, err := likeTaskSearcher{}.Search(db, "100%", 10)
+	assert.NoError(t, err)
+	assert.Len(t, results, 1)
+	assert.Equal(t, float64(2), results[0].Rank)
+
+	// the ranking and the limit are in the query
+	assert.Contains(t, query, `ESCAPE '\'`)
+	assert.Contains(t, query, "ORDER BY rank desc LIMIT 10")
+	assert.Contains(t, query, "REPLACE(LOWER(coalesce(title, ''))")
+	assert.Len(t, args, 6)
+	assert.Equal(t, "100%", args[0].Value)
+	assert.Equal(t, `%100\%%`, args[4].Value)
+}
+
+func TestHighlight(t *testing.T) {
+	assert.Equal(t, "<mark>Buy</mark> <mark>milk</mark>", Highlight("Buy milk", []string{"milk", "buy"}))
+	assert.Equal(t, "", Highlight("Buy milk", []string{"eggs"}))
+
+	long := "The quick brown fox jumps over the lazy dog and keeps running far away from the farm"
+	assert.Equal(t, "The quick brown fox jumps over the lazy <mark>dog</mark> and keeps running far away from the far...", Highlight(long, []string{"dog"}))
+	assert.Equal(t, "...mps over the lazy dog and keeps running <mark>far</mark> away from the <mark>far</mark>m", Highlight(long, []string{"far"}))
+}
+
+func TestHighlightUnicode(t *testing.T) {
+	// the lower case of Ⱥ is longer than Ⱥ
+	text := strings.Repeat("Ⱥ", 100) + " milk"
+	assert.Equal(t, "..."+strings.Repeat("Ⱥ", 20)+" <mark>milk</mark>", Highlight(text, []string{"milk"}))
+	assert.Equal(t, "<mark>ȺȺ</mark> Milk", Highlight("ȺȺ Milk", []string{"ⱥⱥ"}))
+	assert.Equal(t, "Crème <mark>BRÛLÉE</mark>", Highlight("Crème BRÛLÉE", []string{"brûlée"}))
+}
+
+func TestHighlightEscape(t *testing.T) {
+	text := `<img src=x onerror="alert(1)"> & milk`
+	assert.Equal(t, "&lt;img src=x onerror=&#34;alert(1)&#34;&gt; &amp; <mark>milk</mark>", Highlight(text, []string{"milk"}))
+	assert.Equal(t, "<mark>&lt;b&gt;</mark> milk", Highlight("<b> milk", []string{"<b>"}))
+}
+
+func TestPostgresTaskSearcherEscape(t *testing.T) {
+	db := config.TestInit()
+
+	mocket.Catcher.Reset()
+	mocket.Catcher.NewMock().WithQuery("ts_headline").WithReply([]map[string]interface{}{{"id": 5, "snippet": "<script>x</script> " + headlineStart + "milk" + headlineStop}})
+
+	results, err := postgresTaskSearcher{}.Search(db, "milk", 10)
+	assert.NoError(t, err)
+	assert.Len(t, results, 1)
+	assert.Equal(t, "&lt;script&gt;x&lt;/script&gt; <mark>milk</mark>", results[0].Snippet)
+}
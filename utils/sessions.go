@@ -0,0 +1,190 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/giuliobosco/todoAPI/config"
+	"github.com/giuliobosco/todoAPI/model"
+
+	"github.com/jinzhu/gorm"
+)
+
+var (
+	// ErrInvalidRefreshToken is the error of an unknown refresh token or of a
+	// refresh token of an expired or revoked session
+	ErrInvalidRefreshToken = errors.New(config.SInvalidRefreshToken)
+	// ErrRefreshTokenReused is the error of a refresh token used twice, its
+	// session is revoked
+	ErrRefreshTokenReused = errors.New(config.SRefreshTokenReused)
+	// ErrInvalidChallenge is the error of an unknown, expired or exhausted
+	// login challenge
+	ErrInvalidChallenge = errors.New(config.SInvalidChallenge)
+)
+
+// HashToken returns the hex SHA-256 hash of the token, stored in place of
+// the token
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// CreateSession creates a session of the user, valid for timeout, and its
+// first refresh token, returns the session and the refresh token
+func CreateSession(db *gorm.DB, userID uint, userAgent string, ip string, timeout time.Duration) (model.Session, string, error) {
+	now := time.Now()
+	session := model.Session{UserID: userID, UserAgent: userAgent, IP: ip, LastUsedAt: now, ExpiresAt: now.Add(timeout)}
+
+	var token string
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&session).Error; err != nil {
+			return err
+		}
+
+		var err error
+		token, err = newRefreshToken(tx, session.ID)
+		return err
+	})
+
+	return session, token, err
+}
+
+// RotateRefreshToken uses the refresh token: the token is spent and the
+// session, extended by timeout, is returned with a new refresh token. Using
+// again a spent token revokes the session, since the token has been stolen
+// by the user or by the attacker.
+func RotateRefreshToken(db *gorm.DB, token string, ip string, timeout time.Duration) (model.Session, string, error) {
+	var session model.Session
+
+	var refresh model.RefreshToken
+	if err := db.Where("token_hash = ?", HashToken(token)).First(&refresh).Error; err != nil {
+		return session, "", ErrInvalidRefreshToken
+	}
+
+	now := time.Now()
+	if err := db.Where("id = ? AND revoked_at IS NULL AND expires_at > ?", refresh.SessionID, now).First(&session).Error; err != nil {
+		return session, "", ErrInvalidRefreshToken
+	}
+	if refresh.UsedAt != nil {
+		RevokeSessions(db.Where("id = ?", session.ID))
+		return session, "", ErrRefreshTokenReused
+	}
+
+	var next string
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// the token is spent only once, also by concurrent requests
+		spent := tx.Model(&model.RefreshToken{}).Where("id = ? AND used_at IS NULL", refresh.ID).Update("used_at", now)
+		if spent.Error != nil {
+			return spent.Error
+		}
+		if spent.RowsAffected == 0 {
+			return ErrRefreshTokenReused
+		}
+
+		session.IP, session.LastUsedAt, session.ExpiresAt = ip, now, now.Add(timeout)
+		err := tx.Model(&session).Updates(map[string]interface{}{"ip": session.IP, "last_used_at": now, "expires_at": session.ExpiresAt}).Error
+		if err != nil {
+			return err
+		}
+
+		next, err = newRefreshToken(tx, session.ID)
+		return err
+	})
+	if err == ErrRefreshTokenReused {
+		RevokeSessions(db.Where("id = ?", session.ID))
+	}
+
+	return session, next, err
+}
+
+// ActiveSession returns true if the session of the user is not expired and
+// not revoked
+func ActiveSession(db *gorm.DB, sessionID interface{}, userID interface{}) bool {
+	var sessions int
+	db.Model(&model.Session{}).Where("id = ? AND user_id = ? AND revoked_at IS NULL AND expires_at > ?", sessionID, userID, time.Now()).Count(&sessions)
+
+	return sessions > 0
+}
+
+// RevokeSessions revokes the active sessions selected by db, returns the
+// number of revoked sessions
+func RevokeSessions(db *gorm.DB) (int64, error) {
+	result := db.Model(&model.Session{}).Where("revoked_at IS NULL").Update("revoked_at", time.Now())
+	return result.RowsAffected, result.Error
+}
+
+// CreateLoginChallenge creates the second step of the login of the user with
+// the two-factor authentication, returns the challenge token and its
+// expiration
+func CreateLoginChallenge(db *gorm.DB, userID uint) (string, time.Time, error) {
+	token, err := GenerateRandomStringURLSafe(config.TokenLength)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	challenge := model.LoginChallenge{UserID: userID, TokenHash: HashToken(token), ExpiresAt: time.Now().Add(config.LoginChallengeValidity)}
+	if err := db.Save(&challenge).Error; err != nil {
+		return "", time.Time{}, err
+	}
+
+	return token, challenge.ExpiresAt, nil
+}
+
+// AttemptLoginChallenge returns the login challenge of the token, counting an
+// attempt: the challenges are refused after LoginChallengeAttempts attempts
+func AttemptLoginChallenge(db *gorm.DB, token string) (model.LoginChallenge, error) {
+	var challenge model.LoginChallenge
+	if err := db.Where("token_hash = ? AND expires_at > ?", HashToken(token), time.Now()).First(&challenge).Error; err != nil {
+		return challenge, ErrInvalidChallenge
+	}
+
+	attempt := db.Model(&model.LoginChallenge{}).
+		Where("id = ? AND attempts < ?", challenge.ID, config.LoginChallengeAttempts).
+		UpdateColumn("attempts", gorm.Expr("attempts + 1"))
+	if attempt.Error != nil || attempt.RowsAffected == 0 {
+		return challenge, ErrInvalidChallenge
+	}
+
+	return challenge, nil
+}
+
+// TwoFactorLocked returns true if the second step of the login of the user is
+// locked after too many wrong codes
+func TwoFactorLocked(user model.User) bool {
+	return user.TOTPLockedUntil != nil && time.Now().Before(*user.TOTPLockedUntil)
+}
+
+// RecordTwoFactorFailure counts a wrong code of the user: the failures are
+// counted across the login challenges, so new challenges do not give new
+// attempts, and TwoFactorFailures failures lock the second step of the login
+// for TwoFactorLockout
+func RecordTwoFactorFailure(db *gorm.DB, userID uint) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&model.User{}).Where("id = ?", userID).
+			UpdateColumn("totp_failures", gorm.Expr("totp_failures + 1")).Error
+		if err != nil {
+			return err
+		}
+
+		return tx.Model(&model.User{}).Where("id = ? AND totp_failures >= ?", userID, config.TwoFactorFailures).
+			UpdateColumns(map[string]interface{}{"totp_failures": 0, "totp_locked_until": time.Now().Add(config.TwoFactorLockout)}).Error
+	})
+}
+
+// ResetTwoFactorFailures clears the wrong codes of the user, after a login
+func ResetTwoFactorFailures(db *gorm.DB, userID uint) error {
+	return db.Model(&model.User{}).Where("id = ?", userID).
+		UpdateColumns(map[string]interface{}{"totp_failures": 0, "totp_locked_until": nil}).Error
+}
+
+// newRefreshToken creates a refresh token of the session, returns the token
+func newRefreshToken(tx *gorm.DB, sessionID uint) (string, error) {
+	token, err := GenerateRandomStringURLSafe(config.TokenLength)
+	if err != nil {
+		return "", err
+	}
+
+	return token, tx.Save(&model.RefreshToken{SessionID: sessionID, TokenHash: HashToken(token)}).Error
+}
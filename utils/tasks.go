@@ -0,0 +1,87 @@
+package utils
+
+import (
+	"github.com/giuliobosco/todoAPI/config"
+	"github.com/giuliobosco/todoAPI/model"
+
+	"github.com/jinzhu/gorm"
+)
+
+// TaskAncestorIDs returns the ids of the task parentID and of its ancestors,
+// from the nearest to the root. The walk stops after config.MaxTaskDepth
+// levels.
+func TaskAncestorIDs(db *gorm.DB, parentID uint) []uint {
+	var ids []uint
+
+	for id := parentID; id != 0 && len(ids) <= config.MaxTaskDepth; {
+		var parent model.Task
+		db.Select("id, parent_id").Where("id = ?", id).First(&parent)
+
+		if parent.ID == 0 {
+			break
+		}
+		ids = append(ids, parent.ID)
+
+		if parent.ParentID == nil {
+			break
+		}
+		id = *parent.ParentID
+	}
+
+	return ids
+}
+
+// TaskDescendants returns all the subtasks of the task rootID, level by level
+func TaskDescendants(db *gorm.DB, rootID uint) []model.Task {
+	var descendants []model.Task
+
+	parents := []uint{rootID}
+	for level := 0; level < config.MaxTaskDepth && len(parents) > 0; level++ {
+		var children []model.Task
+		db.Where("parent_id IN (?)", parents).Order("created_at asc").Find(&children)
+
+		parents = nil
+		for _, child := range children {
+			parents = append(parents, child.ID)
+		}
+		descendants = append(descendants, children...)
+	}
+
+	return descendants
+}
+
+// BuildTaskTree builds the tree of the root task from its descendants
+func BuildTaskTree(root model.Task, descendants []model.Task) model.TaskNode {
+	children := make(map[uint][]model.Task)
+	for _, t := range descendants {
+		if t.ParentID != nil {
+			children[*t.ParentID] = append(children[*t.ParentID], t)
+		}
+	}
+
+	return buildTaskNode(root, children)
+}
+
+// buildTaskNode builds the node of the task and its subtasks
+func buildTaskNode(task model.Task, children map[uint][]model.Task) model.TaskNode {
+	node := model.TaskNode{Task: task, Children: []model.TaskNode{}}
+
+	for _, child := range children[task.ID] {
+		node.Children = append(node.Children, buildTaskNode(child, children))
+	}
+
+	return node
+}
+
+// TaskHeight returns the number of levels of subtasks under the tree node
+func TaskHeight(node model.TaskNode) int {
+	height := 0
+
+	for _, child := range node.Children {
+		if h := TaskHeight(child) + 1; h > height {
+			height = h
+		}
+	}
+
+	return height
+}
@@ -0,0 +1,30 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/giuliobosco/todoAPI/model"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildTaskTree(t *testing.T) {
+	id := func(i uint) *uint { return &i }
+
+	root := model.Task{Base: model.Base{ID: 1}}
+	descendants := []model.Task{
+		{Base: model.Base{ID: 2}, ParentID: id(1)},
+		{Base: model.Base{ID: 3}, ParentID: id(1)},
+		{Base: model.Base{ID: 4}, ParentID: id(3)},
+		{Base: model.Base{ID: 5}, ParentID: id(4)},
+	}
+
+	tree := BuildTaskTree(root, descendants)
+	assert.Equal(t, uint(1), tree.ID)
+	assert.Len(t, tree.Children, 2)
+	assert.Empty(t, tree.Children[0].Children)
+	assert.Equal(t, uint(4), tree.Children[1].Children[0].ID)
+	assert.Equal(t, 3, TaskHeight(tree))
+
+	assert.Equal(t, 0, TaskHeight(BuildTaskTree(root, nil)))
+}
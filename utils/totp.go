@@ -0,0 +1,154 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/giuliobosco/todoAPI/config"
+	"github.com/giuliobosco/todoAPI/model"
+
+	"github.com/jinzhu/gorm"
+)
+
+// totpEncoding is the base32 encoding of the TOTP secrets, without padding
+// like in the otpauth URIs
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// recoveryCodeLetters are the letters of the recovery codes, without the
+// ones easily confused
+const recoveryCodeLetters = "abcdefghijkmnpqrstuvwxyz23456789"
+
+// GenerateTOTPSecret returns a new base32 TOTP secret
+func GenerateTOTPSecret() (string, error) {
+	b, err := GenerateRandomBytes(config.TOTPSecretLength)
+	if err != nil {
+		return "", err
+	}
+
+	return totpEncoding.EncodeToString(b), nil
+}
+
+// TOTPURI returns the otpauth URI of the secret of the account, shown as QR
+// code by the authenticator apps
+func TOTPURI(secret string, account string) string {
+	label := url.PathEscape(config.TOTPIssuer + ":" + account)
+	params := url.Values{}
+	params.Set("secret", secret)
+	params.Set("issuer", config.TOTPIssuer)
+	params.Set("algorithm", "SHA1")
+	params.Set("digits", fmt.Sprint(config.TOTPDigits))
+	params.Set("period", fmt.Sprint(int(config.TOTPPeriod/time.Second)))
+
+	return "otpauth://totp/" + label + "?" + params.Encode()
+}
+
+// TOTPCode returns the RFC 6238 code of the secret at the time step
+func TOTPCode(secret string, step int64, digits int) (string, error) {
+	key, err := totpEncoding.DecodeString(strings.ToUpper(secret))
+	if err != nil {
+		return "", err
+	}
+
+	msg := make([]byte, 8)
+	binary.BigEndian.PutUint64(msg, uint64(step))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg)
+	sum := mac.Sum(nil)
+
+	// RFC 4226 dynamic truncation
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	mod := uint32(1)
+	for i := 0; i < digits; i++ {
+		mod *= 10
+	}
+
+	return fmt.Sprintf("%0*d", digits, value%mod), nil
+}
+
+// TOTPStep returns the time step of t
+func TOTPStep(t time.Time) int64 {
+	return t.Unix() / int64(config.TOTPPeriod/time.Second)
+}
+
+// ValidateTOTP checks the code of the secret at the time t, accepting the
+// previous and the next step for the clock skew. A code is used only once:
+// the steps up to lastStep are refused. Returns the step of the code.
+func ValidateTOTP(secret string, code string, t time.Time, lastStep int64) (int64, bool) {
+	code = strings.TrimSpace(code)
+	if len(code) != config.TOTPDigits {
+		return 0, false
+	}
+
+	now := TOTPStep(t)
+	for step := now - 1; step <= now+1; step++ {
+		if step <= lastStep {
+			continue
+		}
+		expected, err := TOTPCode(secret, step, config.TOTPDigits)
+		if err == nil && hmac.Equal([]byte(expected), []byte(code)) {
+			return step, true
+		}
+	}
+
+	return 0, false
+}
+
+// NewRecoveryCodes replaces the recovery codes of the user, returns the new
+// codes
+func NewRecoveryCodes(db *gorm.DB, userID uint) ([]string, error) {
+	codes := make([]string, config.RecoveryCodeCount)
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&model.RecoveryCode{}).Error; err != nil {
+			return err
+		}
+
+		for i := range codes {
+			b, err := GenerateRandomBytes(10)
+			if err != nil {
+				return err
+			}
+			for j := range b {
+				b[j] = recoveryCodeLetters[int(b[j])%len(recoveryCodeLetters)]
+			}
+			codes[i] = string(b[:5]) + "-" + string(b[5:])
+
+			if err := tx.Save(&model.RecoveryCode{UserID: userID, CodeHash: HashToken(normalizeRecoveryCode(codes[i]))}).Error; err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+
+	return codes, err
+}
+
+// VerifySecondFactor checks the TOTP code or a recovery code of the user,
+// both usable only once
+func VerifySecondFactor(db *gorm.DB, user model.User, code string) bool {
+	if step, ok := ValidateTOTP(user.TOTPSecret, code, time.Now(), user.TOTPLastStep); ok {
+		// the code is spent only once, also by concurrent requests
+		spent := db.Model(&model.User{}).Where("id = ? AND totp_last_step < ?", user.ID, step).UpdateColumn("totp_last_step", step)
+		return spent.Error == nil && spent.RowsAffected > 0
+	}
+
+	spent := db.Model(&model.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", user.ID, HashToken(normalizeRecoveryCode(code))).
+		Update("used_at", time.Now())
+	return spent.Error == nil && spent.RowsAffected > 0
+}
+
+// normalizeRecoveryCode returns the recovery code without the separator and
+// the case, as typed by the user
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.Replace(strings.TrimSpace(code), "-", "", -1))
+}
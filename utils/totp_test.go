@@ -0,0 +1,66 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// rfc6238Secret is the base32 SHA-1 secret of the RFC 6238 test vectors
+const rfc6238Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestTOTPCode(t *testing.T) {
+	vectors := map[int64]string{
+		59:         "94287082",
+		1111111109: "07081804",
+		1234567890: "89005924",
+		2000000000: "69279037",
+	}
+
+	for unix, expected := range vectors {
+		code, err := TOTPCode(rfc6238Secret, TOTPStep(time.Unix(unix, 0)), 8)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, code)
+	}
+
+	_, err := TOTPCode("not base32!", 1, 6)
+	assert.Error(t, err)
+}
+
+func TestValidateTOTP(t *testing.T) {
+	now := time.Unix(1234567890, 0)
+	step := TOTPStep(now)
+	code, _ := TOTPCode(rfc6238Secret, step, 6)
+	previous, _ := TOTPCode(rfc6238Secret, step-1, 6)
+	old, _ := TOTPCode(rfc6238Secret, step-2, 6)
+
+	used, ok := ValidateTOTP(rfc6238Secret, code, now, 0)
+	assert.True(t, ok)
+	assert.Equal(t, step, used)
+
+	// the clock skew of one step is accepted
+	_, ok = ValidateTOTP(rfc6238Secret, previous, now, 0)
+	assert.True(t, ok)
+	_, ok = ValidateTOTP(rfc6238Secret, old, now, 0)
+	assert.False(t, ok)
+
+	// a used code is refused
+	_, ok = ValidateTOTP(rfc6238Secret, code, now, step)
+	assert.False(t, ok)
+	_, ok = ValidateTOTP(rfc6238Secret, "12345", now, 0)
+	assert.False(t, ok)
+}
+
+func TestTOTPURI(t *testing.T) {
+	secret, err := GenerateTOTPSecret()
+	assert.NoError(t, err)
+	assert.Len(t, secret, 32)
+
+	uri := TOTPURI(secret, "u1@example.com")
+	assert.True(t, strings.HasPrefix(uri, "otpauth://totp/todoAPI:u1@example.com?"))
+	assert.Contains(t, uri, "secret="+secret)
+	assert.Contains(t, uri, "issuer=todoAPI")
+	assert.Contains(t, uri, "period=30")
+}
@@ -0,0 +1,125 @@
+package utils
+
+import (
+	"log"
+	"time"
+
+	"github.com/giuliobosco/todoAPI/config"
+	"github.com/giuliobosco/todoAPI/model"
+
+	"github.com/jinzhu/gorm"
+)
+
+// taskRelations are the tables of the objects of the tasks, permanently
+// deleted with them
+var taskRelations = []string{"task_tags", "task_revisions", "comments", "attachments", "assignees"}
+
+// PurgeTask permanently deletes the task with its tag links, its history, its
+// comments, its attachments, its assignees, its dependencies and its shares
+func PurgeTask(db *gorm.DB, task model.Task) error {
+	var keys []string
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if keys, err = purgeTaskRelations(tx, "?", task.ID); err != nil {
+			return err
+		}
+
+		return tx.Unscoped().Delete(&task).Error
+	})
+	if err == nil {
+		deleteBlobs(keys)
+	}
+
+	return err
+}
+
+// PurgeTrash permanently deletes the tasks in the trash since before, with
+// their tag links, their history, their comments, their attachments, their
+// assignees, their dependencies and their shares, returns the number of
+// deleted tasks
+func PurgeTrash(db *gorm.DB, before time.Time) (int64, error) {
+	var purged int64
+	var keys []string
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if keys, err = purgeTaskRelations(tx, "SELECT id FROM tasks WHERE deleted_at IS NOT NULL AND deleted_at < ?", before); err != nil {
+			return err
+		}
+
+		result := tx.Unscoped().Where("deleted_at IS NOT NULL AND deleted_at < ?", before).Delete(&model.Task{})
+		purged = result.RowsAffected
+
+		return result.Error
+	})
+	if err == nil {
+		deleteBlobs(keys)
+	}
+
+	return purged, err
+}
+
+// purgeTaskRelations permanently deletes the objects of the tasks selected by
+// the ids query, returns the keys of the contents of their attachments
+func purgeTaskRelations(tx *gorm.DB, ids string, args ...interface{}) ([]string, error) {
+	var keys []string
+	if err := tx.Unscoped().Model(&model.Attachment{}).Where("task_id IN ("+ids+")", args...).Pluck("storage_key", &keys).Error; err != nil {
+		return nil, err
+	}
+
+	if err := tx.Exec("DELETE FROM task_dependencies WHERE task_id IN ("+ids+") OR blocker_id IN ("+ids+")", append(append([]interface{}{}, args...), args...)...).Error; err != nil {
+		return nil, err
+	}
+	if err := tx.Exec("DELETE FROM shares WHERE resource_type = ? AND resource_id IN ("+ids+")", append([]interface{}{model.ShareTask}, args...)...).Error; err != nil {
+		return nil, err
+	}
+	for _, table := range taskRelations {
+		if err := tx.Exec("DELETE FROM "+table+" WHERE task_id IN ("+ids+")", args...).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	return keys, nil
+}
+
+// deleteBlobs deletes the contents of the attachments from the store, the
+// errors are only logged since the attachments are already deleted
+func deleteBlobs(keys []string) {
+	store := config.GetStore()
+	if store == nil {
+		return
+	}
+
+	for _, key := range keys {
+		if err := store.Delete(key); err != nil {
+			log.Printf("attachment %s delete error: %s", key, err)
+		}
+	}
+}
+
+// StartTrashPurger starts a background job purging every interval the tasks in
+// the trash for more than retention. The returned function stops the job.
+func StartTrashPurger(db *gorm.DB, retention time.Duration, interval time.Duration) func() {
+	ticker := time.NewTicker(interval)
+	done := make(chan struct{})
+
+	go func() {
+		for {
+			if n, err := PurgeTrash(db, time.Now().Add(-retention)); err != nil {
+				log.Printf("trash purger error: %s", err)
+			} else if n > 0 {
+				log.Printf("trash purger: %d tasks deleted", n)
+			}
+
+			select {
+			case <-ticker.C:
+			case <-done:
+				ticker.Stop()
+				return
+			}
+		}
+	}()
+
+	return func() { close(done) }
+}
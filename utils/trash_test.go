@@ -0,0 +1,110 @@
+package utils
+
+import (
+	"database/sql/driver"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/giuliobosco/todoAPI/config"
+	"github.com/giuliobosco/todoAPI/storage"
+
+	mocket "github.com/selvatico/go-mocket"
+	"github.com/stretchr/testify/assert"
+)
+
+// testTrashStore sets a local store with the blob key as store of the
+// attachments, the returned function removes it
+func testTrashStore(t *testing.T, key string) (storage.Store, func()) {
+	root, err := ioutil.TempDir("", "trash")
+	assert.NoError(t, err)
+
+	store, err := storage.NewLocalStore(root)
+	assert.NoError(t, err)
+	assert.NoError(t, store.Put(key, strings.NewReader("blob"), 4, "text/plain"))
+	config.Store = store
+
+	return store, func() {
+		config.Store = nil
+		os.RemoveAll(root)
+	}
+}
+
+// recordDeletes registers the mocks of the purge of the trash, the deletions
+// are appended to deletes
+func recordDeletes(deletes *[]string, key string, purged int64) {
+	record := func(q string, _ []driver.NamedValue) { *deletes = append(*deletes, q) }
+
+	mocket.Catcher.NewMock().WithQuery(`SELECT storage_key FROM "attachments"`).WithReply([]map[string]interface{}{{"storage_key": key}})
+	mocket.Catcher.NewMock().WithQuery(`DELETE FROM "tasks"`).WithRowsNum(purged).WithCallback(record)
+	mocket.Catcher.NewMock().WithQuery(`DELETE FROM`).WithCallback(record)
+}
+
+func TestPurgeTrash(t *testing.T) {
+	db := config.TestInit()
+	store, cleanup := testTrashStore(t, "1/blob")
+	defer cleanup()
+
+	var deletes []string
+	mocket.Catcher.Reset()
+	recordDeletes(&deletes, "1/blob", 2)
+
+	purged, err := PurgeTrash(db, time.Now().Add(-time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), purged)
+
+	// the objects of the tasks are deleted before the tasks
+	for _, table := range append([]string{"task_dependencies", "shares"}, taskRelations...) {
+		assert.Contains(t, strings.Join(deletes, "\n"), "DELETE FROM "+table+" WHERE", table)
+	}
+	assert.Contains(t, deletes[len(deletes)-1], `DELETE FROM "tasks"  WHERE (deleted_at IS NOT NULL AND deleted_at < ?)`)
+
+	_, err = store.Get("1/blob")
+	assert.Equal(t, storage.ErrNotFound, err)
+}
+
+func TestPurgeTrashRollback(t *testing.T) {
+	db := config.TestInit()
+	store, cleanup := testTrashStore(t, "1/blob")
+	defer cleanup()
+
+	var deletes []string
+	mocket.Catcher.Reset()
+	mocket.Catcher.NewMock().WithQuery(`DELETE FROM comments`).WithExecException()
+	recordDeletes(&deletes, "1/blob", 2)
+
+	_, err := PurgeTrash(db, time.Now().Add(-time.Hour))
+	assert.Error(t, err)
+	assert.NotContains(t, strings.Join(deletes, "\n"), `DELETE FROM "tasks"`)
+
+	// the attachments of the tasks still in the trash are kept
+	content, err := store.Get("1/blob")
+	assert.NoError(t, err)
+	content.Close()
+}
+
+func TestStartTrashPurger(t *testing.T) {
+	db := config.TestInit()
+
+	purges := make(chan time.Time, 10)
+	mocket.Catcher.Reset()
+	mocket.Catcher.NewMock().WithQuery(`DELETE FROM "tasks"`).WithCallback(func(_ string, args []driver.NamedValue) {
+		purges <- args[0].Value.(time.Time)
+	})
+
+	stop := StartTrashPurger(db, 24*time.Hour, 10*time.Millisecond)
+	defer stop()
+
+	// a purge at the start and one every interval, of the tasks deleted
+	// before the retention
+	for i := 0; i < 2; i++ {
+		select {
+		case before := <-purges:
+			assert.WithinDuration(t, time.Now().Add(-24*time.Hour), before, time.Minute)
+		case <-time.After(time.Second):
+			t.Fatal("trash not purged")
+		}
+	}
+}
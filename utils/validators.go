@@ -2,6 +2,8 @@ package utils
 
 import (
 	"errors"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/giuliobosco/todoAPI/config"
@@ -56,6 +58,91 @@ func UserValidator(user model.User, usePassword bool) (bool, error) {
 	return true, nil
 }
 
+// TaskValidator validate task parameters
+func TaskValidator(task model.Task) (bool, error) {
+	if task.Priority < model.PriorityNone || task.Priority > model.PriorityHigh {
+		return false, errors.New(config.STaskInvalidPriority)
+	}
+
+	if task.StartAt != nil && task.DueAt != nil && task.DueAt.Before(*task.StartAt) {
+		return false, errors.New(config.STaskInvalidDates)
+	}
+
+	if len(task.Recurrence) > 0 {
+		if _, err := ParseRRule(task.Recurrence); err != nil {
+			return false, err
+		}
+		if task.DueAt == nil {
+			return false, errors.New(config.STaskRecurrenceNeedsDue)
+		}
+	}
+
+	return true, nil
+}
+
+// TagValidator validate tag parameters
+func TagValidator(tag model.Tag) (bool, error) {
+	if len(strings.TrimSpace(tag.Name)) == 0 {
+		return false, errors.New("Missing: name")
+	}
+	if len(tag.Name) > config.TagNameLength {
+		return false, errors.New(config.STagNameTooLong)
+	}
+
+	return true, nil
+}
+
+// CommentValidator validate comment parameters
+func CommentValidator(comment model.Comment) (bool, error) {
+	if len(strings.TrimSpace(comment.Body)) == 0 {
+		return false, errors.New("Missing: body")
+	}
+	if len(comment.Body) > config.CommentBodyLength {
+		return false, errors.New(config.SCommentTooLong)
+	}
+
+	return true, nil
+}
+
+// ProjectValidator validate project parameters
+func ProjectValidator(project model.Project) (bool, error) {
+	if len(strings.TrimSpace(project.Name)) == 0 {
+		return false, errors.New("Missing: name")
+	}
+
+	return true, nil
+}
+
+// WorkspaceValidator validate workspace parameters
+func WorkspaceValidator(workspace model.Workspace) (bool, error) {
+	if len(strings.TrimSpace(workspace.Name)) == 0 {
+		return false, errors.New("Missing: name")
+	}
+	if strings.ContainsAny(workspace.Name, "\r\n") {
+		return false, errors.New(config.SWorkspaceInvalidName)
+	}
+
+	return true, nil
+}
+
+// AccessTokenValidator validate personal access token parameters
+func AccessTokenValidator(accessToken model.AccessToken) (bool, error) {
+	if len(strings.TrimSpace(accessToken.Name)) == 0 {
+		return false, errors.New("Missing: name")
+	}
+	if len(accessToken.Name) > config.AccessTokenNameLength {
+		return false, errors.New(config.SAccessTokenNameTooLong)
+	}
+	if !ValidScopes(accessToken.Scopes) {
+		return false, errors.New(config.SAccessTokenInvalidScopes)
+	}
+	if accessToken.ExpiresAt != nil && !accessToken.ExpiresAt.After(time.Now()) {
+		return false, errors.New(config.SAccessTokenInvalidExpiry)
+	}
+
+	return true, nil
+}
+
 func ConfirmUserValidator(m map[string][]string) (*model.User, error) {
 	var missing []string
 
@@ -1,8 +1,11 @@
 package utils
 
 import (
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/giuliobosco/todoAPI/config"
 	"github.com/giuliobosco/todoAPI/model"
 
 	"github.com/stretchr/testify/assert"
@@ -32,11 +35,81 @@ func TestUserValidator(t *testing.T) {
 	}
 
 	for _, user := range users {
-		ok, err := UserValidator(user)
+		ok, err := UserValidator(user, true)
 		assert.False(t, ok, err)
 	}
 
 	user := model.User{Email: "giuliobva@gmail.com", Password: "b", Firstname: "c", Lastname: "d"}
-	ok, err := UserValidator(user)
+	ok, err := UserValidator(user, true)
 	assert.True(t, ok, err)
 }
+
+func TestTaskValidator(t *testing.T) {
+	start := time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC)
+	due := start.AddDate(0, 0, 1)
+
+	ok, _ := TaskValidator(model.Task{StartAt: &start, DueAt: &due})
+	assert.True(t, ok)
+	ok, _ = TaskValidator(model.Task{DueAt: &due})
+	assert.True(t, ok)
+	ok, _ = TaskValidator(model.Task{StartAt: &due, DueAt: &start})
+	assert.False(t, ok)
+
+	ok, _ = TaskValidator(model.Task{DueAt: &due, Recurrence: "FREQ=WEEKLY"})
+	assert.True(t, ok)
+	ok, _ = TaskValidator(model.Task{Recurrence: "FREQ=WEEKLY"})
+	assert.False(t, ok)
+	ok, _ = TaskValidator(model.Task{DueAt: &due, Recurrence: "FREQ=SOMETIMES"})
+	assert.False(t, ok)
+
+	for _, p := range []int{-1, model.PriorityHigh + 1} {
+		ok, _ = TaskValidator(model.Task{Priority: p})
+		assert.False(t, ok)
+	}
+}
+
+func TestCommentValidator(t *testing.T) {
+	ok, _ := CommentValidator(model.Comment{Body: "Done, see the receipt"})
+	assert.True(t, ok)
+
+	ok, err := CommentValidator(model.Comment{Body: "  "})
+	assert.False(t, ok)
+	assert.Equal(t, "Missing: body", err.Error())
+
+	ok, _ = CommentValidator(model.Comment{Body: strings.Repeat("a", config.CommentBodyLength+1)})
+	assert.False(t, ok)
+}
+
+func TestWorkspaceValidator(t *testing.T) {
+	ok, _ := WorkspaceValidator(model.Workspace{Name: "Team"})
+	assert.True(t, ok)
+	ok, _ = WorkspaceValidator(model.Workspace{Name: " "})
+	assert.False(t, ok)
+	ok, err := WorkspaceValidator(model.Workspace{Name: "Team\r\nBcc: victim@example.com"})
+	assert.False(t, ok)
+	assert.EqualError(t, err, config.SWorkspaceInvalidName)
+}
+
+func TestAccessTokenValidator(t *testing.T) {
+	ok, _ := AccessTokenValidator(model.AccessToken{Name: "backup", Scopes: "tasks:read profile:read"})
+	assert.True(t, ok)
+
+	ok, err := AccessTokenValidator(model.AccessToken{Name: "backup", Scopes: "tasks:read admin"})
+	assert.False(t, ok)
+	assert.Equal(t, config.SAccessTokenInvalidScopes, err.Error())
+
+	ok, _ = AccessTokenValidator(model.AccessToken{Name: "backup"})
+	assert.False(t, ok)
+
+	past := time.Now().Add(-time.Hour)
+	ok, err = AccessTokenValidator(model.AccessToken{Name: "backup", Scopes: "tasks:write", ExpiresAt: &past})
+	assert.False(t, ok)
+	assert.Equal(t, config.SAccessTokenInvalidExpiry, err.Error())
+}
+
+func TestHasScope(t *testing.T) {
+	assert.True(t, HasScope("tasks:write", model.ScopeTasksRead))
+	assert.True(t, HasScope("profile:read tasks:read", model.ScopeTasksRead))
+	assert.False(t, HasScope("tasks:read", model.ScopeTasksWrite))
+	assert.False(t, HasScope("tasks:write", model.ScopeProfileRead))
+}